@@ -0,0 +1,191 @@
+// Package auth tells who a request to the server is made by. Clients present
+// an API key, as a bearer token or in the X-API-Key header, or a client
+// certificate signed by a CA the server trusts. Whoever they turn out to be
+// is attached to the request's context as its Principal.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Methods a principal can authenticate with.
+const (
+	METHOD_API_KEY     = "apiKey"
+	METHOD_CLIENT_CERT = "clientCert"
+)
+
+// HASH_PREFIX marks a key in the keys file given as the hex SHA-256 digest of
+// the key, so that the file need not hold the key itself.
+const HASH_PREFIX = "sha256:"
+
+var (
+	errNoCredentials  = errors.New("no API key or client certificate")
+	errInvalidKey     = errors.New("invalid API key")
+	errMalformedToken = errors.New("malformed authorization header")
+)
+
+// Principal is who a request is made by.
+type Principal struct {
+	Name   string
+	Method string
+}
+
+// keys is the format of the keys file: the principals allowed to use the
+// server, each with any number of keys.
+//
+//	{
+//	    "principals": [
+//	        {"name": "grader", "keys": ["k3y", "sha256:9f86d08..."]}
+//	    ]
+//	}
+type keys struct {
+	Principals []struct {
+		Name string   `json:"name"`
+		Keys []string `json:"keys"`
+	} `json:"principals"`
+}
+
+// Authenticator checks the credentials of requests.
+type Authenticator struct {
+	// keys maps the SHA-256 digests of the keys to their principal.
+	keys        map[[sha256.Size]byte]string
+	clientCerts bool
+}
+
+// NewAuthenticator accepts the keys listed in keysFile, unless it is empty,
+// and with clientCerts, the verified client certificates of TLS connections,
+// whose subject's common name names the principal.
+func NewAuthenticator(keysFile string, clientCerts bool) (*Authenticator, error) {
+	a := &Authenticator{
+		keys:        make(map[[sha256.Size]byte]string),
+		clientCerts: clientCerts,
+	}
+	if keysFile == "" {
+		return a, nil
+	}
+
+	b, err := os.ReadFile(keysFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading keys file: %w", err)
+	}
+	var f keys
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("error parsing keys file: %w", err)
+	}
+
+	for _, p := range f.Principals {
+		if p.Name == "" {
+			return nil, errors.New("error parsing keys file: principal without a name")
+		}
+		for _, key := range p.Keys {
+			digest, err := keyDigest(key)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing keys file: key of %q: %w", p.Name, err)
+			}
+			if other, ok := a.keys[digest]; ok && other != p.Name {
+				return nil, fmt.Errorf("error parsing keys file: %q and %q share a key", other, p.Name)
+			}
+			a.keys[digest] = p.Name
+		}
+	}
+	return a, nil
+}
+
+func keyDigest(key string) ([sha256.Size]byte, error) {
+	var digest [sha256.Size]byte
+
+	if hexDigest, ok := strings.CutPrefix(key, HASH_PREFIX); ok {
+		b, err := hex.DecodeString(hexDigest)
+		if err != nil || len(b) != sha256.Size {
+			return digest, errors.New("invalid SHA-256 digest")
+		}
+		copy(digest[:], b)
+		return digest, nil
+	}
+
+	if key == "" {
+		return digest, errors.New("empty key")
+	}
+	return sha256.Sum256([]byte(key)), nil
+}
+
+// authenticate finds the principal of a request presenting key, which is
+// empty when none was given, over a connection in state, which is nil
+// without TLS. A key that is given must be valid, even with a certificate.
+func (a *Authenticator) authenticate(key string, state *tls.ConnectionState) (Principal, error) {
+	if key != "" {
+		name, ok := a.keys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return Principal{}, errInvalidKey
+		}
+		return Principal{Name: name, Method: METHOD_API_KEY}, nil
+	}
+
+	if a.clientCerts && state != nil && len(state.VerifiedChains) > 0 {
+		cert := state.VerifiedChains[0][0]
+		name := cert.Subject.CommonName
+		if name == "" {
+			name = cert.Subject.String()
+		}
+		return Principal{Name: name, Method: METHOD_CLIENT_CERT}, nil
+	}
+
+	return Principal{}, errNoCredentials
+}
+
+// bearerToken returns the token of an Authorization header value.
+func bearerToken(header string) (string, error) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", errMalformedToken
+	}
+	return token, nil
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal ctx carries, if any. Without
+// authentication, there is none.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Name returns the name of the principal ctx carries, or "" if none.
+func Name(ctx context.Context) string {
+	p, _ := FromContext(ctx)
+	return p.Name
+}
+
+// Owns tells whether the principal ctx carries may use what owner, the name
+// of the principal that made it, made. Anyone may use what was made without
+// authentication, and anything may be used without it.
+func Owns(ctx context.Context, owner string) bool {
+	p, ok := FromContext(ctx)
+	return !ok || owner == "" || p.Name == owner
+}
+
+// Listed tells whether the principal ctx carries is one of principals. "*"
+// lists anyone, including requests that are not authenticated.
+func Listed(ctx context.Context, principals []string) bool {
+	name := Name(ctx)
+	for _, p := range principals {
+		if p == "*" || (p == name && name != "") {
+			return true
+		}
+	}
+	return false
+}
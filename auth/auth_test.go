@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeys(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestAuthenticate(t *testing.T) {
+	digest := sha256.Sum256([]byte("hashed"))
+	a, err := NewAuthenticator(writeKeys(t, `{"principals": [
+		{"name": "grader", "keys": ["plain", "sha256:`+hex.EncodeToString(digest[:])+`"]},
+		{"name": "judge", "keys": ["other"]}
+	]}`), false)
+	require.NoError(t, err)
+
+	p, err := a.authenticate("plain", nil)
+	require.NoError(t, err)
+	require.Equal(t, Principal{Name: "grader", Method: METHOD_API_KEY}, p)
+
+	p, err = a.authenticate("hashed", nil)
+	require.NoError(t, err)
+	require.Equal(t, "grader", p.Name)
+
+	p, err = a.authenticate("other", nil)
+	require.NoError(t, err)
+	require.Equal(t, "judge", p.Name)
+
+	_, err = a.authenticate("sha256:"+hex.EncodeToString(digest[:]), nil)
+	require.ErrorIs(t, err, errInvalidKey)
+
+	_, err = a.authenticate("", nil)
+	require.ErrorIs(t, err, errNoCredentials)
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	for _, content := range []string{
+		`{`,
+		`{"principals": [{"keys": ["k"]}]}`,
+		`{"principals": [{"name": "a", "keys": [""]}]}`,
+		`{"principals": [{"name": "a", "keys": ["sha256:abc"]}]}`,
+		`{"principals": [{"name": "a", "keys": ["k"]}, {"name": "b", "keys": ["k"]}]}`,
+	} {
+		_, err := NewAuthenticator(writeKeys(t, content), false)
+		require.Error(t, err, content)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a, err := NewAuthenticator(writeKeys(t, `{"principals": [{"name": "grader", "keys": ["k3y"]}]}`), false)
+	require.NoError(t, err)
+
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Name(r.Context())))
+	}), func(w http.ResponseWriter, r *http.Request, msg string, status int) {
+		http.Error(w, msg, status)
+	})
+
+	tests := []struct {
+		method string
+		target string
+		header map[string]string
+		status int
+	}{
+		{http.MethodPost, "/exec", map[string]string{"Authorization": "Bearer k3y"}, http.StatusOK},
+		{http.MethodPost, "/exec", map[string]string{"Authorization": "bearer k3y"}, http.StatusOK},
+		{http.MethodPost, "/exec", map[string]string{"X-API-Key": "k3y"}, http.StatusOK},
+		{http.MethodGet, "/jobs/a/events?access_token=k3y", nil, http.StatusOK},
+		{http.MethodPost, "/exec?access_token=k3y", nil, http.StatusUnauthorized},
+		{http.MethodPost, "/exec", map[string]string{"Authorization": "Basic k3y"}, http.StatusUnauthorized},
+		{http.MethodPost, "/exec", map[string]string{"Authorization": "Bearer wrong"}, http.StatusUnauthorized},
+		{http.MethodPost, "/exec", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		require.Equal(t, tt.status, w.Code, "%s %s %v", tt.method, tt.target, tt.header)
+		if tt.status == http.StatusOK {
+			require.Equal(t, "grader", w.Body.String())
+		} else {
+			require.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestOwns(t *testing.T) {
+	ctx := context.Background()
+	require.True(t, Owns(ctx, "grader"))
+
+	ctx = NewContext(ctx, Principal{Name: "grader", Method: METHOD_API_KEY})
+	require.True(t, Owns(ctx, "grader"))
+	require.True(t, Owns(ctx, ""))
+	require.False(t, Owns(ctx, "judge"))
+}
+
+func TestListed(t *testing.T) {
+	ctx := context.Background()
+	require.False(t, Listed(ctx, nil))
+	require.False(t, Listed(ctx, []string{""}))
+	require.True(t, Listed(ctx, []string{"*"}))
+
+	ctx = NewContext(ctx, Principal{Name: "grader", Method: METHOD_API_KEY})
+	require.True(t, Listed(ctx, []string{"admin", "grader"}))
+	require.False(t, Listed(ctx, []string{"admin"}))
+	require.True(t, Listed(ctx, []string{"*"}))
+}
@@ -0,0 +1,37 @@
+package auth
+
+import "net/http"
+
+// Middleware lets requests through to next once their principal is known,
+// answering the others with fail. Browsers cannot set headers on
+// EventSource and WebSocket requests, so GET requests may also give their
+// key in the access_token query parameter.
+func (a *Authenticator) Middleware(next http.Handler, fail func(http.ResponseWriter, *http.Request, string, int)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := httpKey(r)
+		if err == nil {
+			var p Principal
+			p, err = a.authenticate(key, r.TLS)
+			if err == nil {
+				next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="castletown"`)
+		fail(w, r, err.Error(), http.StatusUnauthorized)
+	})
+}
+
+func httpKey(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		return bearerToken(header)
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, nil
+	}
+	if r.Method == http.MethodGet {
+		return r.URL.Query().Get("access_token"), nil
+	}
+	return "", nil
+}
@@ -21,8 +21,13 @@ func (b *RequestBuilder) WithID(id string) *RequestBuilder {
 	return b
 }
 
-// AddFile adds a file to the request.
+// AddFile adds a text file to the request.
 func (b *RequestBuilder) AddFile(name, content string) *RequestBuilder {
+	return b.AddBinaryFile(name, []byte(content))
+}
+
+// AddBinaryFile adds a file with arbitrary binary content to the request.
+func (b *RequestBuilder) AddBinaryFile(name string, content []byte) *RequestBuilder {
 	b.req.Files = append(b.req.Files, File{
 		Name:    name,
 		Content: content,
@@ -30,6 +35,36 @@ func (b *RequestBuilder) AddFile(name, content string) *RequestBuilder {
 	return b
 }
 
+// AddFileWithMode adds a file with the given permission bits to the request.
+func (b *RequestBuilder) AddFileWithMode(name string, content []byte, mode uint32) *RequestBuilder {
+	b.req.Files = append(b.req.Files, File{
+		Name:    name,
+		Content: content,
+		Mode:    mode,
+	})
+	return b
+}
+
+// AddDirectory adds an empty directory to the request.
+func (b *RequestBuilder) AddDirectory(name string) *RequestBuilder {
+	b.req.Files = append(b.req.Files, File{
+		Name: name,
+		Type: FileTypeDir,
+	})
+	return b
+}
+
+// AddArchive adds a tar (optionally gzip-compressed) or zip archive that the
+// server extracts into the directory dir. Use "." to extract into the box root.
+func (b *RequestBuilder) AddArchive(dir string, archiveType FileType, content []byte) *RequestBuilder {
+	b.req.Files = append(b.req.Files, File{
+		Name:    dir,
+		Content: content,
+		Type:    archiveType,
+	})
+	return b
+}
+
 // AddStep adds a process/step to the request using a ProcessBuilder.
 func (b *RequestBuilder) AddStep(fn func(*ProcessBuilder)) *RequestBuilder {
 	pb := NewProcess()
@@ -59,6 +94,12 @@ func NewProcess() *ProcessBuilder {
 	}
 }
 
+// WithName names the step so that later steps can refer to it.
+func (p *ProcessBuilder) WithName(name string) *ProcessBuilder {
+	p.proc.Name = name
+	return p
+}
+
 // WithImage sets the container image for the process.
 func (p *ProcessBuilder) WithImage(image string) *ProcessBuilder {
 	p.proc.Image = image
@@ -77,6 +118,56 @@ func (p *ProcessBuilder) WithStdin(stdin string) *ProcessBuilder {
 	return p
 }
 
+// WithStdinFile reads standard input from a file in the sandbox.
+func (p *ProcessBuilder) WithStdinFile(name string) *ProcessBuilder {
+	p.proc.StdinFile = name
+	return p
+}
+
+// WithStdinFrom feeds the output of an earlier step ("<step>.stdout" or
+// "<step>.stderr") into the standard input of this step.
+func (p *ProcessBuilder) WithStdinFrom(ref string) *ProcessBuilder {
+	p.proc.StdinFrom = ref
+	return p
+}
+
+// WithStdoutFile redirects standard output to a file in the sandbox.
+func (p *ProcessBuilder) WithStdoutFile(name string) *ProcessBuilder {
+	p.proc.StdoutFile = name
+	return p
+}
+
+// WithStderrFile redirects standard error to a file in the sandbox.
+func (p *ProcessBuilder) WithStderrFile(name string) *ProcessBuilder {
+	p.proc.StderrFile = name
+	return p
+}
+
+// WithFileSizeLimit sets the maximum size in megabytes of files the process may write.
+func (p *ProcessBuilder) WithFileSizeLimit(mb int64) *ProcessBuilder {
+	p.proc.FileSizeLimitMB = mb
+	return p
+}
+
+// WithEnv sets environment variables ("KEY=value"), overriding those of the
+// image with the same key.
+func (p *ProcessBuilder) WithEnv(env ...string) *ProcessBuilder {
+	p.proc.Env = append(p.proc.Env, env...)
+	return p
+}
+
+// WithWorkingDir sets the working directory, overriding the image's.
+func (p *ProcessBuilder) WithWorkingDir(dir string) *ProcessBuilder {
+	p.proc.WorkingDir = dir
+	return p
+}
+
+// WithUser sets the user the process runs as, overriding the image's.
+func (p *ProcessBuilder) WithUser(user string) *ProcessBuilder {
+	p.proc.User = user
+	return p
+}
+
 // WithMemoryLimit sets the memory limit in megabytes.
 func (p *ProcessBuilder) WithMemoryLimit(mb int64) *ProcessBuilder {
 	p.proc.MemoryLimitMB = mb
@@ -107,6 +198,38 @@ func (p *ProcessBuilder) WithPersist(files ...string) *ProcessBuilder {
 	return p
 }
 
+// WithOutputs specifies which files to collect and return after the step.
+func (p *ProcessBuilder) WithOutputs(patterns ...string) *ProcessBuilder {
+	p.proc.Outputs = append(p.proc.Outputs, patterns...)
+	return p
+}
+
+// WithInteractor runs an interactor next to this process, connected to it
+// through pipes, and judges the step by the interactor's exit code.
+func (p *ProcessBuilder) WithInteractor(fn func(*ProcessBuilder)) *ProcessBuilder {
+	ib := NewProcess()
+	fn(ib)
+	interactor := ib.Build()
+	p.proc.Interactor = &interactor
+	return p
+}
+
+// WithService starts a service next to this process in a shared network
+// namespace. The step's process can reach it on 127.0.0.1.
+func (p *ProcessBuilder) WithService(fn func(*ProcessBuilder)) *ProcessBuilder {
+	sb := NewProcess()
+	fn(sb)
+	p.proc.Services = append(p.proc.Services, sb.Build())
+	return p
+}
+
+// WithReadinessPort makes the step wait until this service accepts TCP
+// connections on port, for at most timeoutMs (0 = server default).
+func (p *ProcessBuilder) WithReadinessPort(port int32, timeoutMs int64) *ProcessBuilder {
+	p.proc.Readiness = &Readiness{TCPPort: port, TimeoutMs: timeoutMs}
+	return p
+}
+
 // Build returns the constructed Process.
 func (p *ProcessBuilder) Build() Process {
 	return p.proc
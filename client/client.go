@@ -4,6 +4,7 @@ package client
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	pb "github.com/joshjms/castletown/proto"
@@ -16,10 +17,52 @@ type Client interface {
 	// If req.ID is empty, a unique ID will be generated by the server.
 	Execute(ctx context.Context, req *ExecRequest) (*ExecResponse, error)
 
+	// ExecuteStream submits a job like Execute and returns its events as
+	// they happen: the job being accepted, each step starting and finishing
+	// with its report, and the job being done. Over HTTP, a job without an
+	// ID is given one by the client.
+	ExecuteStream(ctx context.Context, req *ExecRequest) (ExecStream, error)
+
+	// GetArtifact downloads an output file produced by an executed step.
+	// This works for any file selected by the step's Outputs, including
+	// those omitted from the report for exceeding the inline size limits.
+	GetArtifact(ctx context.Context, jobID string, step int, path string) ([]byte, error)
+
 	// Done notifies the server that the job is complete and can be cleaned up.
 	// This is optional but recommended to free up resources on the server.
 	Done(ctx context.Context, jobID string) error
 
+	// OpenSession starts a long-lived process, such as an interpreter, whose
+	// stdin and output are streamed through the returned Session. The
+	// session lives until the process exits, ctx is cancelled or the session
+	// is closed.
+	OpenSession(ctx context.Context, req *SessionRequest) (Session, error)
+
+	// ListImages returns the images available to jobs, sorted by name.
+	ListImages(ctx context.Context) ([]Image, error)
+
+	// GetImage returns a single image by the name jobs refer to it with.
+	GetImage(ctx context.Context, name string) (*Image, error)
+
+	// DeleteImage removes an image from the server. It fails while any
+	// sandbox is running on the image.
+	DeleteImage(ctx context.Context, name string) error
+
+	// BuildImage runs steps on top of a base image and registers what they
+	// change as a new image. Build steps get more time and memory than job
+	// steps, network access and root over the image's files, so that they
+	// can install packages. Builds often outlast the default timeout of 30
+	// seconds, so raise ClientOptions.Timeout for them.
+	BuildImage(ctx context.Context, req *BuildImageRequest) (*BuildImageResponse, error)
+
+	// CollectGarbage removes the layers no image refers to and the overlays
+	// and files left behind by sandboxes and jobs that are gone. With dryRun
+	// it only reports what it would remove.
+	CollectGarbage(ctx context.Context, dryRun bool) (*GCResult, error)
+
+	// DiskUsage reports the disk space the server's data takes.
+	DiskUsage(ctx context.Context) (*DiskUsage, error)
+
 	// Close closes the client and releases any resources.
 	Close() error
 }
@@ -43,12 +86,47 @@ type File struct {
 	// Name is the filename (can include relative paths).
 	Name string
 
-	// Content is the file content.
-	Content string
+	// Content is the raw file content. It may hold arbitrary binary data
+	// such as compiled executables or archives.
+	Content []byte
+
+	// Type tells the server how to materialize the file (default: regular file).
+	// Directories are created empty; archives are extracted into the directory Name.
+	Type FileType
+
+	// Mode is the Unix permission bits, e.g. 0755 (0 = server default).
+	Mode uint32
+}
+
+// FileType tells the server how to materialize a File.
+type FileType int32
+
+const (
+	FileTypeRegular FileType = 0
+	FileTypeDir     FileType = 1
+	FileTypeTar     FileType = 2
+	FileTypeZip     FileType = 3
+)
+
+// String returns the string representation of the file type.
+func (t FileType) String() string {
+	switch t {
+	case FileTypeDir:
+		return "dir"
+	case FileTypeTar:
+		return "tar"
+	case FileTypeZip:
+		return "zip"
+	default:
+		return "file"
+	}
 }
 
 // Process represents a single execution step in the sandbox.
 type Process struct {
+	// Name optionally identifies the step so later steps can refer to it.
+	Name string
+
 	// Image is the container image name (must be available on server).
 	// Example: "gcc:15-bookworm"
 	Image string
@@ -70,12 +148,209 @@ type Process struct {
 	ProcLimit int64
 
 	// Files specifies which files to make available in this step.
-	// References files by name from the Files array.
+	// References files by name from the Files array or persisted by earlier
+	// steps. Entries may name directories or use glob patterns.
 	Files []string
 
 	// Persist specifies which output files to persist to the next step.
 	// Only files listed here will be available to subsequent steps.
+	// Entries may name directories or use glob patterns such as "build/**".
 	Persist []string
+
+	// Outputs specifies files to collect after the step and return in its
+	// Report, e.g. "coverage/**" or "*.xml". Large files are omitted from the
+	// report and can be fetched with GetArtifact.
+	Outputs []string
+
+	// StdinFile reads standard input from a file in the sandbox instead of
+	// Stdin. The file may come from Files or be persisted by an earlier step.
+	StdinFile string
+
+	// StdinFrom feeds the captured output of an earlier step into this
+	// step's standard input. The format is "<step>.stdout" or
+	// "<step>.stderr", where <step> is a step Name or index.
+	StdinFrom string
+
+	// StdoutFile and StderrFile redirect the output streams to files in the
+	// sandbox. They can be persisted or returned via Outputs; the matching
+	// Report fields stay empty.
+	StdoutFile string
+	StderrFile string
+
+	// FileSizeLimitMB is the largest file the process may write, including
+	// StdoutFile and StderrFile (0 = server default).
+	FileSizeLimitMB int64
+
+	// Env, WorkingDir and User override the defaults from the image's OCI
+	// config. Env entries ("KEY=value") replace those with the same key.
+	// User is "name", "uid", "name:group" or "uid:gid". An empty Cmd runs
+	// the image's entrypoint and command.
+	Env        []string
+	WorkingDir string
+	User       string
+
+	// Interactor makes this an interactive step: the interactor runs at the
+	// same time with its stdout connected to this process' stdin and vice
+	// versa. Its exit code decides the Verdict (testlib convention: 0 = OK,
+	// 1 = wrong answer, 2 = presentation error, 3 = judge failure).
+	Interactor *Process
+
+	// Services are started before this process in a pod that shares one
+	// loopback-only network namespace, so the process can reach them on
+	// 127.0.0.1. They are stopped once the process finishes.
+	Services []Process
+
+	// Readiness delays the step's process until this service accepts
+	// connections (services only).
+	Readiness *Readiness
+}
+
+// Readiness tells when a service is ready.
+type Readiness struct {
+	TCPPort   int32 // Port polled on 127.0.0.1 inside the pod
+	TimeoutMs int64 // How long to wait (0 = server default)
+}
+
+// SessionRequest describes the process of a session.
+type SessionRequest struct {
+	// Files are available to the process as in ExecRequest.
+	Files []File
+
+	// Process is the long-lived process. Its TimeLimitMs is the CPU budget
+	// of the whole session; its standard streams cannot be configured.
+	Process Process
+
+	// IdleTimeoutMs ends the session after this long without input or
+	// output (0 = server default, which it cannot exceed).
+	IdleTimeoutMs int64
+
+	// Terminal runs the process on a pseudo-terminal of this size, for
+	// shells and curses programs. Stderr is then merged into stdout.
+	Terminal *TerminalSize
+}
+
+// TerminalSize is the size of a terminal in characters.
+type TerminalSize struct {
+	Cols uint16
+	Rows uint16
+}
+
+// Session is a running process started with OpenSession. Write and Recv may
+// be used from different goroutines.
+type Session interface {
+	// ID returns the session identifier assigned by the server.
+	ID() string
+
+	// Write sends p to the process' standard input.
+	Write(p []byte) (int, error)
+
+	// CloseStdin signals end of input to the process. On a terminal it
+	// sends the end-of-file character (Ctrl-D).
+	CloseStdin() error
+
+	// Resize changes the size of the session's terminal.
+	Resize(cols, rows uint16) error
+
+	// Recv returns the next output chunk or, last, the exit report. It
+	// returns io.EOF after the exit report.
+	Recv() (*SessionEvent, error)
+
+	// Close ends the session, killing the process if it is still running.
+	Close() error
+}
+
+// SessionEvent is received from a session. Exactly one field is set.
+type SessionEvent struct {
+	Stdout []byte
+	Stderr []byte
+	Exit   *Report
+}
+
+// ExecStream is a job started with ExecuteStream.
+type ExecStream interface {
+	// Recv returns the next event of the job. It returns io.EOF after the
+	// job done event.
+	Recv() (*ExecEvent, error)
+
+	// Close stops receiving events, cancelling the job if it is still
+	// running.
+	Close() error
+}
+
+// ExecEventType tells what an ExecEvent reports.
+type ExecEventType int32
+
+const (
+	ExecEventUnspecified  ExecEventType = 0
+	ExecEventJobAccepted  ExecEventType = 1
+	ExecEventStepStarted  ExecEventType = 2
+	ExecEventStepFinished ExecEventType = 3
+	ExecEventJobDone      ExecEventType = 4
+	ExecEventOutput       ExecEventType = 5
+)
+
+// String returns the string representation of the event type.
+func (t ExecEventType) String() string {
+	switch t {
+	case ExecEventJobAccepted:
+		return "JOB_ACCEPTED"
+	case ExecEventStepStarted:
+		return "STEP_STARTED"
+	case ExecEventStepFinished:
+		return "STEP_FINISHED"
+	case ExecEventJobDone:
+		return "JOB_DONE"
+	case ExecEventOutput:
+		return "OUTPUT"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+// ExecEvent reports the progress of a job.
+type ExecEvent struct {
+	// Type tells what happened.
+	Type ExecEventType
+
+	// JobID is the unique job identifier, generated by the server if the
+	// request had none.
+	JobID string
+
+	// Step is the index of the step the event is about. For job events it
+	// is the next step to run.
+	Step int
+
+	// Steps is the number of steps of the job.
+	Steps int
+
+	// Report is the step's report (ExecEventStepFinished only).
+	Report *Report
+
+	// Output is a piece of what the step's process wrote while it ran
+	// (ExecEventOutput only).
+	Output *OutputChunk
+
+	// Error tells why the job stopped early when a step could not be run
+	// (ExecEventJobDone only). Steps that fail are reported normally.
+	Error string
+
+	// Time is when the event happened.
+	Time time.Time
+}
+
+// OutputChunk is a piece of what a step's process wrote to stdout or
+// stderr. Streamed output is best effort: when it comes faster than the
+// server's rate limit or than it is received, some is left out, which
+// Dropped counts. The step's report always has all of it.
+type OutputChunk struct {
+	// Stream is "stdout" or "stderr".
+	Stream string
+
+	// Data is the output.
+	Data []byte
+
+	// Dropped is the number of bytes of the stream left out before Data.
+	Dropped int64
 }
 
 // ExecResponse contains the execution results.
@@ -98,11 +373,16 @@ type Report struct {
 	// Signal is the signal that terminated the process (-1 if normal exit).
 	Signal int32
 
-	// Stdout is the standard output captured from the process.
-	Stdout string
+	// Stdout is the raw standard output captured from the process.
+	Stdout []byte
+
+	// Stderr is the raw standard error captured from the process.
+	Stderr []byte
 
-	// Stderr is the standard error captured from the process.
-	Stderr string
+	// StdoutTruncated and StderrTruncated tell that the stream was longer
+	// than the server's output limit and its end is missing.
+	StdoutTruncated bool
+	StderrTruncated bool
 
 	// CPUTime is the CPU time used in nanoseconds.
 	CPUTime uint64
@@ -118,6 +398,138 @@ type Report struct {
 
 	// FinishAt is the finish timestamp in Unix nanoseconds.
 	FinishAt int64
+
+	// Artifacts are the files collected from the step's Outputs.
+	Artifacts []Artifact
+
+	// Interactor is the interactor's report (interactive steps only).
+	Interactor *Report
+
+	// Verdict is derived from both reports (interactive steps only).
+	Verdict Verdict
+
+	// Services are the reports of the step's services, in order.
+	Services []Report
+}
+
+// Artifact is an output file collected from the sandbox after a step.
+type Artifact struct {
+	// Path is the file path relative to the sandbox working directory.
+	Path string
+
+	// Content is the raw file content (empty if Omitted).
+	Content []byte
+
+	// Size is the file size in bytes.
+	Size int64
+
+	// Omitted is true when the file exceeded the server's inline size limits.
+	// Use Client.GetArtifact to download it.
+	Omitted bool
+}
+
+// Image describes an image available on the server.
+type Image struct {
+	// Name is what Process.Image refers to, e.g. "gcc:15-bookworm".
+	Name string
+
+	// Digest is the manifest digest (OCI layouts) or image ID (Docker
+	// archives) the image was imported from. Empty if unknown.
+	Digest string
+
+	// Size is the disk space of the unpacked image in bytes.
+	Size int64
+
+	// ImportedAt is when the image was imported. Zero if unknown.
+	ImportedAt time.Time
+
+	// InUse is true while any sandbox is running on the image.
+	InUse bool
+
+	// Layers are the diff IDs of the image's layers, bottom first.
+	Layers []string
+
+	// Config holds the defaults the image's OCI config sets.
+	Config ImageConfig
+}
+
+// BuildImageRequest describes an image to build from a base image.
+type BuildImageRequest struct {
+	// Name is the name to register the new image under.
+	Name string
+
+	// Base is the image the steps run on.
+	Base string
+
+	// Files are the files to create in the sandbox environment. They are
+	// not part of the new image unless a step copies them out of /box.
+	Files []File
+
+	// Steps are the processes to execute sequentially. Each sees the
+	// changes of the steps before it; Image may be left empty.
+	Steps []Process
+}
+
+// BuildImageResponse contains the results of a build.
+type BuildImageResponse struct {
+	// ID is the unique build identifier.
+	ID string
+
+	// Image is the new image, or nil if a step failed.
+	Image *Image
+
+	// Error tells which step failed, if any.
+	Error string
+
+	// Reports contains one report per executed step.
+	Reports []Report
+}
+
+// GCResult lists what a garbage collection removed.
+type GCResult struct {
+	// Layers are the diff IDs of the layers removed.
+	Layers []string
+
+	// Overlays are the names of the sandbox and build overlay directories
+	// removed.
+	Overlays []string
+
+	// Storage are the IDs of the jobs whose files were removed.
+	Storage []string
+
+	// Freed is the disk space reclaimed in bytes.
+	Freed int64
+}
+
+// DiskUsage is the disk space taken by each kind of data on the server, in
+// bytes.
+type DiskUsage struct {
+	// Images is what images take beside their layers, which is next to
+	// nothing for images imported as layers.
+	Images int64
+
+	// Layers is the space of the image layers, each counted once however
+	// many images share it.
+	Layers int64
+
+	// Overlays is the space of the root filesystem changes of sandboxes
+	// and builds.
+	Overlays int64
+
+	// Storage is the space of the files of jobs.
+	Storage int64
+
+	// Reclaimable is what CollectGarbage would free now.
+	Reclaimable int64
+}
+
+// ImageConfig holds the defaults an image's OCI config sets for containers.
+type ImageConfig struct {
+	User       string
+	Env        []string
+	Entrypoint []string
+	Cmd        []string
+	WorkingDir string
 }
 
 // Status represents the execution status of a process.
@@ -161,6 +573,45 @@ func (s Status) String() string {
 	}
 }
 
+// Verdict is the outcome of an interactive step.
+type Verdict int32
+
+const (
+	VerdictUnspecified         Verdict = 0
+	VerdictAccepted            Verdict = 1
+	VerdictWrongAnswer         Verdict = 2
+	VerdictPresentationError   Verdict = 3
+	VerdictTimeLimitExceeded   Verdict = 4
+	VerdictMemoryLimitExceeded Verdict = 5
+	VerdictOutputLimitExceeded Verdict = 6
+	VerdictRuntimeError        Verdict = 7
+	VerdictJudgeError          Verdict = 8
+)
+
+// String returns the string representation of the verdict.
+func (v Verdict) String() string {
+	switch v {
+	case VerdictAccepted:
+		return "ACCEPTED"
+	case VerdictWrongAnswer:
+		return "WRONG_ANSWER"
+	case VerdictPresentationError:
+		return "PRESENTATION_ERROR"
+	case VerdictTimeLimitExceeded:
+		return "TIME_LIMIT_EXCEEDED"
+	case VerdictMemoryLimitExceeded:
+		return "MEMORY_LIMIT_EXCEEDED"
+	case VerdictOutputLimitExceeded:
+		return "OUTPUT_LIMIT_EXCEEDED"
+	case VerdictRuntimeError:
+		return "RUNTIME_ERROR"
+	case VerdictJudgeError:
+		return "JUDGE_ERROR"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
 // ClientOptions contains configuration options for creating a client.
 type ClientOptions struct {
 	// Address is the server address. For HTTP, include the scheme (http://localhost:8000).
@@ -172,6 +623,25 @@ type ClientOptions struct {
 
 	// GRPCOptions contains additional gRPC-specific options (only used for gRPC client).
 	GRPCOptions *GRPCOptions
+
+	// APIKey authenticates the client to a server that requires it. It is
+	// sent as a bearer token with every request.
+	APIKey string
+
+	// CertFile and KeyFile are a PEM client certificate and its key, which
+	// authenticate the client to a server that trusts the CA that signed
+	// the certificate. They need a TLS connection: an https:// address for
+	// HTTP, and GRPCOptions.Insecure unset for gRPC.
+	CertFile string
+	KeyFile  string
+
+	// CAFile is a PEM bundle of the CAs that sign the server's certificate,
+	// for servers whose certificate the system does not trust.
+	CAFile string
+
+	// ServerName overrides the name the server's certificate is checked
+	// against, which is otherwise the host of Address.
+	ServerName string
 }
 
 // GRPCOptions contains gRPC-specific configuration options.
@@ -207,9 +677,22 @@ func NewHTTPClient(address string, opts *ClientOptions) (Client, error) {
 		opts.Timeout = 30 * time.Second
 	}
 
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
 	return &httpClient{
-		address: opts.Address,
-		timeout: opts.Timeout,
+		address:   opts.Address,
+		timeout:   opts.Timeout,
+		apiKey:    opts.APIKey,
+		tlsConfig: tlsConfig,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: &apiKeyTransport{key: opts.APIKey, base: transport},
+		},
 	}, nil
 }
 
@@ -265,6 +748,8 @@ func toProtoFiles(files []File) []*pb.File {
 		result[i] = &pb.File{
 			Name:    f.Name,
 			Content: f.Content,
+			Type:    pb.FileType(f.Type),
+			Mode:    f.Mode,
 		}
 	}
 	return result
@@ -273,34 +758,124 @@ func toProtoFiles(files []File) []*pb.File {
 func toProtoProcesses(processes []Process) []*pb.Process {
 	result := make([]*pb.Process, len(processes))
 	for i, p := range processes {
-		result[i] = &pb.Process{
-			Image:         p.Image,
-			Cmd:           p.Cmd,
-			Stdin:         p.Stdin,
-			MemoryLimitMb: p.MemoryLimitMB,
-			TimeLimitMs:   p.TimeLimitMs,
-			ProcLimit:     p.ProcLimit,
-			Files:         p.Files,
-			Persist:       p.Persist,
-		}
+		result[i] = toProtoProcess(p)
 	}
 	return result
 }
 
+func toProtoProcess(p Process) *pb.Process {
+	proc := &pb.Process{
+		Name:          p.Name,
+		Image:         p.Image,
+		Cmd:           p.Cmd,
+		Stdin:         p.Stdin,
+		MemoryLimitMb: p.MemoryLimitMB,
+		TimeLimitMs:   p.TimeLimitMs,
+		ProcLimit:     p.ProcLimit,
+		Files:         p.Files,
+		Persist:       p.Persist,
+		Outputs:       p.Outputs,
+
+		StdinFile:       p.StdinFile,
+		StdinFrom:       p.StdinFrom,
+		StdoutFile:      p.StdoutFile,
+		StderrFile:      p.StderrFile,
+		FileSizeLimitMb: p.FileSizeLimitMB,
+
+		Env:        p.Env,
+		WorkingDir: p.WorkingDir,
+		User:       p.User,
+	}
+
+	if p.Interactor != nil {
+		proc.Interactor = toProtoProcess(*p.Interactor)
+	}
+
+	if len(p.Services) > 0 {
+		proc.Services = toProtoProcesses(p.Services)
+	}
+
+	if p.Readiness != nil {
+		proc.Readiness = &pb.Readiness{
+			TcpPort:   p.Readiness.TCPPort,
+			TimeoutMs: p.Readiness.TimeoutMs,
+		}
+	}
+
+	return proc
+}
+
 func fromProtoReports(reports []*pb.Report) []Report {
 	result := make([]Report, len(reports))
 	for i, r := range reports {
-		result[i] = Report{
-			Status:   Status(r.Status),
-			ExitCode: r.ExitCode,
-			Signal:   r.Signal,
-			Stdout:   r.Stdout,
-			Stderr:   r.Stderr,
-			CPUTime:  r.CpuTime,
-			Memory:   r.Memory,
-			WallTime: r.WallTime,
-			StartAt:  r.StartAt,
-			FinishAt: r.FinishAt,
+		result[i] = fromProtoReport(r)
+	}
+	return result
+}
+
+func fromProtoReport(r *pb.Report) Report {
+	report := Report{
+		Status:   Status(r.Status),
+		ExitCode: r.ExitCode,
+		Signal:   r.Signal,
+		Stdout:   r.Stdout,
+		Stderr:   r.Stderr,
+		CPUTime:  r.CpuTime,
+		Memory:   r.Memory,
+		WallTime: r.WallTime,
+		StartAt:  r.StartAt,
+		FinishAt: r.FinishAt,
+
+		StdoutTruncated: r.StdoutTruncated,
+		StderrTruncated: r.StderrTruncated,
+
+		Artifacts: fromProtoArtifacts(r.Artifacts),
+		Verdict:   Verdict(r.Verdict),
+	}
+
+	if r.Interactor != nil {
+		interactor := fromProtoReport(r.Interactor)
+		report.Interactor = &interactor
+	}
+
+	if len(r.Services) > 0 {
+		report.Services = fromProtoReports(r.Services)
+	}
+
+	return report
+}
+
+func fromProtoImage(img *pb.Image) Image {
+	image := Image{
+		Name:   img.Name,
+		Digest: img.Digest,
+		Size:   img.Size,
+		InUse:  img.InUse,
+		Layers: img.Layers,
+	}
+	if img.ImportedAt != 0 {
+		image.ImportedAt = time.Unix(0, img.ImportedAt)
+	}
+	if img.Config != nil {
+		image.Config = ImageConfig{
+			User:       img.Config.User,
+			Env:        img.Config.Env,
+			Entrypoint: img.Config.Entrypoint,
+			Cmd:        img.Config.Cmd,
+			WorkingDir: img.Config.WorkingDir,
+		}
+	}
+	return image
+}
+
+func fromProtoArtifacts(artifacts []*pb.Artifact) []Artifact {
+	result := make([]Artifact, len(artifacts))
+	for i, a := range artifacts {
+		result[i] = Artifact{
+			Path:    a.Path,
+			Content: a.Content,
+			Size:    a.Size,
+			Omitted: a.Omitted,
 		}
 	}
 	return result
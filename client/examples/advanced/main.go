@@ -93,10 +93,10 @@ print(f"Factorial of {n} is {result}")
 		}
 
 		// Output
-		if report.Stdout != "" {
+		if len(report.Stdout) > 0 {
 			fmt.Printf("\nStdout:\n%s\n", report.Stdout)
 		}
-		if report.Stderr != "" {
+		if len(report.Stderr) > 0 {
 			fmt.Printf("\nStderr:\n%s\n", report.Stderr)
 		}
 
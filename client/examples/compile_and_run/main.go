@@ -53,10 +53,10 @@ int main() {
 	compileReport := resp.Reports[0]
 	fmt.Printf("Status: %s\n", compileReport.Status)
 	fmt.Printf("Exit Code: %d\n", compileReport.ExitCode)
-	if compileReport.Stdout != "" {
+	if len(compileReport.Stdout) > 0 {
 		fmt.Printf("Stdout: %s\n", compileReport.Stdout)
 	}
-	if compileReport.Stderr != "" {
+	if len(compileReport.Stderr) > 0 {
 		fmt.Printf("Stderr: %s\n", compileReport.Stderr)
 	}
 	fmt.Printf("CPU Time: %d ns\n", compileReport.CPUTime)
@@ -67,7 +67,7 @@ int main() {
 	fmt.Printf("Status: %s\n", runReport.Status)
 	fmt.Printf("Exit Code: %d\n", runReport.ExitCode)
 	fmt.Printf("Stdout: %s\n", runReport.Stdout)
-	if runReport.Stderr != "" {
+	if len(runReport.Stderr) > 0 {
 		fmt.Printf("Stderr: %s\n", runReport.Stderr)
 	}
 	fmt.Printf("CPU Time: %d ns\n", runReport.CPUTime)
@@ -3,19 +3,25 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	pb "github.com/joshjms/castletown/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 // grpcClient implements the Client interface using gRPC.
 type grpcClient struct {
-	conn       *grpc.ClientConn
-	execClient pb.ExecServiceClient
-	doneClient pb.DoneServiceClient
-	timeout    time.Duration
+	conn           *grpc.ClientConn
+	execClient     pb.ExecServiceClient
+	doneClient     pb.DoneServiceClient
+	artifactClient pb.ArtifactServiceClient
+	sessionClient  pb.SessionServiceClient
+	imageClient    pb.ImageServiceClient
+	gcClient       pb.GCServiceClient
+	timeout        time.Duration
 }
 
 // newGRPCClient creates a new gRPC client.
@@ -25,6 +31,16 @@ func newGRPCClient(address string, opts *ClientOptions) (*grpcClient, error) {
 
 	if opts.GRPCOptions.Insecure {
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		tlsConfig, err := opts.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	if opts.APIKey != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(apiKeyCredentials{key: opts.APIKey}))
 	}
 
 	if opts.GRPCOptions.MaxMessageSize > 0 {
@@ -43,10 +59,14 @@ func newGRPCClient(address string, opts *ClientOptions) (*grpcClient, error) {
 	}
 
 	return &grpcClient{
-		conn:       conn,
-		execClient: pb.NewExecServiceClient(conn),
-		doneClient: pb.NewDoneServiceClient(conn),
-		timeout:    opts.Timeout,
+		conn:           conn,
+		execClient:     pb.NewExecServiceClient(conn),
+		doneClient:     pb.NewDoneServiceClient(conn),
+		artifactClient: pb.NewArtifactServiceClient(conn),
+		sessionClient:  pb.NewSessionServiceClient(conn),
+		imageClient:    pb.NewImageServiceClient(conn),
+		gcClient:       pb.NewGCServiceClient(conn),
+		timeout:        opts.Timeout,
 	}, nil
 }
 
@@ -79,6 +99,41 @@ func (c *grpcClient) Execute(ctx context.Context, req *ExecRequest) (*ExecRespon
 	}, nil
 }
 
+// GetArtifact downloads a step output file via gRPC.
+func (c *grpcClient) GetArtifact(ctx context.Context, jobID string, step int, path string) ([]byte, error) {
+	// Set timeout if not already set in context
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// Create request
+	pbReq := &pb.GetArtifactRequest{
+		Id:   jobID,
+		Step: int32(step),
+		Path: path,
+	}
+
+	// Call gRPC method
+	stream, err := c.artifactClient.GetArtifact(ctx, pbReq)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC GetArtifact failed: %w", err)
+	}
+
+	var content []byte
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return content, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gRPC GetArtifact failed: %w", err)
+		}
+		content = append(content, chunk.Data...)
+	}
+}
+
 // Done notifies the server that a job is complete via gRPC.
 func (c *grpcClient) Done(ctx context.Context, jobID string) error {
 	// Set timeout if not already set in context
@@ -102,6 +157,302 @@ func (c *grpcClient) Done(ctx context.Context, jobID string) error {
 	return nil
 }
 
+// ListImages returns the images available on the server via gRPC.
+func (c *grpcClient) ListImages(ctx context.Context) ([]Image, error) {
+	// Set timeout if not already set in context
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	pbResp, err := c.imageClient.ListImages(ctx, &pb.ListImagesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("gRPC ListImages failed: %w", err)
+	}
+
+	images := make([]Image, len(pbResp.Images))
+	for i, img := range pbResp.Images {
+		images[i] = fromProtoImage(img)
+	}
+	return images, nil
+}
+
+// GetImage returns a single image via gRPC.
+func (c *grpcClient) GetImage(ctx context.Context, name string) (*Image, error) {
+	// Set timeout if not already set in context
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	pbResp, err := c.imageClient.GetImage(ctx, &pb.GetImageRequest{Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("gRPC GetImage failed: %w", err)
+	}
+
+	image := fromProtoImage(pbResp.Image)
+	return &image, nil
+}
+
+// DeleteImage removes an image from the server via gRPC.
+func (c *grpcClient) DeleteImage(ctx context.Context, name string) error {
+	// Set timeout if not already set in context
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	if _, err := c.imageClient.DeleteImage(ctx, &pb.DeleteImageRequest{Name: name}); err != nil {
+		return fmt.Errorf("gRPC DeleteImage failed: %w", err)
+	}
+	return nil
+}
+
+// BuildImage builds an image via gRPC.
+func (c *grpcClient) BuildImage(ctx context.Context, req *BuildImageRequest) (*BuildImageResponse, error) {
+	// Set timeout if not already set in context
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	pbReq := &pb.BuildImageRequest{
+		Name:  req.Name,
+		Base:  req.Base,
+		Files: toProtoFiles(req.Files),
+		Procs: toProtoProcesses(req.Steps),
+	}
+
+	pbResp, err := c.imageClient.BuildImage(ctx, pbReq)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC BuildImage failed: %w", err)
+	}
+
+	resp := &BuildImageResponse{
+		ID:      pbResp.Id,
+		Error:   pbResp.Error,
+		Reports: fromProtoReports(pbResp.Reports),
+	}
+	if pbResp.Image != nil {
+		image := fromProtoImage(pbResp.Image)
+		resp.Image = &image
+	}
+	return resp, nil
+}
+
+// ExecuteStream submits a job for execution via gRPC, streaming its events.
+func (c *grpcClient) ExecuteStream(ctx context.Context, req *ExecRequest) (ExecStream, error) {
+	// Set timeout if not already set in context
+	var cancel context.CancelFunc
+	if _, hasDeadline := ctx.Deadline(); hasDeadline {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+
+	pbReq := &pb.ExecRequest{
+		Id:    req.ID,
+		Files: toProtoFiles(req.Files),
+		Procs: toProtoProcesses(req.Steps),
+	}
+
+	stream, err := c.execClient.ExecuteStream(ctx, pbReq)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("gRPC ExecuteStream failed: %w", err)
+	}
+
+	return &grpcExecStream{stream: stream, cancel: cancel}, nil
+}
+
+// grpcExecStream implements ExecStream over a server-streaming gRPC call.
+type grpcExecStream struct {
+	stream pb.ExecService_ExecuteStreamClient
+	cancel context.CancelFunc
+}
+
+func (s *grpcExecStream) Recv() (*ExecEvent, error) {
+	pbEvent, err := s.stream.Recv()
+	if err == io.EOF {
+		s.cancel()
+		return nil, io.EOF
+	}
+	if err != nil {
+		s.cancel()
+		return nil, fmt.Errorf("gRPC ExecuteStream failed: %w", err)
+	}
+
+	event := &ExecEvent{
+		Type:  ExecEventType(pbEvent.Type),
+		JobID: pbEvent.Id,
+		Step:  int(pbEvent.Step),
+		Steps: int(pbEvent.Steps),
+		Error: pbEvent.Error,
+		Time:  time.Unix(0, pbEvent.Time),
+	}
+	if pbEvent.Report != nil {
+		report := fromProtoReport(pbEvent.Report)
+		event.Report = &report
+	}
+	if pbEvent.Output != nil {
+		event.Output = &OutputChunk{
+			Stream:  pbEvent.Output.Stream,
+			Data:    pbEvent.Output.Data,
+			Dropped: pbEvent.Output.Dropped,
+		}
+	}
+	return event, nil
+}
+
+func (s *grpcExecStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+// CollectGarbage runs a garbage collection via gRPC.
+func (c *grpcClient) CollectGarbage(ctx context.Context, dryRun bool) (*GCResult, error) {
+	// Set timeout if not already set in context
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	pbResp, err := c.gcClient.Collect(ctx, &pb.CollectRequest{DryRun: dryRun})
+	if err != nil {
+		return nil, fmt.Errorf("gRPC Collect failed: %w", err)
+	}
+
+	return &GCResult{
+		Layers:   pbResp.Layers,
+		Overlays: pbResp.Overlays,
+		Storage:  pbResp.Storage,
+		Freed:    pbResp.Freed,
+	}, nil
+}
+
+// DiskUsage reports the server's disk usage via gRPC.
+func (c *grpcClient) DiskUsage(ctx context.Context) (*DiskUsage, error) {
+	// Set timeout if not already set in context
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	pbResp, err := c.gcClient.DiskUsage(ctx, &pb.DiskUsageRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("gRPC DiskUsage failed: %w", err)
+	}
+
+	return &DiskUsage{
+		Images:      pbResp.Images,
+		Layers:      pbResp.Layers,
+		Overlays:    pbResp.Overlays,
+		Storage:     pbResp.Storage,
+		Reclaimable: pbResp.Reclaimable,
+	}, nil
+}
+
+// OpenSession starts a session over a bidirectional gRPC stream.
+func (c *grpcClient) OpenSession(ctx context.Context, req *SessionRequest) (Session, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	stream, err := c.sessionClient.Session(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("gRPC Session failed: %w", err)
+	}
+
+	err = stream.Send(&pb.SessionRequest{
+		Msg: &pb.SessionRequest_Start{Start: &pb.SessionStart{
+			Files:         toProtoFiles(req.Files),
+			Process:       toProtoProcess(req.Process),
+			IdleTimeoutMs: req.IdleTimeoutMs,
+			Terminal:      toProtoTerminalSize(req.Terminal),
+		}},
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("gRPC Session failed: %w", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("gRPC Session failed: %w", err)
+	}
+
+	return &grpcSession{
+		id:     resp.GetId(),
+		stream: stream,
+		cancel: cancel,
+	}, nil
+}
+
+// grpcSession implements Session on top of a gRPC stream.
+type grpcSession struct {
+	id     string
+	stream grpc.BidiStreamingClient[pb.SessionRequest, pb.SessionResponse]
+	cancel context.CancelFunc
+}
+
+func (s *grpcSession) ID() string {
+	return s.id
+}
+
+func (s *grpcSession) Write(p []byte) (int, error) {
+	if err := s.stream.Send(&pb.SessionRequest{Msg: &pb.SessionRequest_Stdin{Stdin: p}}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *grpcSession) CloseStdin() error {
+	return s.stream.Send(&pb.SessionRequest{Msg: &pb.SessionRequest_CloseStdin{CloseStdin: true}})
+}
+
+func (s *grpcSession) Resize(cols, rows uint16) error {
+	return s.stream.Send(&pb.SessionRequest{Msg: &pb.SessionRequest_Resize{
+		Resize: toProtoTerminalSize(&TerminalSize{Cols: cols, Rows: rows}),
+	}})
+}
+
+func (s *grpcSession) Recv() (*SessionEvent, error) {
+	resp, err := s.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	switch msg := resp.Msg.(type) {
+	case *pb.SessionResponse_Stdout:
+		return &SessionEvent{Stdout: msg.Stdout}, nil
+	case *pb.SessionResponse_Stderr:
+		return &SessionEvent{Stderr: msg.Stderr}, nil
+	case *pb.SessionResponse_Exit:
+		report := fromProtoReport(msg.Exit)
+		return &SessionEvent{Exit: &report}, nil
+	default:
+		return nil, fmt.Errorf("unexpected session message %T", resp.Msg)
+	}
+}
+
+func (s *grpcSession) Close() error {
+	s.cancel()
+	return nil
+}
+
+func toProtoTerminalSize(t *TerminalSize) *pb.TerminalSize {
+	if t == nil {
+		return nil
+	}
+	return &pb.TerminalSize{Cols: uint32(t.Cols), Rows: uint32(t.Rows)}
+}
+
 // Close closes the gRPC connection.
 func (c *grpcClient) Close() error {
 	if c.conn != nil {
@@ -1,23 +1,33 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
 )
 
 // httpClient implements the Client interface using HTTP REST API.
 type httpClient struct {
-	address string
-	timeout time.Duration
-	client  *http.Client
+	address   string
+	timeout   time.Duration
+	client    *http.Client
+	apiKey    string
+	tlsConfig *tls.Config
 }
 
-// httpExecRequest is the HTTP JSON request format for /exec endpoint.
+// httpExecRequest is the HTTP JSON request format for /v1/exec endpoint.
 type httpExecRequest struct {
 	ID    string        `json:"id,omitempty"`
 	Files []httpFile    `json:"files"`
@@ -25,13 +35,17 @@ type httpExecRequest struct {
 }
 
 // httpFile is the HTTP JSON format for a file.
+// Content is base64-encoded on the wire.
 type httpFile struct {
 	Name    string `json:"name"`
-	Content string `json:"content"`
+	Content []byte `json:"content"`
+	Type    string `json:"type,omitempty"`
+	Mode    uint32 `json:"mode,omitempty"`
 }
 
 // httpProcess is the HTTP JSON format for a process.
 type httpProcess struct {
+	Name          string   `json:"name,omitempty"`
 	Image         string   `json:"image"`
 	Cmd           []string `json:"cmd"`
 	Stdin         string   `json:"stdin,omitempty"`
@@ -40,58 +54,90 @@ type httpProcess struct {
 	ProcLimit     int64    `json:"procLimit,omitempty"`
 	Files         []string `json:"files,omitempty"`
 	Persist       []string `json:"persist,omitempty"`
+	Outputs       []string `json:"outputs,omitempty"`
+
+	StdinFile       string `json:"stdinFile,omitempty"`
+	StdinFrom       string `json:"stdinFrom,omitempty"`
+	StdoutFile      string `json:"stdoutFile,omitempty"`
+	StderrFile      string `json:"stderrFile,omitempty"`
+	FileSizeLimitMB int64  `json:"fileSizeLimitMB,omitempty"`
+
+	Env        []string `json:"env,omitempty"`
+	WorkingDir string   `json:"workingDir,omitempty"`
+	User       string   `json:"user,omitempty"`
+
+	Interactor *httpProcess   `json:"interactor,omitempty"`
+	Services   []httpProcess  `json:"services,omitempty"`
+	Readiness  *httpReadiness `json:"readiness,omitempty"`
+}
+
+type httpReadiness struct {
+	TCPPort   int32 `json:"tcpPort"`
+	TimeoutMs int64 `json:"timeoutMs"`
 }
 
-// httpExecResponse is the HTTP JSON response format for /exec endpoint.
+// httpExecResponse is the HTTP JSON response format for /v1/exec endpoint.
 type httpExecResponse struct {
 	ID      string       `json:"id"`
 	Reports []httpReport `json:"reports"`
 }
 
 // httpReport is the HTTP JSON format for a report.
+// Stdout and Stderr are base64-encoded on the wire.
 type httpReport struct {
-	Status   string `json:"Status"`
-	ExitCode int32  `json:"ExitCode"`
-	Signal   int32  `json:"Signal"`
-	Stdout   string `json:"Stdout"`
-	Stderr   string `json:"Stderr"`
-	CPUTime  uint64 `json:"CPUTime"`
-	Memory   uint64 `json:"Memory"`
-	WallTime int64  `json:"WallTime"`
-	StartAt  int64  `json:"StartAt"`
-	FinishAt int64  `json:"FinishAt"`
-}
-
-// httpDoneRequest is the HTTP JSON request format for /done endpoint.
+	Status      string    `json:"status"`
+	ExitCode    int32     `json:"exitCode"`
+	Signal      int32     `json:"signal"`
+	Stdout      []byte    `json:"stdout"`
+	Stderr      []byte    `json:"stderr"`
+	CPUTimeUs   uint64    `json:"cpuTimeUs"`
+	MemoryBytes uint64    `json:"memoryBytes"`
+	WallTimeMs  int64     `json:"wallTimeMs"`
+	StartAt     time.Time `json:"startAt"`
+	FinishAt    time.Time `json:"finishAt"`
+
+	StdoutTruncated bool `json:"stdoutTruncated"`
+	StderrTruncated bool `json:"stderrTruncated"`
+
+	Artifacts []httpArtifact `json:"artifacts"`
+
+	Interactor *httpReport  `json:"interactor"`
+	Verdict    string       `json:"verdict"`
+	Services   []httpReport `json:"services"`
+}
+
+// httpArtifact is the HTTP JSON format for an artifact.
+// Content is base64-encoded on the wire.
+type httpArtifact struct {
+	Path    string `json:"path"`
+	Content []byte `json:"content"`
+	Size    int64  `json:"size"`
+	Omitted bool   `json:"omitted"`
+}
+
+// httpErrorResponse is the body of every failed HTTP response.
+type httpErrorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// httpDoneRequest is the HTTP JSON request format for /v1/done endpoint.
 type httpDoneRequest struct {
 	ID string `json:"id"`
 }
 
 // Execute submits a job for execution via HTTP REST API.
 func (c *httpClient) Execute(ctx context.Context, req *ExecRequest) (*ExecResponse, error) {
-	// Convert to HTTP format
-	httpReq := httpExecRequest{
-		ID:    req.ID,
-		Files: make([]httpFile, len(req.Files)),
-		Steps: make([]httpProcess, len(req.Steps)),
-	}
-
-	for i, f := range req.Files {
-		httpReq.Files[i] = httpFile(f)
-	}
-
-	for i, p := range req.Steps {
-		httpReq.Steps[i] = httpProcess(p)
-	}
-
 	// Marshal to JSON
-	body, err := json.Marshal(httpReq)
+	body, err := json.Marshal(toHTTPExecRequest(req))
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
-	httpRequest, err := http.NewRequestWithContext(ctx, "POST", c.address+"/exec", bytes.NewReader(body))
+	httpRequest, err := http.NewRequestWithContext(ctx, "POST", c.address+"/v1/exec", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -112,8 +158,7 @@ func (c *httpClient) Execute(ctx context.Context, req *ExecRequest) (*ExecRespon
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, responseError(resp)
 	}
 
 	// Parse response
@@ -129,23 +174,75 @@ func (c *httpClient) Execute(ctx context.Context, req *ExecRequest) (*ExecRespon
 	}
 
 	for i, r := range httpResp.Reports {
-		response.Reports[i] = Report{
-			Status:   parseStatus(r.Status),
-			ExitCode: r.ExitCode,
-			Signal:   r.Signal,
-			Stdout:   r.Stdout,
-			Stderr:   r.Stderr,
-			CPUTime:  r.CPUTime,
-			Memory:   r.Memory,
-			WallTime: r.WallTime,
-			StartAt:  r.StartAt,
-			FinishAt: r.FinishAt,
-		}
+		response.Reports[i] = fromHTTPReport(r)
 	}
 
 	return response, nil
 }
 
+// toHTTPExecRequest converts req to the HTTP format.
+func toHTTPExecRequest(req *ExecRequest) httpExecRequest {
+	httpReq := httpExecRequest{
+		ID:    req.ID,
+		Files: make([]httpFile, len(req.Files)),
+		Steps: make([]httpProcess, len(req.Steps)),
+	}
+
+	for i, f := range req.Files {
+		httpReq.Files[i] = httpFile{
+			Name:    f.Name,
+			Content: f.Content,
+			Type:    f.Type.String(),
+			Mode:    f.Mode,
+		}
+	}
+
+	for i, p := range req.Steps {
+		httpReq.Steps[i] = toHTTPProcess(p)
+	}
+
+	return httpReq
+}
+
+// GetArtifact downloads a step output file via HTTP REST API.
+func (c *httpClient) GetArtifact(ctx context.Context, jobID string, step int, path string) ([]byte, error) {
+	query := url.Values{}
+	query.Set("id", jobID)
+	query.Set("step", strconv.Itoa(step))
+	query.Set("path", path)
+
+	// Create HTTP request
+	httpRequest, err := http.NewRequestWithContext(ctx, "GET", c.address+"/v1/artifact?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	// Send request
+	if c.client == nil {
+		c.client = &http.Client{
+			Timeout: c.timeout,
+		}
+	}
+
+	resp, err := c.client.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+
+	return content, nil
+}
+
 // Done notifies the server that a job is complete via HTTP REST API.
 func (c *httpClient) Done(ctx context.Context, jobID string) error {
 	// Create request
@@ -159,7 +256,7 @@ func (c *httpClient) Done(ctx context.Context, jobID string) error {
 	}
 
 	// Create HTTP request
-	httpRequest, err := http.NewRequestWithContext(ctx, "POST", c.address+"/done", bytes.NewReader(body))
+	httpRequest, err := http.NewRequestWithContext(ctx, "POST", c.address+"/v1/done", bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -179,19 +276,741 @@ func (c *httpClient) Done(ctx context.Context, jobID string) error {
 	defer resp.Body.Close()
 
 	// Check status code
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// httpImage is the HTTP JSON format for an image.
+type httpImage struct {
+	Name       string          `json:"name"`
+	Digest     string          `json:"digest"`
+	Size       int64           `json:"size"`
+	ImportedAt time.Time       `json:"importedAt"`
+	InUse      bool            `json:"inUse"`
+	Layers     []string        `json:"layers"`
+	Config     httpImageConfig `json:"config"`
+}
+
+type httpImageConfig struct {
+	User       string   `json:"user"`
+	Env        []string `json:"env"`
+	Entrypoint []string `json:"entrypoint"`
+	Cmd        []string `json:"cmd"`
+	WorkingDir string   `json:"workingDir"`
+}
+
+// httpListImagesResponse is the HTTP JSON response format for /v1/images.
+type httpListImagesResponse struct {
+	Images []httpImage `json:"images"`
+}
+
+// ListImages returns the images available on the server via HTTP REST API.
+func (c *httpClient) ListImages(ctx context.Context) ([]Image, error) {
+	var httpResp httpListImagesResponse
+	if err := c.doImageRequest(ctx, "GET", "/v1/images", &httpResp); err != nil {
+		return nil, err
+	}
+
+	images := make([]Image, len(httpResp.Images))
+	for i, img := range httpResp.Images {
+		images[i] = fromHTTPImage(img)
+	}
+	return images, nil
+}
+
+// GetImage returns a single image via HTTP REST API.
+func (c *httpClient) GetImage(ctx context.Context, name string) (*Image, error) {
+	var httpResp httpImage
+	if err := c.doImageRequest(ctx, "GET", "/v1/images/"+url.PathEscape(name), &httpResp); err != nil {
+		return nil, err
+	}
+
+	image := fromHTTPImage(httpResp)
+	return &image, nil
+}
+
+// DeleteImage removes an image from the server via HTTP REST API.
+func (c *httpClient) DeleteImage(ctx context.Context, name string) error {
+	return c.doImageRequest(ctx, "DELETE", "/v1/images/"+url.PathEscape(name), nil)
+}
+
+// httpBuildImageRequest is the HTTP JSON request format for POST /images/{name}.
+type httpBuildImageRequest struct {
+	Base  string        `json:"base"`
+	Files []httpFile    `json:"files"`
+	Steps []httpProcess `json:"steps"`
+}
+
+// httpBuildImageResponse is the HTTP JSON response format for POST /images/{name}.
+type httpBuildImageResponse struct {
+	ID      string       `json:"id"`
+	Image   *httpImage   `json:"image"`
+	Error   string       `json:"error"`
+	Reports []httpReport `json:"reports"`
+}
+
+// BuildImage builds an image via HTTP REST API.
+func (c *httpClient) BuildImage(ctx context.Context, req *BuildImageRequest) (*BuildImageResponse, error) {
+	// Convert to HTTP format
+	httpReq := httpBuildImageRequest{
+		Base:  req.Base,
+		Files: make([]httpFile, len(req.Files)),
+		Steps: make([]httpProcess, len(req.Steps)),
+	}
+
+	for i, f := range req.Files {
+		httpReq.Files[i] = httpFile{
+			Name:    f.Name,
+			Content: f.Content,
+			Type:    f.Type.String(),
+			Mode:    f.Mode,
+		}
+	}
+
+	for i, p := range req.Steps {
+		httpReq.Steps[i] = toHTTPProcess(p)
+	}
+
+	body, err := json.Marshal(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, "POST", c.address+"/v1/images/"+url.PathEscape(req.Name), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	if c.client == nil {
+		c.client = &http.Client{
+			Timeout: c.timeout,
+		}
+	}
+
+	resp, err := c.client.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// A failed step still comes with the reports of the steps that ran.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnprocessableEntity {
+		return nil, responseError(resp)
+	}
+
+	var httpResp httpBuildImageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&httpResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	response := &BuildImageResponse{
+		ID:      httpResp.ID,
+		Error:   httpResp.Error,
+		Reports: make([]Report, len(httpResp.Reports)),
+	}
+	for i, r := range httpResp.Reports {
+		response.Reports[i] = fromHTTPReport(r)
+	}
+	if httpResp.Image != nil {
+		image := fromHTTPImage(*httpResp.Image)
+		response.Image = &image
+	}
+
+	return response, nil
+}
+
+// httpExecEvent is the HTTP JSON format of an event on /jobs/{id}/events.
+type httpExecEvent struct {
+	Type   string           `json:"type"`
+	JobID  string           `json:"jobId"`
+	Step   int              `json:"step"`
+	Steps  int              `json:"steps"`
+	Report *httpReport      `json:"report"`
+	Output *httpOutputChunk `json:"output"`
+	Error  string           `json:"error"`
+	Time   time.Time        `json:"time"`
+}
+
+// httpOutputChunk is the HTTP JSON format of an output chunk.
+// Data is base64-encoded on the wire.
+type httpOutputChunk struct {
+	Stream  string `json:"stream"`
+	Data    []byte `json:"data"`
+	Dropped int64  `json:"dropped"`
+}
+
+// ExecuteStream submits a job via HTTP REST API, following its events as
+// Server-Sent Events from /jobs/{id}/events. A job without an ID gets one
+// from the client, since the events are subscribed to before it is
+// submitted.
+func (c *httpClient) ExecuteStream(ctx context.Context, req *ExecRequest) (ExecStream, error) {
+	httpReq := toHTTPExecRequest(req)
+	if httpReq.ID == "" {
+		httpReq.ID = uuid.NewString()
+	}
+
+	body, err := json.Marshal(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Set timeout if not already set in context
+	var cancel context.CancelFunc
+	if _, hasDeadline := ctx.Deadline(); hasDeadline {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+
+	// Both requests last as long as the job, which ctx bounds; the client's
+	// own timeout would also cut the event stream short.
+	client := &http.Client{}
+	if c.client != nil {
+		*client = *c.client
+		client.Timeout = 0
+	}
+
+	query := url.Values{}
+	query.Set("output", "true")
+	query.Set("replay", "false")
+	eventsRequest, err := http.NewRequestWithContext(ctx, "GET", c.address+"/v1/jobs/"+url.PathEscape(httpReq.ID)+"/events?"+query.Encode(), nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	eventsRequest.Header.Set("Accept", "text/event-stream")
+
+	// The server subscribes before answering, so no event of the job is
+	// missed.
+	resp, err := client.Do(eventsRequest)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+		defer resp.Body.Close()
+		cancel()
+		return nil, responseError(resp)
+	}
+
+	s := &httpExecStream{
+		body:      resp.Body,
+		reader:    bufio.NewReader(resp.Body),
+		cancel:    cancel,
+		submitted: make(chan struct{}),
 	}
 
+	go func() {
+		defer close(s.submitted)
+
+		httpRequest, err := http.NewRequestWithContext(ctx, "POST", c.address+"/v1/exec", bytes.NewReader(body))
+		if err != nil {
+			s.submitErr = fmt.Errorf("failed to create HTTP request: %w", err)
+			cancel()
+			return
+		}
+		httpRequest.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(httpRequest)
+		if err != nil {
+			s.submitErr = fmt.Errorf("failed to send HTTP request: %w", err)
+			cancel()
+			return
+		}
+		defer resp.Body.Close()
+
+		// A job that fails once running still ends with a job done event.
+		if resp.StatusCode != http.StatusOK {
+			s.submitErr = responseError(resp)
+			cancel()
+		}
+	}()
+
+	return s, nil
+}
+
+// httpExecStream implements ExecStream over Server-Sent Events, while the
+// job itself is submitted with a separate request.
+type httpExecStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	cancel context.CancelFunc
+	done   bool
+
+	// submitErr is set before submitted is closed.
+	submitted chan struct{}
+	submitErr error
+}
+
+func (s *httpExecStream) Recv() (*ExecEvent, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	for {
+		name, data, err := readServerSentEvent(s.reader)
+		if err != nil {
+			select {
+			case <-s.submitted:
+				if s.submitErr != nil {
+					err = s.submitErr
+				}
+			default:
+			}
+			s.Close()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed to read event: %w", err)
+		}
+		if name == "" {
+			continue
+		}
+
+		var httpEvent httpExecEvent
+		if err := json.Unmarshal(data, &httpEvent); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+
+		event := fromHTTPExecEvent(httpEvent)
+		if event.Type == ExecEventJobDone {
+			s.done = true
+			<-s.submitted
+			s.Close()
+		}
+		return event, nil
+	}
+}
+
+func (s *httpExecStream) Close() error {
+	s.cancel()
+	return s.body.Close()
+}
+
+// readServerSentEvent reads the next event from r and returns its name and
+// data. Comments are skipped; an event without a name has an empty one.
+func readServerSentEvent(r *bufio.Reader) (string, []byte, error) {
+	var (
+		name string
+		data [][]byte
+	)
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) == 0 {
+			if name != "" || data != nil {
+				return name, bytes.Join(data, []byte("\n")), nil
+			}
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			name = string(value)
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
+
+func fromHTTPExecEvent(e httpExecEvent) *ExecEvent {
+	event := &ExecEvent{
+		Type:  fromHTTPExecEventType(e.Type),
+		JobID: e.JobID,
+		Step:  e.Step,
+		Steps: e.Steps,
+		Error: e.Error,
+		Time:  e.Time,
+	}
+	if e.Report != nil {
+		report := fromHTTPReport(*e.Report)
+		event.Report = &report
+	}
+	if e.Output != nil {
+		event.Output = &OutputChunk{
+			Stream:  e.Output.Stream,
+			Data:    e.Output.Data,
+			Dropped: e.Output.Dropped,
+		}
+	}
+	return event
+}
+
+func fromHTTPExecEventType(t string) ExecEventType {
+	switch t {
+	case "jobAccepted":
+		return ExecEventJobAccepted
+	case "stepStarted":
+		return ExecEventStepStarted
+	case "stepFinished":
+		return ExecEventStepFinished
+	case "jobDone":
+		return ExecEventJobDone
+	case "output":
+		return ExecEventOutput
+	default:
+		return ExecEventUnspecified
+	}
+}
+
+// httpCollectRequest is the HTTP JSON request format for POST /gc.
+type httpCollectRequest struct {
+	DryRun bool `json:"dryRun"`
+}
+
+// httpCollectResponse is the HTTP JSON response format for POST /gc.
+type httpCollectResponse struct {
+	Layers   []string `json:"layers"`
+	Overlays []string `json:"overlays"`
+	Storage  []string `json:"storage"`
+	Freed    int64    `json:"freed"`
+}
+
+// httpDiskUsageResponse is the HTTP JSON response format for /disk-usage.
+type httpDiskUsageResponse struct {
+	Images      int64 `json:"images"`
+	Layers      int64 `json:"layers"`
+	Overlays    int64 `json:"overlays"`
+	Storage     int64 `json:"storage"`
+	Reclaimable int64 `json:"reclaimable"`
+}
+
+// CollectGarbage runs a garbage collection via HTTP REST API.
+func (c *httpClient) CollectGarbage(ctx context.Context, dryRun bool) (*GCResult, error) {
+	var httpResp httpCollectResponse
+	if err := c.doJSONRequest(ctx, "POST", "/v1/gc", httpCollectRequest{DryRun: dryRun}, &httpResp); err != nil {
+		return nil, err
+	}
+
+	return &GCResult{
+		Layers:   httpResp.Layers,
+		Overlays: httpResp.Overlays,
+		Storage:  httpResp.Storage,
+		Freed:    httpResp.Freed,
+	}, nil
+}
+
+// DiskUsage reports the server's disk usage via HTTP REST API.
+func (c *httpClient) DiskUsage(ctx context.Context) (*DiskUsage, error) {
+	var httpResp httpDiskUsageResponse
+	if err := c.doJSONRequest(ctx, "GET", "/v1/disk-usage", nil, &httpResp); err != nil {
+		return nil, err
+	}
+
+	usage := DiskUsage(httpResp)
+	return &usage, nil
+}
+
+// doImageRequest sends a bodyless request to an image endpoint and decodes
+// the response into out unless it is nil.
+func (c *httpClient) doImageRequest(ctx context.Context, method, path string, out any) error {
+	return c.doJSONRequest(ctx, method, path, nil, out)
+}
+
+// doJSONRequest sends in, unless it is nil, as the JSON body of a request
+// and decodes the response into out unless it is nil.
+func (c *httpClient) doJSONRequest(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	// Create HTTP request
+	httpRequest, err := http.NewRequestWithContext(ctx, method, c.address+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	if in != nil {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+
+	// Send request
+	if c.client == nil {
+		c.client = &http.Client{
+			Timeout: c.timeout,
+		}
+	}
+
+	resp, err := c.client.Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check status code
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
 	return nil
 }
 
+// responseError reads the error of a failed response, falling back to its
+// body when it holds no error object.
+func responseError(resp *http.Response) error {
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	var httpErr httpErrorResponse
+	if err := json.Unmarshal(bodyBytes, &httpErr); err == nil && httpErr.Error.Message != "" {
+		return fmt.Errorf("HTTP request failed with status %d: %s: %s", resp.StatusCode, httpErr.Error.Code, httpErr.Error.Message)
+	}
+	return fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+}
+
+func fromHTTPImage(img httpImage) Image {
+	return Image{
+		Name:       img.Name,
+		Digest:     img.Digest,
+		Size:       img.Size,
+		ImportedAt: img.ImportedAt,
+		InUse:      img.InUse,
+		Layers:     img.Layers,
+		Config:     ImageConfig(img.Config),
+	}
+}
+
+// httpSessionStart is the first WebSocket message of a session.
+type httpSessionStart struct {
+	Files         []httpFile  `json:"files"`
+	Process       httpProcess `json:"process"`
+	IdleTimeoutMs int64       `json:"idleTimeoutMs,omitempty"`
+
+	Terminal *httpTerminalSize `json:"terminal,omitempty"`
+}
+
+type httpTerminalSize struct {
+	Cols uint16 `json:"cols"`
+	Rows uint16 `json:"rows"`
+}
+
+// httpSessionInput is sent on a session after the start message.
+type httpSessionInput struct {
+	Stdin      []byte `json:"stdin,omitempty"`
+	CloseStdin bool   `json:"closeStdin,omitempty"`
+
+	Resize *httpTerminalSize `json:"resize,omitempty"`
+}
+
+// httpSessionEvent is received on a session.
+type httpSessionEvent struct {
+	ID     string      `json:"id"`
+	Stdout []byte      `json:"stdout"`
+	Stderr []byte      `json:"stderr"`
+	Exit   *httpReport `json:"exit"`
+	Error  string      `json:"error"`
+}
+
+// OpenSession starts a session over a WebSocket connection to /session.
+func (c *httpClient) OpenSession(ctx context.Context, req *SessionRequest) (Session, error) {
+	location := "ws" + strings.TrimPrefix(c.address, "http") + "/v1/session"
+	wsConfig, err := websocket.NewConfig(location, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session URL: %w", err)
+	}
+
+	wsConfig.TlsConfig = c.tlsConfig
+	if c.apiKey != "" {
+		wsConfig.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	ws, err := wsConfig.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session: %w", err)
+	}
+
+	start := httpSessionStart{
+		Files:         make([]httpFile, len(req.Files)),
+		Process:       toHTTPProcess(req.Process),
+		IdleTimeoutMs: req.IdleTimeoutMs,
+	}
+	if req.Terminal != nil {
+		terminal := httpTerminalSize(*req.Terminal)
+		start.Terminal = &terminal
+	}
+	for i, f := range req.Files {
+		start.Files[i] = httpFile{
+			Name:    f.Name,
+			Content: f.Content,
+			Type:    f.Type.String(),
+			Mode:    f.Mode,
+		}
+	}
+
+	if err := websocket.JSON.Send(ws, start); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+
+	var event httpSessionEvent
+	if err := websocket.JSON.Receive(ws, &event); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+	if event.Error != "" {
+		ws.Close()
+		return nil, fmt.Errorf("failed to start session: %s", event.Error)
+	}
+
+	// Closing the connection is how the server learns that the client has
+	// gone away, so it has to follow ctx.
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+
+	return &httpSession{id: event.ID, ws: ws, stop: stop}, nil
+}
+
+// httpSession implements Session on top of a WebSocket connection.
+type httpSession struct {
+	id   string
+	ws   *websocket.Conn
+	stop func() bool
+}
+
+func (s *httpSession) ID() string {
+	return s.id
+}
+
+func (s *httpSession) Write(p []byte) (int, error) {
+	if err := websocket.JSON.Send(s.ws, httpSessionInput{Stdin: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *httpSession) CloseStdin() error {
+	return websocket.JSON.Send(s.ws, httpSessionInput{CloseStdin: true})
+}
+
+func (s *httpSession) Resize(cols, rows uint16) error {
+	return websocket.JSON.Send(s.ws, httpSessionInput{Resize: &httpTerminalSize{Cols: cols, Rows: rows}})
+}
+
+func (s *httpSession) Recv() (*SessionEvent, error) {
+	var event httpSessionEvent
+	if err := websocket.JSON.Receive(s.ws, &event); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case event.Error != "":
+		return nil, fmt.Errorf("session failed: %s", event.Error)
+	case event.Exit != nil:
+		report := fromHTTPReport(*event.Exit)
+		return &SessionEvent{Exit: &report}, nil
+	case event.Stderr != nil:
+		return &SessionEvent{Stderr: event.Stderr}, nil
+	default:
+		return &SessionEvent{Stdout: event.Stdout}, nil
+	}
+}
+
+func (s *httpSession) Close() error {
+	s.stop()
+	return s.ws.Close()
+}
+
 // Close closes the HTTP client (no-op for HTTP).
 func (c *httpClient) Close() error {
 	return nil
 }
 
+func toHTTPProcess(p Process) httpProcess {
+	proc := httpProcess{
+		Name:          p.Name,
+		Image:         p.Image,
+		Cmd:           p.Cmd,
+		Stdin:         p.Stdin,
+		MemoryLimitMB: p.MemoryLimitMB,
+		TimeLimitMs:   p.TimeLimitMs,
+		ProcLimit:     p.ProcLimit,
+		Files:         p.Files,
+		Persist:       p.Persist,
+		Outputs:       p.Outputs,
+
+		StdinFile:       p.StdinFile,
+		StdinFrom:       p.StdinFrom,
+		StdoutFile:      p.StdoutFile,
+		StderrFile:      p.StderrFile,
+		FileSizeLimitMB: p.FileSizeLimitMB,
+
+		Env:        p.Env,
+		WorkingDir: p.WorkingDir,
+		User:       p.User,
+	}
+
+	if p.Interactor != nil {
+		interactor := toHTTPProcess(*p.Interactor)
+		proc.Interactor = &interactor
+	}
+
+	for _, svc := range p.Services {
+		proc.Services = append(proc.Services, toHTTPProcess(svc))
+	}
+
+	if p.Readiness != nil {
+		readiness := httpReadiness(*p.Readiness)
+		proc.Readiness = &readiness
+	}
+
+	return proc
+}
+
+func fromHTTPReport(r httpReport) Report {
+	report := Report{
+		Status:   parseStatus(r.Status),
+		ExitCode: r.ExitCode,
+		Signal:   r.Signal,
+		Stdout:   r.Stdout,
+		Stderr:   r.Stderr,
+		CPUTime:  r.CPUTimeUs,
+		Memory:   r.MemoryBytes,
+		WallTime: r.WallTimeMs,
+		StartAt:  r.StartAt.UnixNano(),
+		FinishAt: r.FinishAt.UnixNano(),
+		Verdict:  parseVerdict(r.Verdict),
+
+		StdoutTruncated: r.StdoutTruncated,
+		StderrTruncated: r.StderrTruncated,
+	}
+
+	for _, a := range r.Artifacts {
+		report.Artifacts = append(report.Artifacts, Artifact(a))
+	}
+
+	if r.Interactor != nil {
+		interactor := fromHTTPReport(*r.Interactor)
+		report.Interactor = &interactor
+	}
+
+	for _, svc := range r.Services {
+		report.Services = append(report.Services, fromHTTPReport(svc))
+	}
+
+	return report
+}
+
 // parseStatus converts a string status to Status enum.
 func parseStatus(s string) Status {
 	switch s {
@@ -215,3 +1034,27 @@ func parseStatus(s string) Status {
 		return StatusUnspecified
 	}
 }
+
+// parseVerdict converts a string verdict to Verdict enum.
+func parseVerdict(s string) Verdict {
+	switch s {
+	case "ACCEPTED":
+		return VerdictAccepted
+	case "WRONG_ANSWER":
+		return VerdictWrongAnswer
+	case "PRESENTATION_ERROR":
+		return VerdictPresentationError
+	case "TIME_LIMIT_EXCEEDED":
+		return VerdictTimeLimitExceeded
+	case "MEMORY_LIMIT_EXCEEDED":
+		return VerdictMemoryLimitExceeded
+	case "OUTPUT_LIMIT_EXCEEDED":
+		return VerdictOutputLimitExceeded
+	case "RUNTIME_ERROR":
+		return VerdictRuntimeError
+	case "JUDGE_ERROR":
+		return VerdictJudgeError
+	default:
+		return VerdictUnspecified
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// gcCmd asks a running server to remove data nothing uses any more
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove unused layers, overlays and job storage",
+	Long: `Ask a running castletown server to remove the image layers no image refers
+to, the overlay directories of sandboxes and builds that are gone and the
+files of jobs that are gone, then show how the server's disk space is used.
+
+Anything changed within the server's --gc-min-age is kept. The server also
+collects garbage on its own every --gc-interval.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		c := newImageClient(cmd)
+		defer c.Close()
+
+		res, err := c.CollectGarbage(cmd.Context(), dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error collecting garbage: %v\n", err)
+			os.Exit(1)
+		}
+
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		for _, layer := range res.Layers {
+			fmt.Printf("%s layer %s\n", verb, shortDigest(layer))
+		}
+		for _, overlay := range res.Overlays {
+			fmt.Printf("%s overlay %s\n", verb, overlay)
+		}
+		for _, id := range res.Storage {
+			fmt.Printf("%s storage of job %s\n", verb, id)
+		}
+		if dryRun {
+			fmt.Printf("Would free %s\n", formatSize(res.Freed))
+		} else {
+			fmt.Printf("Freed %s\n", formatSize(res.Freed))
+		}
+
+		usage, err := c.DiskUsage(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting disk usage: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tSIZE")
+		fmt.Fprintf(w, "images\t%s\n", formatSize(usage.Images))
+		fmt.Fprintf(w, "layers\t%s\n", formatSize(usage.Layers))
+		fmt.Fprintf(w, "overlays\t%s\n", formatSize(usage.Overlays))
+		fmt.Fprintf(w, "storage\t%s\n", formatSize(usage.Storage))
+		fmt.Fprintf(w, "reclaimable\t%s\n", formatSize(usage.Reclaimable))
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().String("server", "http://localhost:8000", "HTTP address of the castletown server")
+	gcCmd.Flags().Bool("dry-run", false, "Only show what would be removed")
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joshjms/castletown/client"
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/image"
+	"github.com/spf13/cobra"
+)
+
+// imageCmd groups the commands that manage sandbox images
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage sandbox images",
+	Long: `Manage the root filesystem images that sandboxes run on.
+
+Images are imported straight into the images directory. Listing, inspecting,
+removing and building them goes through a running server, which knows whether
+any sandbox is using an image.`,
+}
+
+// imageImportCmd unpacks an OCI image layout or a Docker archive
+var imageImportCmd = &cobra.Command{
+	Use:   "import <oci-layout-dir|docker-archive.tar>",
+	Short: "Import an image from an OCI image layout or a Docker archive",
+	Long: `Import an image from an OCI image layout (e.g. skopeo copy docker://gcc:15-bookworm oci:gcc)
+or a Docker archive (e.g. docker save gcc:15-bookworm -o gcc.tar) and register it under --name.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		config.ImagesDir, _ = cmd.Flags().GetString("images-dir")
+
+		if err := os.MkdirAll(config.ImagesDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create Images directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		img, err := image.Import(args[0], name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing image: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Imported %s (%s)\n", img.Name, img.Digest)
+	},
+}
+
+// imageListCmd lists the images of a running server
+var imageListCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List images",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := newImageClient(cmd)
+		defer c.Close()
+
+		images, err := c.ListImages(cmd.Context())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing images: %v\n", err)
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDIGEST\tSIZE\tIMPORTED\tIN USE")
+		for _, img := range images {
+			imported := "-"
+			if !img.ImportedAt.IsZero() {
+				imported = img.ImportedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", img.Name, shortDigest(img.Digest), formatSize(img.Size), imported, img.InUse)
+		}
+		w.Flush()
+	},
+}
+
+// imageInspectCmd prints everything a running server knows about images
+var imageInspectCmd = &cobra.Command{
+	Use:   "inspect <name>...",
+	Short: "Show details of images",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := newImageClient(cmd)
+		defer c.Close()
+
+		images := make([]*client.Image, 0, len(args))
+		for _, name := range args {
+			img, err := c.GetImage(cmd.Context(), name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error inspecting image %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			images = append(images, img)
+		}
+
+		out, _ := json.MarshalIndent(images, "", "  ")
+		fmt.Println(string(out))
+	},
+}
+
+// imageRemoveCmd removes images from a running server
+var imageRemoveCmd = &cobra.Command{
+	Use:     "rm <name>...",
+	Aliases: []string{"remove"},
+	Short:   "Remove images that no sandbox is using",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := newImageClient(cmd)
+		defer c.Close()
+
+		failed := false
+		for _, name := range args {
+			if err := c.DeleteImage(cmd.Context(), name); err != nil {
+				fmt.Fprintf(os.Stderr, "Error removing image %s: %v\n", name, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("Removed %s\n", name)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// imageBuildCmd builds an image on a running server
+var imageBuildCmd = &cobra.Command{
+	Use:   "build <base>",
+	Short: "Build an image by running commands on top of another",
+	Long: `Build an image by running each --run command with sh -c on top of <base>, one
+after another, and register what they change under --name. Files given with
+--file are placed in /box, which is not part of the new image.
+
+Build steps run as root over the image's files, with network access and
+looser limits than jobs, e.g.
+
+  castletown image build python:3.12 --name cs101:2026 --file requirements.txt \
+    --run "pip install --no-cache-dir -r /box/requirements.txt"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		runs, _ := cmd.Flags().GetStringArray("run")
+		paths, _ := cmd.Flags().GetStringArray("file")
+
+		req := &client.BuildImageRequest{Name: name, Base: args[0]}
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			req.Files = append(req.Files, client.File{Name: filepath.Base(path), Content: content})
+		}
+		for _, run := range runs {
+			step := client.Process{Image: args[0], Cmd: []string{"sh", "-c", run}}
+			for _, f := range req.Files {
+				step.Files = append(step.Files, f.Name)
+			}
+			req.Steps = append(req.Steps, step)
+		}
+
+		c := newImageClient(cmd)
+		defer c.Close()
+
+		resp, err := c.BuildImage(cmd.Context(), req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error building image: %v\n", err)
+			os.Exit(1)
+		}
+
+		for i, report := range resp.Reports {
+			fmt.Printf("Step %d/%d: %s (%s)\n", i+1, len(runs), runs[i], report.Status)
+			os.Stdout.Write(report.Stdout)
+			os.Stderr.Write(report.Stderr)
+		}
+		if resp.Image == nil {
+			fmt.Fprintf(os.Stderr, "Error building image: %s\n", resp.Error)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Built %s (%s)\n", resp.Image.Name, resp.Image.Digest)
+	},
+}
+
+func newImageClient(cmd *cobra.Command) client.Client {
+	server, _ := cmd.Flags().GetString("server")
+
+	var opts *client.ClientOptions
+	if timeout, err := cmd.Flags().GetDuration("timeout"); err == nil {
+		opts = &client.ClientOptions{Address: server, Timeout: timeout}
+	}
+
+	c, err := client.NewHTTPClient(server, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating client: %v\n", err)
+		os.Exit(1)
+	}
+	return c
+}
+
+func shortDigest(digest string) string {
+	if digest == "" {
+		return "-"
+	}
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	return digest
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(imageCmd)
+	imageCmd.AddCommand(imageImportCmd, imageListCmd, imageInspectCmd, imageRemoveCmd, imageBuildCmd)
+
+	for _, c := range []*cobra.Command{imageListCmd, imageInspectCmd, imageRemoveCmd, imageBuildCmd} {
+		c.Flags().String("server", "http://localhost:8000", "HTTP address of the castletown server")
+	}
+
+	imageImportCmd.Flags().String("images-dir", "/tmp/castletown/images", "Directory for container rootfs images")
+
+	imageImportCmd.Flags().String("name", "", "Name to register the image under, e.g. gcc:15-bookworm")
+	imageImportCmd.MarkFlagRequired("name")
+
+	imageBuildCmd.Flags().String("name", "", "Name to register the built image under, e.g. cs101:2026")
+	imageBuildCmd.MarkFlagRequired("name")
+	imageBuildCmd.Flags().StringArray("run", nil, "Command to run with sh -c, repeatable; runs in order")
+	imageBuildCmd.MarkFlagRequired("run")
+	imageBuildCmd.Flags().StringArray("file", nil, "Local file to place in /box for the build, repeatable")
+	imageBuildCmd.Flags().Duration("timeout", 30*time.Minute, "How long to wait for the build")
+}
@@ -4,13 +4,19 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/joshjms/castletown/config"
 	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/logging"
+	"github.com/joshjms/castletown/quota"
 	"github.com/joshjms/castletown/sandbox"
 	"github.com/joshjms/castletown/server"
+	"github.com/joshjms/castletown/tracing"
 	"github.com/spf13/cobra"
 )
 
@@ -32,8 +38,55 @@ Don't forget, I'm with you in the dark`,
 		config.LibcontainerDir, _ = cmd.Flags().GetString("libcontainer-dir")
 		config.MaxConcurrency, _ = cmd.Flags().GetInt("max-concurrency")
 		config.RootfsDir, _ = cmd.Flags().GetString("rootfs-dir")
+		config.PodsDir, _ = cmd.Flags().GetString("pods-dir")
+		config.MaxArtifactSize, _ = cmd.Flags().GetInt64("max-artifact-size")
+		config.MaxArtifactsSize, _ = cmd.Flags().GetInt64("max-artifacts-size")
+		config.SessionIdleTimeout, _ = cmd.Flags().GetDuration("session-idle-timeout")
+		config.SessionMaxLifetime, _ = cmd.Flags().GetDuration("session-max-lifetime")
+		config.GCInterval, _ = cmd.Flags().GetDuration("gc-interval")
+		config.GCMinAge, _ = cmd.Flags().GetDuration("gc-min-age")
+		config.OutputStreamRate, _ = cmd.Flags().GetInt64("output-stream-rate")
+		config.MaxOutputSize, _ = cmd.Flags().GetInt64("max-output-size")
+		config.AuthKeysFile, _ = cmd.Flags().GetString("auth-keys-file")
+		config.TLSCertFile, _ = cmd.Flags().GetString("tls-cert")
+		config.TLSKeyFile, _ = cmd.Flags().GetString("tls-key")
+		config.TLSClientCAFile, _ = cmd.Flags().GetString("tls-client-ca")
+		config.RateLimit, _ = cmd.Flags().GetFloat64("rate-limit")
+		config.JobLimit, _ = cmd.Flags().GetInt("job-limit")
+		config.CPUQuota, _ = cmd.Flags().GetFloat64("cpu-quota")
+		config.BuildPrincipals, _ = cmd.Flags().GetStringSlice("build-principals")
+		config.AdminPrincipals, _ = cmd.Flags().GetStringSlice("admin-principals")
+		config.LogLevel, _ = cmd.Flags().GetString("log-level")
+		config.LogFormat, _ = cmd.Flags().GetString("log-format")
+		config.TraceExporter, _ = cmd.Flags().GetString("trace-exporter")
+		config.TraceEndpoint, _ = cmd.Flags().GetString("trace-endpoint")
+		config.TraceInsecure, _ = cmd.Flags().GetBool("trace-insecure")
+		config.TraceFile, _ = cmd.Flags().GetString("trace-file")
+
+		if err := logging.Setup(os.Stderr, config.LogLevel, config.LogFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting up logging: %v\n", err)
+			os.Exit(1)
+		}
+
+		shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+			Exporter: config.TraceExporter,
+			Endpoint: config.TraceEndpoint,
+			Insecure: config.TraceInsecure,
+			File:     config.TraceFile,
+		})
+		if err != nil {
+			slog.Error("error setting up tracing", logging.Error(err))
+			os.Exit(1)
+		}
 
 		RunServer()
+
+		// Flush the spans of the last requests.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("error shutting down tracing", logging.Error(err))
+		}
 	},
 }
 
@@ -41,68 +94,85 @@ func RunServer() {
 	f, err := os.Stat(config.OverlayFSDir)
 	if os.IsNotExist(err) {
 		if err := os.Mkdir(config.OverlayFSDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create OverlayFS directory: %v\n", err)
+			slog.Error("failed to create OverlayFS directory", logging.Error(err))
 			os.Exit(1)
 		}
 	} else if !f.IsDir() {
-		fmt.Fprintf(os.Stderr, "OverlayFS path exists but is not a directory: %s\n", config.OverlayFSDir)
+		slog.Error("OverlayFS path exists but is not a directory", "path", config.OverlayFSDir)
 		os.Exit(1)
 	}
 
 	f, err = os.Stat(config.StorageDir)
 	if os.IsNotExist(err) {
 		if err := os.Mkdir(config.StorageDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create Storage directory: %v\n", err)
+			slog.Error("failed to create Storage directory", logging.Error(err))
 			os.Exit(1)
 		}
 	} else if !f.IsDir() {
-		fmt.Fprintf(os.Stderr, "Storage path exists but is not a directory: %s\n", config.StorageDir)
+		slog.Error("storage path exists but is not a directory", "path", config.StorageDir)
 		os.Exit(1)
 	}
 
 	f, err = os.Stat(config.ImagesDir)
 	if os.IsNotExist(err) {
 		if err := os.Mkdir(config.ImagesDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create Images directory: %v\n", err)
+			slog.Error("failed to create Images directory", logging.Error(err))
 			os.Exit(1)
 		}
 	} else if !f.IsDir() {
-		fmt.Fprintf(os.Stderr, "Images path exists but is not a directory: %s\n", config.ImagesDir)
+		slog.Error("images path exists but is not a directory", "path", config.ImagesDir)
 		os.Exit(1)
 	}
 
 	f, err = os.Stat(config.LibcontainerDir)
 	if os.IsNotExist(err) {
 		if err := os.Mkdir(config.LibcontainerDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create Libcontainer directory: %v\n", err)
+			slog.Error("failed to create Libcontainer directory", logging.Error(err))
 			os.Exit(1)
 		}
 	} else if !f.IsDir() {
-		fmt.Fprintf(os.Stderr, "Libcontainer path exists but is not a directory: %s\n", config.LibcontainerDir)
+		slog.Error("libcontainer path exists but is not a directory", "path", config.LibcontainerDir)
 		os.Exit(1)
 	}
 
 	f, err = os.Stat(config.RootfsDir)
 	if os.IsNotExist(err) {
 		if err := os.Mkdir(config.RootfsDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create Rootfs directory: %v\n", err)
+			slog.Error("failed to create Rootfs directory", logging.Error(err))
 			os.Exit(1)
 		}
 	} else if !f.IsDir() {
-		fmt.Fprintf(os.Stderr, "Rootfs path exists but is not a directory: %s\n", config.RootfsDir)
+		slog.Error("rootfs path exists but is not a directory", "path", config.RootfsDir)
+		os.Exit(1)
+	}
+
+	f, err = os.Stat(config.PodsDir)
+	if os.IsNotExist(err) {
+		if err := os.Mkdir(config.PodsDir, 0755); err != nil {
+			slog.Error("failed to create Pods directory", logging.Error(err))
+			os.Exit(1)
+		}
+	} else if !f.IsDir() {
+		slog.Error("pods path exists but is not a directory", "path", config.PodsDir)
 		os.Exit(1)
 	}
 
 	job.NewJobPool()
 
+	quota.NewLimiter(quota.Limits{
+		RequestsPerSecond: config.RateLimit,
+		ConcurrentJobs:    config.JobLimit,
+		CPUSecondsPerHour: config.CPUQuota,
+	})
+
 	if err := sandbox.NewManager(config.MaxConcurrency); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating sandbox manager: %v\n", err)
+		slog.Error("error creating sandbox manager", logging.Error(err))
 		os.Exit(1)
 	}
 
 	s, err := server.NewServer()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating server: %v\n", err)
+		slog.Error("error creating server", logging.Error(err))
 		os.Exit(1)
 	}
 	s.Start()
@@ -126,7 +196,31 @@ func init() {
 	serverCmd.Flags().String("images-dir", "/tmp/castletown/images", "Directory for container rootfs images")
 	serverCmd.Flags().String("libcontainer-dir", "/tmp/castletown/libcontainer", "Directory for libcontainer containers")
 	serverCmd.Flags().String("rootfs-dir", "/tmp/castletown/rootfs", "Directory for temporary root filesystems")
+	serverCmd.Flags().String("pods-dir", "/tmp/castletown/pods", "Directory for the namespaces shared by pod sandboxes")
 
 	serverCmd.Flags().IntP("port", "p", 8000, "Port to run the server on")
 	serverCmd.Flags().Int("max-concurrency", 10, "Maximum number of concurrent sandboxes")
+	serverCmd.Flags().Int64("max-artifact-size", 1*1024*1024, "Maximum size in bytes of a single artifact returned inline in a report")
+	serverCmd.Flags().Int64("max-artifacts-size", 4*1024*1024, "Maximum total size in bytes of the artifacts returned inline for one step")
+	serverCmd.Flags().Duration("session-idle-timeout", 5*time.Minute, "Time after which a session without input or output is ended")
+	serverCmd.Flags().Duration("session-max-lifetime", 1*time.Hour, "Maximum real time a session may run")
+	serverCmd.Flags().Duration("gc-interval", 1*time.Hour, "Interval between removals of unused layers, overlays and job storage, 0 to disable")
+	serverCmd.Flags().Duration("gc-min-age", 1*time.Hour, "Minimum time since unused data last changed before it is removed")
+	serverCmd.Flags().Int64("output-stream-rate", 256*1024, "Maximum bytes per second of a step's output streamed while it runs, 0 for no limit")
+	serverCmd.Flags().Int64("max-output-size", 16*1024*1024, "Maximum bytes of a step's stdout and of its stderr kept in its report and streamed, 0 for no limit")
+	serverCmd.Flags().String("auth-keys-file", "", "JSON file of the principals allowed to use the server and their API keys")
+	serverCmd.Flags().String("tls-cert", "", "PEM certificate served on both ports, enabling TLS; reloaded when it changes")
+	serverCmd.Flags().String("tls-key", "", "PEM private key of the TLS certificate")
+	serverCmd.Flags().String("tls-client-ca", "", "PEM bundle of the CAs whose client certificates authenticate requests")
+	serverCmd.Flags().Float64("rate-limit", 0, "Maximum requests per second of each principal, 0 for no limit")
+	serverCmd.Flags().Int("job-limit", 0, "Maximum jobs, builds and sessions each principal may run at once, 0 for no limit")
+	serverCmd.Flags().Float64("cpu-quota", 0, "Maximum CPU seconds the jobs of each principal may take per hour, 0 for no limit")
+	serverCmd.Flags().StringSlice("build-principals", nil, "Principals allowed to build images, or * for anyone; builds are rejected by default")
+	serverCmd.Flags().StringSlice("admin-principals", nil, "Principals allowed to remove images and collect garbage, or * for anyone; both are rejected by default")
+	serverCmd.Flags().String("log-level", "info", "Minimum level of logged lines: debug, info, warn or error")
+	serverCmd.Flags().String("log-format", "text", "Format of logged lines: text or json")
+	serverCmd.Flags().String("trace-exporter", "none", "Where spans go: none, otlp, stdout or file")
+	serverCmd.Flags().String("trace-endpoint", "", "host:port of the OTLP collector, over gRPC; defaults to the OTEL_EXPORTER_OTLP_* environment variables")
+	serverCmd.Flags().Bool("trace-insecure", false, "Send spans to the OTLP collector without TLS")
+	serverCmd.Flags().String("trace-file", "", "File the file exporter appends spans to, as JSON")
 }
@@ -1,14 +1,63 @@
 package config
 
+import "time"
+
 var (
 	OverlayFSDir    string
 	StorageDir      string
 	ImagesDir       string
 	LibcontainerDir string
 	RootfsDir       string
+	PodsDir         string
 
 	MaxConcurrency int
 	Port           int
+
+	MaxArtifactSize  int64
+	MaxArtifactsSize int64
+
+	SessionIdleTimeout time.Duration
+	SessionMaxLifetime time.Duration
+
+	GCInterval time.Duration
+	GCMinAge   time.Duration
+
+	OutputStreamRate int64
+	MaxOutputSize    int64
+
+	// AuthKeysFile lists the principals allowed to use the server and their
+	// API keys. Without it or TLSClientCAFile, requests are not
+	// authenticated.
+	AuthKeysFile string
+
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+
+	// RateLimit, JobLimit and CPUQuota limit each principal to a number of
+	// requests per second, of jobs running at once and of CPU seconds per
+	// hour. Zero means unlimited.
+	RateLimit float64
+	JobLimit  int
+	CPUQuota  float64
+
+	// BuildPrincipals may build images, "*" meaning anyone, including
+	// unauthenticated requests. Builds are rejected without it.
+	BuildPrincipals []string
+	// AdminPrincipals may remove images, collect garbage and see the disk
+	// usage, "*" meaning anyone. These are rejected without it.
+	AdminPrincipals []string
+
+	LogLevel  string
+	LogFormat string
+
+	// TraceExporter is where spans go: none, otlp, stdout or file. Spans go
+	// to the OTLP collector at TraceEndpoint, without TLS if TraceInsecure,
+	// or are appended to TraceFile.
+	TraceExporter string
+	TraceEndpoint string
+	TraceInsecure bool
+	TraceFile     string
 )
 
 func UseDefaults() {
@@ -17,7 +66,25 @@ func UseDefaults() {
 	ImagesDir = "/tmp/castletown/images"
 	LibcontainerDir = "/tmp/castletown/libcontainer"
 	RootfsDir = "/tmp/castletown/rootfs"
+	PodsDir = "/tmp/castletown/pods"
 
 	MaxConcurrency = 10
 	Port = 8080
+
+	MaxArtifactSize = 1 * 1024 * 1024
+	MaxArtifactsSize = 4 * 1024 * 1024
+
+	SessionIdleTimeout = 5 * time.Minute
+	SessionMaxLifetime = 1 * time.Hour
+
+	GCInterval = 1 * time.Hour
+	GCMinAge = 1 * time.Hour
+
+	OutputStreamRate = 256 * 1024
+	MaxOutputSize = 16 * 1024 * 1024
+
+	LogLevel = "info"
+	LogFormat = "text"
+
+	TraceExporter = "none"
 }
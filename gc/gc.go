@@ -0,0 +1,166 @@
+// Package gc reclaims the disk space castletown leaves behind: layers no
+// image refers to, overlay directories of sandboxes and builds that are gone
+// and the storage of jobs that are gone.
+package gc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/image"
+)
+
+const (
+	sandboxOverlayPrefix = "sandbox-"
+	buildOverlayPrefix   = "build-"
+)
+
+// Options tell Collect what is still in use. The InUse functions get the ID
+// of a sandbox, build or job; nil ones report nothing in use.
+type Options struct {
+	// MinAge spares anything changed more recently, which covers what is
+	// being set up while Collect runs.
+	MinAge time.Duration
+	// DryRun reports what would be removed without removing it.
+	DryRun bool
+
+	SandboxInUse func(id string) bool
+	BuildInUse   func(id string) bool
+	JobInUse     func(id string) bool
+}
+
+// Result lists what Collect removed.
+type Result struct {
+	// Layers are the diff IDs of the layers removed.
+	Layers []string
+	// Overlays are the names of the directories removed from
+	// config.OverlayFSDir.
+	Overlays []string
+	// Storage are the IDs of the jobs whose files, the proc-N directories
+	// of their steps included, were removed from config.StorageDir.
+	Storage []string
+	// Freed is the disk space reclaimed in bytes.
+	Freed int64
+}
+
+// Usage is the disk space taken by each kind of data, in bytes.
+type Usage struct {
+	// Images only counts images imported before layers were shared in
+	// full; the others take next to nothing beside their layers.
+	Images   int64
+	Layers   int64
+	Overlays int64
+	Storage  int64
+}
+
+// Collect removes the data nothing uses any more.
+func Collect(opts Options) (*Result, error) {
+	res := &Result{}
+	before := time.Now().Add(-opts.MinAge)
+
+	layers, freed, err := image.PruneLayers(opts.MinAge, opts.DryRun)
+	res.Layers, res.Freed = layers, freed
+	if err != nil {
+		return res, fmt.Errorf("error removing layers: %w", err)
+	}
+
+	entries, err := os.ReadDir(config.OverlayFSDir)
+	if err != nil && !os.IsNotExist(err) {
+		return res, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var inUse func(string) bool
+		switch {
+		case strings.HasPrefix(name, sandboxOverlayPrefix):
+			inUse = opts.SandboxInUse
+		case strings.HasPrefix(name, buildOverlayPrefix):
+			inUse = opts.BuildInUse
+		default:
+			continue
+		}
+
+		id := name[strings.Index(name, "-")+1:]
+		if inUse != nil && inUse(id) {
+			continue
+		}
+
+		removed, err := remove(res, filepath.Join(config.OverlayFSDir, name), before, opts.DryRun)
+		if err != nil {
+			return res, fmt.Errorf("error removing overlay %s: %w", name, err)
+		}
+		if removed {
+			res.Overlays = append(res.Overlays, name)
+		}
+	}
+
+	entries, err = os.ReadDir(config.StorageDir)
+	if err != nil && !os.IsNotExist(err) {
+		return res, err
+	}
+	for _, entry := range entries {
+		id := entry.Name()
+		if opts.JobInUse != nil && opts.JobInUse(id) {
+			continue
+		}
+
+		removed, err := remove(res, filepath.Join(config.StorageDir, id), before, opts.DryRun)
+		if err != nil {
+			return res, fmt.Errorf("error removing storage of job %s: %w", id, err)
+		}
+		if removed {
+			res.Storage = append(res.Storage, id)
+		}
+	}
+
+	return res, nil
+}
+
+// GetUsage measures the disk space taken by images, layers, overlays and job
+// storage.
+func GetUsage() (*Usage, error) {
+	images, layers, err := image.Usage()
+	if err != nil {
+		return nil, err
+	}
+	overlays, err := image.DiskUsage(config.OverlayFSDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	storage, err := image.DiskUsage(config.StorageDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &Usage{
+		Images:   images,
+		Layers:   layers,
+		Overlays: overlays,
+		Storage:  storage,
+	}, nil
+}
+
+// remove removes path unless it changed after before, adding the space it
+// took to res.Freed. It tells whether path was, or with dryRun would have
+// been, removed.
+func remove(res *Result, path string, before time.Time, dryRun bool) (bool, error) {
+	if !image.ChangedBefore(path, before) {
+		return false, nil
+	}
+
+	size, err := image.DiskUsage(path)
+	if err != nil {
+		return false, err
+	}
+	if !dryRun {
+		if err := os.RemoveAll(path); err != nil {
+			return false, err
+		}
+	}
+	res.Freed += size
+	return true, nil
+}
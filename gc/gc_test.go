@@ -0,0 +1,70 @@
+package gc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollect(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+	config.OverlayFSDir = t.TempDir()
+	config.StorageDir = t.TempDir()
+
+	for _, dir := range []string{
+		filepath.Join(config.OverlayFSDir, "sandbox-live-0", "upper"),
+		filepath.Join(config.OverlayFSDir, "sandbox-dead-0", "upper"),
+		filepath.Join(config.OverlayFSDir, "build-b1", "upper"),
+		filepath.Join(config.OverlayFSDir, "unknown"),
+		filepath.Join(config.StorageDir, "live", "proc-0"),
+		filepath.Join(config.StorageDir, "dead", "proc-0"),
+	} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(config.StorageDir, "dead", "proc-0", "main"), []byte("binary"), 0644))
+
+	opts := Options{
+		SandboxInUse: func(id string) bool { return id == "live-0" },
+		BuildInUse:   func(id string) bool { return false },
+		JobInUse:     func(id string) bool { return id == "live" },
+	}
+
+	// Nothing is old enough yet.
+	opts.MinAge = time.Hour
+	res, err := Collect(opts)
+	require.NoError(t, err)
+	require.Empty(t, res.Overlays)
+	require.Empty(t, res.Storage)
+
+	opts.MinAge = 0
+	opts.DryRun = true
+	res, err = Collect(opts)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"sandbox-dead-0", "build-b1"}, res.Overlays)
+	require.Equal(t, []string{"dead"}, res.Storage)
+	require.Positive(t, res.Freed)
+	require.DirExists(t, filepath.Join(config.StorageDir, "dead"))
+
+	opts.DryRun = false
+	res, err = Collect(opts)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"sandbox-dead-0", "build-b1"}, res.Overlays)
+	require.Equal(t, []string{"dead"}, res.Storage)
+
+	require.DirExists(t, filepath.Join(config.OverlayFSDir, "sandbox-live-0"))
+	require.DirExists(t, filepath.Join(config.OverlayFSDir, "unknown"))
+	require.DirExists(t, filepath.Join(config.StorageDir, "live"))
+	require.NoDirExists(t, filepath.Join(config.OverlayFSDir, "sandbox-dead-0"))
+	require.NoDirExists(t, filepath.Join(config.OverlayFSDir, "build-b1"))
+	require.NoDirExists(t, filepath.Join(config.StorageDir, "dead"))
+
+	usage, err := GetUsage()
+	require.NoError(t, err)
+	require.Zero(t, usage.Layers)
+	require.Positive(t, usage.Overlays)
+	require.Positive(t, usage.Storage)
+}
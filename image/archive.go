@@ -0,0 +1,136 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+// archive gives access to the files of an image source, which is either a
+// directory or an uncompressed tarball of one.
+type archive interface {
+	open(name string) (io.ReadCloser, error)
+	exists(name string) bool
+	Close() error
+}
+
+func openArchive(src string) (archive, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return dirArchive(src), nil
+	}
+	return openTarArchive(src)
+}
+
+type dirArchive string
+
+func (d dirArchive) open(name string) (io.ReadCloser, error) {
+	p, err := securejoin.SecureJoin(string(d), name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (d dirArchive) exists(name string) bool {
+	p, err := securejoin.SecureJoin(string(d), name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(p)
+	return err == nil
+}
+
+func (d dirArchive) Close() error {
+	return nil
+}
+
+// tarArchive reads members of a tarball in place. Their offsets are recorded
+// in a single pass, so layers are never copied out of the tarball.
+type tarArchive struct {
+	f       *os.File
+	members map[string]*io.SectionReader
+}
+
+func openTarArchive(src string) (*tarArchive, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err == nil && (bytes.HasPrefix(magic, gzipMagic) || bytes.HasPrefix(magic, zstdMagic)) {
+		f.Close()
+		return nil, fmt.Errorf("%s is compressed; decompress it first", src)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	a := &tarArchive{
+		f:       f,
+		members: map[string]*io.SectionReader{},
+	}
+
+	// tar.Reader reads headers in whole blocks straight from its reader and
+	// skips data by reading it, so the count is the offset of each member.
+	cr := &countingReader{r: f}
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("invalid tarball %s: %w", src, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		a.members[cleanMember(hdr.Name)] = io.NewSectionReader(f, cr.n, hdr.Size)
+	}
+
+	return a, nil
+}
+
+func (a *tarArchive) open(name string) (io.ReadCloser, error) {
+	sr, ok := a.members[cleanMember(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+	}
+	return io.NopCloser(io.NewSectionReader(sr, 0, sr.Size())), nil
+}
+
+func (a *tarArchive) exists(name string) bool {
+	_, ok := a.members[cleanMember(name)]
+	return ok
+}
+
+func (a *tarArchive) Close() error {
+	return a.f.Close()
+}
+
+func cleanMember(name string) string {
+	return path.Clean("/" + name)[1:]
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,236 @@
+package image
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Commit registers a new image called name that is base with the changes in
+// upperDir on top, the upper directory of an overlay mounted on base. The
+// changes become a new layer, stored like imported ones, so they are shared
+// with any image that happens to contain the same layer.
+func Commit(base, name, upperDir string) (*Image, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(Dir(name)); err == nil {
+		return nil, fmt.Errorf("image %q already exists", name)
+	}
+
+	baseImg, err := Get(base)
+	if err != nil {
+		return nil, err
+	}
+	if !baseImg.Layered {
+		return nil, fmt.Errorf("image %q was not imported as layers, import it again to build on it", base)
+	}
+
+	if err := os.MkdirAll(layersDir(), 0755); err != nil {
+		return nil, err
+	}
+
+	diffID, layerSize, err := commitLayer(upperDir)
+	if err != nil {
+		return nil, fmt.Errorf("error saving changes as a layer: %w", err)
+	}
+
+	img := &Image{
+		Name:       name,
+		Layers:     append(append([]string{}, baseImg.Layers...), diffID),
+		Layered:    true,
+		Config:     baseImg.Config,
+		Size:       baseImg.Size + layerSize,
+		ImportedAt: time.Now().UTC(),
+	}
+
+	// Docker identifies images by their config digest, which covers the
+	// layers; a built image has no manifest to take a digest from either.
+	var cfg ociConfig
+	cfg.Config = img.Config
+	cfg.RootFS.DiffIDs = img.Layers
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	img.Digest = digestOf(raw)
+
+	if err := register(img); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// commitLayer turns an overlay upper directory into a layer. The directory
+// is streamed as a layer tarball, which gives the layer its diff ID, into
+// the same unpacking as imports, which drops ownership and anything else
+// overlayfs keeps there for itself.
+func commitLayer(upperDir string) (string, int64, error) {
+	tmp, err := os.MkdirTemp(layersDir(), ".commit-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.RemoveAll(tmp)
+
+	unpacked := filepath.Join(tmp, "layer")
+	if err := os.Mkdir(unpacked, 0755); err != nil {
+		return "", 0, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeLayer(pw, upperDir))
+	}()
+
+	d := newDigester(pr)
+	err = extractLayer(unpacked, d)
+	// Drain whatever extraction left so the writer can finish.
+	if _, copyErr := io.Copy(io.Discard, d); err == nil {
+		err = copyErr
+	}
+	pr.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	diffID := d.digest()
+
+	dir, err := layerDir(diffID)
+	if err != nil {
+		return "", 0, err
+	}
+	size, err := DiskUsage(unpacked)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(unpacked, dir); err != nil {
+		if keepErr := keepLayer(dir); keepErr != nil {
+			return "", 0, fmt.Errorf("error moving layer into place: %w", err)
+		}
+	}
+	return diffID, size, nil
+}
+
+// writeLayer writes the overlay upper directory dir as a layer tarball:
+// whiteout devices become .wh. entries and opaque directories get a
+// .wh..wh..opq entry.
+func writeLayer(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	links := map[uint64]string{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		st, _ := info.Sys().(*syscall.Stat_t)
+
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+			Format:  tar.FormatPAX,
+		}
+		if info.Mode()&os.ModeSetuid != 0 {
+			hdr.Mode |= 04000
+		}
+		if info.Mode()&os.ModeSetgid != 0 {
+			hdr.Mode |= 02000
+		}
+		if info.Mode()&os.ModeSticky != 0 {
+			hdr.Mode |= 01000
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if isOpaque(path) {
+				return tw.WriteHeader(&tar.Header{
+					Name:     name + "/" + opaqueWhiteout,
+					Typeflag: tar.TypeReg,
+					ModTime:  info.ModTime(),
+					Format:   tar.FormatPAX,
+				})
+			}
+			return nil
+
+		case mode&os.ModeCharDevice != 0 && st != nil && st.Rdev == 0:
+			parent, base := filepath.Split(name)
+			return tw.WriteHeader(&tar.Header{
+				Name:     parent + whiteoutPrefix + base,
+				Typeflag: tar.TypeReg,
+				ModTime:  info.ModTime(),
+				Format:   tar.FormatPAX,
+			})
+
+		case mode.IsRegular():
+			if st != nil && st.Nlink > 1 {
+				if first, ok := links[st.Ino]; ok {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = first
+					return tw.WriteHeader(hdr)
+				}
+				links[st.Ino] = name
+			}
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = info.Size()
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+
+		case mode&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = target
+			return tw.WriteHeader(hdr)
+
+		case mode&os.ModeNamedPipe != 0:
+			hdr.Typeflag = tar.TypeFifo
+			return tw.WriteHeader(hdr)
+
+		default:
+			// Devices and sockets are not kept, as in imported layers.
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func isOpaque(dir string) bool {
+	buf := make([]byte, 1)
+	n, err := unix.Lgetxattr(dir, opaqueXattr, buf)
+	return err == nil && n == 1 && buf[0] == 'y'
+}
@@ -0,0 +1,279 @@
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+)
+
+// Image is the record kept next to an imported root filesystem.
+type Image struct {
+	Name string `json:"name"`
+	// Digest is the manifest digest for OCI layouts and the config digest,
+	// which Docker uses as the image ID, for Docker archives.
+	Digest string `json:"digest"`
+	// Layers are the diff IDs of the image's layers, bottom first.
+	Layers []string `json:"layers"`
+	// Layered images keep each layer in its own directory under the layers
+	// directory. Older imports were flattened into the image's directory.
+	Layered bool   `json:"layered,omitempty"`
+	Config  Config `json:"config"`
+	// Size is the disk space of the image's layers in bytes, including
+	// those shared with other images.
+	Size       int64     `json:"size"`
+	ImportedAt time.Time `json:"importedAt"`
+}
+
+// Config is the part of an OCI image config that describes how to run a
+// container from the image. Field names follow the image spec.
+type Config struct {
+	User       string   `json:"User,omitempty"`
+	Env        []string `json:"Env,omitempty"`
+	Entrypoint []string `json:"Entrypoint,omitempty"`
+	Cmd        []string `json:"Cmd,omitempty"`
+	WorkingDir string   `json:"WorkingDir,omitempty"`
+}
+
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(:[A-Za-z0-9][A-Za-z0-9._-]*)?$`)
+
+// ValidateName checks that name is a repository with an optional tag, such
+// as "gcc:15-bookworm". Registries and namespaces are not part of the name.
+func ValidateName(name string) error {
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid image name %q", name)
+	}
+	return nil
+}
+
+// Dir returns the directory of the image called name. For layered images it
+// is the topmost layer; otherwise it holds the whole root filesystem.
+func Dir(name string) string {
+	name = strings.Replace(name, ":", "-", 1)
+	return filepath.Join(config.ImagesDir, name)
+}
+
+func recordPath(name string) string {
+	return Dir(name) + ".json"
+}
+
+// Get returns the image called name. Images unpacked by hand, without a
+// record, only have their name and size filled in.
+func Get(name string) (*Image, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(Dir(name))
+	if err != nil {
+		return nil, fmt.Errorf("image %q does not exist: %w", name, err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("image %q is not a directory", name)
+	}
+
+	b, err := os.ReadFile(recordPath(name))
+	if os.IsNotExist(err) {
+		size, err := DiskUsage(Dir(name))
+		if err != nil {
+			return nil, err
+		}
+		return &Image{Name: name, Size: size}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var img Image
+	if err := json.Unmarshal(b, &img); err != nil {
+		return nil, fmt.Errorf("invalid image record for %q: %w", name, err)
+	}
+	return &img, nil
+}
+
+// GetConfig returns the stored OCI config of the image called name. Images
+// without a record have an empty config.
+func GetConfig(name string) (Config, error) {
+	b, err := os.ReadFile(recordPath(name))
+	if os.IsNotExist(err) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	var img Image
+	if err := json.Unmarshal(b, &img); err != nil {
+		return Config{}, fmt.Errorf("invalid image record for %q: %w", name, err)
+	}
+	return img.Config, nil
+}
+
+// LayerDirs returns the layer directories of the image called name below
+// Dir, bottom first. Images without a record or imported before layers were
+// shared have none. A layer that appears more than once, typically an empty
+// one, is only kept in its topmost place, since overlayfs refuses to stack a
+// directory twice.
+func LayerDirs(name string) ([]string, error) {
+	b, err := os.ReadFile(recordPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var img Image
+	if err := json.Unmarshal(b, &img); err != nil {
+		return nil, fmt.Errorf("invalid image record for %q: %w", name, err)
+	}
+	if !img.Layered {
+		return nil, nil
+	}
+
+	var dirs []string
+	seen := map[string]bool{}
+	for i := len(img.Layers) - 1; i >= 0; i-- {
+		if seen[img.Layers[i]] {
+			continue
+		}
+		seen[img.Layers[i]] = true
+
+		dir, err := layerDir(img.Layers[i])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("layer %s of image %q is missing: %w", img.Layers[i], name, err)
+		}
+		dirs = append([]string{dir}, dirs...)
+	}
+	return dirs, nil
+}
+
+// List returns all images, sorted by name.
+func List() ([]*Image, error) {
+	entries, err := os.ReadDir(config.ImagesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	images := []*Image{}
+	for _, entry := range entries {
+		// Imports in progress and records start with a dot or are files.
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		// The record knows the name the image was imported as, which Dir
+		// has flattened.
+		name := entry.Name()
+		if b, err := os.ReadFile(filepath.Join(config.ImagesDir, name+".json")); err == nil {
+			var img Image
+			if err := json.Unmarshal(b, &img); err == nil {
+				name = img.Name
+			}
+		}
+
+		img, err := Get(name)
+		if err != nil {
+			continue
+		}
+		images = append(images, img)
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Name < images[j].Name
+	})
+	return images, nil
+}
+
+// Delete removes the image called name. The root filesystem is first moved
+// aside, so the image disappears at once even if removing it takes a while.
+func Delete(name string) error {
+	if _, err := Get(name); err != nil {
+		return err
+	}
+
+	trash, err := os.MkdirTemp(config.ImagesDir, ".delete-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(trash)
+
+	if err := os.Rename(Dir(name), filepath.Join(trash, "rootfs")); err != nil {
+		return fmt.Errorf("error removing image %q: %w", name, err)
+	}
+	if err := os.Remove(recordPath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing record of image %q: %w", name, err)
+	}
+	return nil
+}
+
+// DiskUsage sums the sizes of the files under dir, counting hard links once.
+// Files removed while it walks are skipped.
+func DiskUsage(dir string) (int64, error) {
+	var size int64
+	seen := map[uint64]bool{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 && !d.IsDir() {
+			if seen[st.Ino] {
+				return nil
+			}
+			seen[st.Ino] = true
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// writeRecord stores the record of img, replacing any previous one
+// atomically.
+func writeRecord(img *Image) error {
+	b, err := json.MarshalIndent(img, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(config.ImagesDir, ".record-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), recordPath(img.Name))
+}
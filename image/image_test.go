@@ -0,0 +1,478 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+type layerEntry struct {
+	name     string
+	body     string
+	mode     int64
+	dir      bool
+	linkname string
+}
+
+func makeLayer(t *testing.T, entries []layerEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.dir:
+			hdr.Typeflag = tar.TypeDir
+		case e.linkname != "":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.linkname
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte(e.body))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func gzipped(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+var testLayers = [][]layerEntry{
+	{
+		{name: "etc/", dir: true, mode: 0755},
+		{name: "etc/hostname", body: "base"},
+		{name: "opt/", dir: true, mode: 0755},
+		{name: "opt/old", body: "old"},
+		{name: "usr/bin/tool", body: "#!/bin/sh", mode: 0755},
+		{name: "lib", linkname: "usr/lib"},
+	},
+	{
+		{name: "etc/.wh.hostname"},
+		{name: "opt/", dir: true, mode: 0755},
+		{name: "opt/.wh..wh..opq"},
+		{name: "opt/new", body: "new"},
+		{name: "lib", body: "not a link anymore"},
+	},
+}
+
+var testConfig = Config{
+	Env:        []string{"PATH=/usr/local/bin:/usr/bin", "LANG=C.UTF-8"},
+	WorkingDir: "/work",
+	Cmd:        []string{"sh"},
+}
+
+func imageConfig(t *testing.T, layers [][]byte) []byte {
+	var cfg ociConfig
+	cfg.Config = testConfig
+	for _, l := range layers {
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, digestOf(l))
+	}
+	b, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	return b
+}
+
+// writeOCILayout writes an OCI image layout with gzip-compressed layers and
+// returns the manifest digest.
+func writeOCILayout(t *testing.T, dir string) string {
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	require.NoError(t, os.MkdirAll(blobs, 0755))
+
+	writeBlob := func(b []byte) string {
+		d := digestOf(b)
+		require.NoError(t, os.WriteFile(filepath.Join(blobs, d[len(sha256Prefix):]), b, 0644))
+		return d
+	}
+
+	var layers [][]byte
+	for _, entries := range testLayers {
+		layers = append(layers, makeLayer(t, entries))
+	}
+
+	var om ociManifest
+	om.Config = descriptor{Digest: writeBlob(imageConfig(t, layers))}
+	for _, l := range layers {
+		om.Layers = append(om.Layers, descriptor{Digest: writeBlob(gzipped(t, l))})
+	}
+	b, err := json.Marshal(om)
+	require.NoError(t, err)
+	manifestDigest := writeBlob(b)
+
+	b, err = json.Marshal(ociIndex{Manifests: []descriptor{{Digest: manifestDigest}}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ociIndexFile), b, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644))
+
+	return manifestDigest
+}
+
+// writeDockerArchive writes a docker save tarball and returns the config
+// digest.
+func writeDockerArchive(t *testing.T, path string) string {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	add := func(name string, b []byte) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(b)
+		require.NoError(t, err)
+	}
+
+	var layers [][]byte
+	entry := dockerManifest{Config: "config.json", RepoTags: []string{"test:latest"}}
+	for i, entries := range testLayers {
+		l := makeLayer(t, entries)
+		layers = append(layers, l)
+		name := fmt.Sprintf("layer%d/layer.tar", i)
+		add(name, l)
+		entry.Layers = append(entry.Layers, name)
+	}
+
+	cfg := imageConfig(t, layers)
+	add("config.json", cfg)
+
+	b, err := json.Marshal([]dockerManifest{entry})
+	require.NoError(t, err)
+	add(dockerManifestFile, b)
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+	return digestOf(cfg)
+}
+
+// requireLayers checks that name was unpacked into one directory per layer,
+// with whiteouts in the form overlayfs expects.
+func requireLayers(t *testing.T, name string) {
+	dirs, err := LayerDirs(name)
+	require.NoError(t, err)
+	require.Len(t, dirs, 2)
+	base, top := dirs[0], dirs[1]
+
+	b, err := os.ReadFile(filepath.Join(base, "etc/hostname"))
+	require.NoError(t, err)
+	require.Equal(t, "base", string(b))
+
+	fi, err := os.Stat(filepath.Join(base, "usr/bin/tool"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+
+	fi, err = os.Lstat(filepath.Join(top, "etc/hostname"))
+	require.NoError(t, err)
+	require.Equal(t, os.ModeDevice|os.ModeCharDevice, fi.Mode().Type(), "whiteout should be a character device")
+	require.Zero(t, fi.Sys().(*syscall.Stat_t).Rdev)
+
+	buf := make([]byte, 1)
+	n, err := unix.Lgetxattr(filepath.Join(top, "opt"), opaqueXattr, buf)
+	require.NoError(t, err)
+	require.Equal(t, "y", string(buf[:n]))
+
+	_, err = os.Lstat(filepath.Join(top, "opt", opaqueWhiteout))
+	require.True(t, os.IsNotExist(err), "opaque marker should not be unpacked as a file")
+
+	fi, err = os.Lstat(filepath.Join(top, "lib"))
+	require.NoError(t, err)
+	require.True(t, fi.Mode().IsRegular())
+
+	fi, err = os.Stat(filepath.Join(Dir(name), "box"))
+	require.NoError(t, err)
+	require.True(t, fi.IsDir())
+}
+
+func TestImportOCILayout(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+
+	src := t.TempDir()
+	digest := writeOCILayout(t, src)
+
+	img, err := Import(src, "test:latest")
+	require.NoError(t, err)
+	require.Equal(t, digest, img.Digest)
+	require.Len(t, img.Layers, 2)
+
+	requireLayers(t, "test:latest")
+
+	stored, err := Get("test:latest")
+	require.NoError(t, err)
+	require.Equal(t, testConfig, stored.Config)
+	require.Equal(t, digest, stored.Digest)
+	require.True(t, stored.Layered)
+
+	_, err = Import(src, "test:latest")
+	require.Error(t, err)
+
+	// Another image with the same layers shares their directories.
+	_, err = Import(src, "other:latest")
+	require.NoError(t, err)
+
+	dirs, err := LayerDirs("test:latest")
+	require.NoError(t, err)
+	otherDirs, err := LayerDirs("other:latest")
+	require.NoError(t, err)
+	require.Equal(t, dirs, otherDirs)
+
+	layers, err := os.ReadDir(layersDir())
+	require.NoError(t, err)
+	require.Len(t, layers, 2)
+}
+
+func TestImportDockerArchive(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+
+	src := filepath.Join(t.TempDir(), "image.tar")
+	digest := writeDockerArchive(t, src)
+
+	img, err := Import(src, "test:latest")
+	require.NoError(t, err)
+	require.Equal(t, digest, img.Digest)
+	require.Equal(t, testConfig, img.Config)
+
+	requireLayers(t, "test:latest")
+}
+
+func TestImportDigestMismatch(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+
+	src := t.TempDir()
+	writeOCILayout(t, src)
+
+	// Corrupt the first layer without touching the manifest.
+	blobs := filepath.Join(src, "blobs", "sha256")
+	entries, err := os.ReadDir(blobs)
+	require.NoError(t, err)
+	for _, e := range entries {
+		p := filepath.Join(blobs, e.Name())
+		b, err := os.ReadFile(p)
+		require.NoError(t, err)
+		if bytes.HasPrefix(b, gzipMagic) {
+			require.NoError(t, os.WriteFile(p, gzipped(t, makeLayer(t, []layerEntry{{name: "evil", body: "x"}})), 0644))
+			break
+		}
+	}
+
+	_, err = Import(src, "test:latest")
+	require.Error(t, err)
+
+	_, err = os.Stat(Dir("test:latest"))
+	require.True(t, os.IsNotExist(err), "failed import must not leave an image behind")
+
+	// Only the layer that was verified may remain.
+	left, err := os.ReadDir(config.ImagesDir)
+	require.NoError(t, err)
+	require.Len(t, left, 1)
+	require.Equal(t, ".layers", left[0].Name())
+
+	layers, err := os.ReadDir(layersDir())
+	require.NoError(t, err)
+	require.Empty(t, layers)
+}
+
+func TestLayerDirsLegacy(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+
+	// Images unpacked by hand and flattened imports have no layers of
+	// their own.
+	require.NoError(t, os.MkdirAll(Dir("legacy"), 0755))
+	dirs, err := LayerDirs("legacy")
+	require.NoError(t, err)
+	require.Empty(t, dirs)
+
+	require.NoError(t, os.MkdirAll(Dir("flat:1"), 0755))
+	require.NoError(t, writeRecord(&Image{Name: "flat:1", Layers: []string{digestOf(nil)}}))
+	dirs, err = LayerDirs("flat:1")
+	require.NoError(t, err)
+	require.Empty(t, dirs)
+}
+
+func TestLayerDirsRepeated(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+
+	empty, other := digestOf(nil), digestOf([]byte("x"))
+	for _, d := range []string{empty, other} {
+		dir, err := layerDir(d)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+
+	require.NoError(t, os.MkdirAll(Dir("test"), 0755))
+	require.NoError(t, writeRecord(&Image{Name: "test", Layered: true, Layers: []string{empty, other, empty}}))
+
+	dirs, err := LayerDirs("test")
+	require.NoError(t, err)
+	require.Len(t, dirs, 2)
+	require.Equal(t, filepath.Base(dirs[0]), other[len(sha256Prefix):])
+	require.Equal(t, filepath.Base(dirs[1]), empty[len(sha256Prefix):])
+
+	// A missing layer makes the image unusable.
+	otherDir, _ := layerDir(other)
+	require.NoError(t, os.RemoveAll(otherDir))
+	_, err = LayerDirs("test")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"gcc:15-bookworm", "python:3.12", "eclipse-temurin", "busybox:latest"} {
+		require.NoError(t, ValidateName(name), name)
+	}
+	for _, name := range []string{"", "../etc", "library/python:3.12", "a:b:c", ".hidden", "gcc:"} {
+		require.Error(t, ValidateName(name), name)
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+
+	src := t.TempDir()
+	writeOCILayout(t, src)
+	_, err := Import(src, "test:latest")
+	require.NoError(t, err)
+
+	// Images unpacked by hand have no record but are still listed.
+	require.NoError(t, os.MkdirAll(filepath.Join(config.ImagesDir, "legacy", "bin"), 0755))
+
+	images, err := List()
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+	require.Equal(t, "legacy", images[0].Name)
+	require.Empty(t, images[0].Digest)
+	require.Equal(t, "test:latest", images[1].Name)
+	require.NotEmpty(t, images[1].Digest)
+	require.Positive(t, images[1].Size)
+
+	require.NoError(t, Delete("test:latest"))
+	_, err = Get("test:latest")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	images, err = List()
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+
+	require.ErrorIs(t, Delete("test:latest"), os.ErrNotExist)
+}
+
+func TestCommit(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+
+	src := t.TempDir()
+	writeOCILayout(t, src)
+	base, err := Import(src, "base:latest")
+	require.NoError(t, err)
+
+	// An upper directory as overlayfs leaves it: a new file, a whiteout and
+	// a directory that replaced one from below.
+	upper := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(upper, "usr/lib/python3"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(upper, "usr/lib/python3/numpy.py"), []byte("pi = 3"), 0644))
+	require.NoError(t, os.Link(filepath.Join(upper, "usr/lib/python3/numpy.py"), filepath.Join(upper, "usr/lib/python3/np.py")))
+	require.NoError(t, os.MkdirAll(filepath.Join(upper, "usr/bin"), 0755))
+	require.NoError(t, unix.Mknod(filepath.Join(upper, "usr/bin/tool"), unix.S_IFCHR, 0))
+	require.NoError(t, os.Mkdir(filepath.Join(upper, "opt"), 0755))
+	require.NoError(t, unix.Lsetxattr(filepath.Join(upper, "opt"), opaqueXattr, []byte("y"), 0))
+	require.NoError(t, os.WriteFile(filepath.Join(upper, "usr/bin/su"), []byte("#!/bin/sh"), 0755))
+	require.NoError(t, os.Chmod(filepath.Join(upper, "usr/bin/su"), 0755|os.ModeSetuid))
+
+	img, err := Commit("base:latest", "built:1", upper)
+	require.NoError(t, err)
+	require.Len(t, img.Layers, 3)
+	require.Equal(t, base.Layers, img.Layers[:2])
+	require.Equal(t, base.Config, img.Config)
+	require.NotEqual(t, base.Digest, img.Digest)
+
+	stored, err := Get("built:1")
+	require.NoError(t, err)
+	require.Equal(t, img.Digest, stored.Digest)
+
+	dirs, err := LayerDirs("built:1")
+	require.NoError(t, err)
+	require.Len(t, dirs, 3)
+	top := dirs[2]
+
+	b, err := os.ReadFile(filepath.Join(top, "usr/lib/python3/np.py"))
+	require.NoError(t, err)
+	require.Equal(t, "pi = 3", string(b))
+
+	fi, err := os.Lstat(filepath.Join(top, "usr/bin/tool"))
+	require.NoError(t, err)
+	require.NotZero(t, fi.Mode()&os.ModeCharDevice, "whiteout should survive the commit")
+	require.True(t, isOpaque(filepath.Join(top, "opt")))
+
+	// Whoever made the changes, the layer belongs to the owner of images.
+	uid, gid := Owner()
+	fi, err = os.Stat(filepath.Join(top, "usr/bin/su"))
+	require.NoError(t, err)
+	require.Equal(t, uid, fi.Sys().(*syscall.Stat_t).Uid)
+	require.Equal(t, gid, fi.Sys().(*syscall.Stat_t).Gid)
+	require.NotZero(t, fi.Mode()&os.ModeSetuid, "setuid should survive the change of owner")
+
+	// The same changes make the same layer.
+	again, err := Commit("base:latest", "built:2", upper)
+	require.NoError(t, err)
+	require.Equal(t, img.Layers, again.Layers)
+
+	_, err = Commit("base:latest", "built:1", upper)
+	require.Error(t, err)
+
+	require.NoError(t, os.MkdirAll(Dir("legacy"), 0755))
+	_, err = Commit("legacy", "built:3", upper)
+	require.Error(t, err)
+}
+
+func TestPruneLayers(t *testing.T) {
+	config.ImagesDir = t.TempDir()
+
+	src := t.TempDir()
+	writeOCILayout(t, src)
+	img, err := Import(src, "base:latest")
+	require.NoError(t, err)
+
+	upper := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(upper, "extra"), []byte("extra"), 0644))
+	built, err := Commit("base:latest", "built:1", upper)
+	require.NoError(t, err)
+	extra := built.Layers[len(built.Layers)-1]
+
+	// Everything is too recent to remove.
+	require.NoError(t, Delete("built:1"))
+	removed, _, err := PruneLayers(time.Hour, false)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+
+	removed, freed, err := PruneLayers(0, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{extra}, removed)
+	require.Positive(t, freed)
+	dir, err := layerDir(extra)
+	require.NoError(t, err)
+	require.DirExists(t, dir, "a dry run should not remove anything")
+
+	removed, _, err = PruneLayers(0, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{extra}, removed)
+	require.NoDirExists(t, dir)
+
+	// The layers of the remaining image are kept.
+	dirs, err := LayerDirs("base:latest")
+	require.NoError(t, err)
+	require.Len(t, dirs, len(img.Layers))
+}
@@ -0,0 +1,427 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+)
+
+const (
+	mediaTypeOCIIndex   = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestFile  = "manifest.json"
+	ociIndexFile        = "index.json"
+	mountPointBox       = "box"
+	sha256Prefix        = "sha256:"
+)
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type ociIndex struct {
+	Manifests []descriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Config descriptor   `json:"config"`
+	Layers []descriptor `json:"layers"`
+}
+
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+type ociConfig struct {
+	Config Config `json:"config"`
+	RootFS struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// manifest is what an import needs from either source format.
+type manifest struct {
+	digest string
+	config ociConfig
+	layers []layerRef
+}
+
+type layerRef struct {
+	path string
+	// digest of the blob as stored, empty when the format has none.
+	digest string
+}
+
+// Import unpacks the image in src, an OCI image layout or a Docker archive
+// as written by docker save, and registers it as name. Each layer is
+// unpacked into its own directory, shared with other images that contain
+// the same layer. Layers and the image itself only appear once complete, so
+// a failed import leaves nothing behind but the layers it finished.
+func Import(src, name string) (*Image, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Lstat(Dir(name)); err == nil {
+		return nil, fmt.Errorf("image %q already exists", name)
+	}
+
+	a, err := openArchive(src)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", src, err)
+	}
+	defer a.Close()
+
+	m, err := readManifest(a, name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(layersDir(), 0755); err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for i, layer := range m.layers {
+		layerSize, err := importLayer(a, layer, m.config.RootFS.DiffIDs[i])
+		if err != nil {
+			return nil, fmt.Errorf("error unpacking layer %d: %w", i, err)
+		}
+		size += layerSize
+	}
+
+	img := &Image{
+		Name:       name,
+		Digest:     m.digest,
+		Layers:     m.config.RootFS.DiffIDs,
+		Layered:    true,
+		Config:     m.config.Config,
+		Size:       size,
+		ImportedAt: time.Now().UTC(),
+	}
+	if err := register(img); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// register makes img, whose layers are in place, available under its name.
+func register(img *Image) error {
+	// The image's own directory is the topmost layer. It only holds the
+	// mount point that job files are bind-mounted onto.
+	tmp, err := os.MkdirTemp(config.ImagesDir, ".import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	top := filepath.Join(tmp, "top")
+	if err := os.MkdirAll(filepath.Join(top, mountPointBox), 0755); err != nil {
+		return err
+	}
+
+	// Renaming onto an existing non-empty directory fails, which settles a
+	// race with a concurrent import of the same name.
+	dir := Dir(img.Name)
+	if err := os.Rename(top, dir); err != nil {
+		return fmt.Errorf("error moving image into place: %w", err)
+	}
+	if err := writeRecord(img); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("error writing image record: %w", err)
+	}
+	return nil
+}
+
+// importLayer unpacks a layer unless an earlier import already has, and
+// returns its size on disk.
+func importLayer(a archive, layer layerRef, diffID string) (int64, error) {
+	dir, err := layerDir(diffID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		if err := keepLayer(dir); err != nil {
+			return 0, err
+		}
+		return DiskUsage(dir)
+	}
+
+	tmp, err := os.MkdirTemp(layersDir(), ".import-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmp)
+
+	unpacked := filepath.Join(tmp, "layer")
+	if err := os.Mkdir(unpacked, 0755); err != nil {
+		return 0, err
+	}
+
+	blob, err := a.open(layer.path)
+	if err != nil {
+		return 0, err
+	}
+	defer blob.Close()
+
+	compressed := newDigester(blob)
+	rd, err := decompress(compressed)
+	if err != nil {
+		return 0, err
+	}
+	defer rd.Close()
+
+	uncompressed := newDigester(rd)
+	if err := extractLayer(unpacked, uncompressed); err != nil {
+		return 0, err
+	}
+
+	if err := uncompressed.verify(diffID); err != nil {
+		return 0, err
+	}
+	if layer.digest != "" {
+		if err := compressed.verify(layer.digest); err != nil {
+			return 0, err
+		}
+	}
+
+	size, err := DiskUsage(unpacked)
+	if err != nil {
+		return 0, err
+	}
+
+	// A concurrent import of the same layer may have won the race, which
+	// is just as good.
+	if err := os.Rename(unpacked, dir); err != nil {
+		if keepErr := keepLayer(dir); keepErr != nil {
+			return 0, fmt.Errorf("error moving layer into place: %w", err)
+		}
+	}
+	return size, nil
+}
+
+func readManifest(a archive, name string) (*manifest, error) {
+	var (
+		m   *manifest
+		err error
+	)
+
+	// Newer versions of docker save write an OCI layout with a Docker
+	// manifest on top; either way the Docker manifest is the simpler read.
+	switch {
+	case a.exists(dockerManifestFile):
+		m, err = readDockerManifest(a, name)
+	case a.exists(ociIndexFile):
+		m, err = readOCIManifest(a)
+	default:
+		return nil, fmt.Errorf("neither %s nor %s found", dockerManifestFile, ociIndexFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.config.RootFS.DiffIDs) != len(m.layers) {
+		return nil, fmt.Errorf("image config lists %d layers, manifest has %d", len(m.config.RootFS.DiffIDs), len(m.layers))
+	}
+	return m, nil
+}
+
+func readDockerManifest(a archive, name string) (*manifest, error) {
+	var entries []dockerManifest
+	if err := readJSON(a, dockerManifestFile, "", &entries); err != nil {
+		return nil, err
+	}
+
+	// An archive of several images is disambiguated by tag.
+	var entry *dockerManifest
+	if len(entries) == 1 {
+		entry = &entries[0]
+	}
+	for i := range entries {
+		for _, tag := range entries[i].RepoTags {
+			if entry == nil && tag == name {
+				entry = &entries[i]
+			}
+		}
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("archive has %d images and none is tagged %q", len(entries), name)
+	}
+
+	rc, err := a.open(entry.Config)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &manifest{digest: digestOf(raw)}
+	if err := json.Unmarshal(raw, &m.config); err != nil {
+		return nil, fmt.Errorf("invalid image config: %w", err)
+	}
+	for _, layer := range entry.Layers {
+		m.layers = append(m.layers, layerRef{path: layer})
+	}
+	return m, nil
+}
+
+func readOCIManifest(a archive) (*manifest, error) {
+	var index ociIndex
+	if err := readJSON(a, ociIndexFile, "", &index); err != nil {
+		return nil, err
+	}
+
+	desc, err := pickManifest(index.Manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	// Multi-platform images point at another index first.
+	for desc.MediaType == mediaTypeOCIIndex || desc.MediaType == mediaTypeDockerList {
+		var nested ociIndex
+		if err := readBlobJSON(a, desc.Digest, &nested); err != nil {
+			return nil, err
+		}
+		if desc, err = pickManifest(nested.Manifests); err != nil {
+			return nil, err
+		}
+	}
+
+	var om ociManifest
+	if err := readBlobJSON(a, desc.Digest, &om); err != nil {
+		return nil, err
+	}
+
+	m := &manifest{digest: desc.Digest}
+	if err := readBlobJSON(a, om.Config.Digest, &m.config); err != nil {
+		return nil, err
+	}
+	for _, layer := range om.Layers {
+		p, err := blobPath(layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		m.layers = append(m.layers, layerRef{path: p, digest: layer.Digest})
+	}
+	return m, nil
+}
+
+// pickManifest chooses the manifest for the host's platform. Descriptors
+// without a platform match any.
+func pickManifest(descs []descriptor) (descriptor, error) {
+	for _, d := range descs {
+		if d.Platform == nil || (d.Platform.OS == runtime.GOOS && d.Platform.Architecture == runtime.GOARCH) {
+			return d, nil
+		}
+	}
+	return descriptor{}, fmt.Errorf("no manifest for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// parseDigest returns the hex-encoded part of a sha256 digest, which is safe
+// to use in paths.
+func parseDigest(digest string) (string, error) {
+	if !strings.HasPrefix(digest, sha256Prefix) {
+		return "", fmt.Errorf("unsupported digest %q", digest)
+	}
+	encoded := strings.TrimPrefix(digest, sha256Prefix)
+	if _, err := hex.DecodeString(encoded); err != nil || len(encoded) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return encoded, nil
+}
+
+func blobPath(digest string) (string, error) {
+	encoded, err := parseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join("blobs", "sha256", encoded), nil
+}
+
+func readBlobJSON(a archive, digest string, v any) error {
+	p, err := blobPath(digest)
+	if err != nil {
+		return err
+	}
+	return readJSON(a, p, digest, v)
+}
+
+// readJSON decodes the file name from a, checking it against digest unless
+// digest is empty.
+func readJSON(a archive, name, digest string, v any) error {
+	rc, err := a.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if digest != "" && digestOf(raw) != digest {
+		return fmt.Errorf("digest mismatch for %s", name)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return sha256Prefix + hex.EncodeToString(sum[:])
+}
+
+// digester hashes everything read through it.
+type digester struct {
+	r io.Reader
+	h hash.Hash
+}
+
+func newDigester(r io.Reader) *digester {
+	return &digester{r: r, h: sha256.New()}
+}
+
+func (d *digester) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.h.Write(p[:n])
+	return n, err
+}
+
+// digest returns the digest of what has been read so far.
+func (d *digester) digest() string {
+	return sha256Prefix + hex.EncodeToString(d.h.Sum(nil))
+}
+
+// verify reads the rest of the stream and checks its digest.
+func (d *digester) verify(want string) error {
+	if _, err := io.Copy(io.Discard, d); err != nil {
+		return err
+	}
+	if got := d.digest(); got != want {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", want, got)
+	}
+	return nil
+}
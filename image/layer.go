@@ -0,0 +1,266 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/sandbox/allocator"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+
+	// Sandboxes mount overlayfs with userxattr, so it looks for its
+	// attributes in the user namespace.
+	opaqueXattr = "user.overlay.opaque"
+)
+
+// OWNER_ID is the host user and group that own the files of images when the
+// server runs as root. It is unprivileged and just below the ranges sandboxes
+// are given, so that build steps, whose root it is, can change the files of
+// images and nothing else of the host's.
+const OWNER_ID = allocator.START_UID_GID - 1
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Owner returns the host user and group that own the files of images:
+// OWNER_ID, or the user running the server when it is not root.
+func Owner() (uid, gid uint32) {
+	if os.Geteuid() != 0 {
+		return uint32(os.Getuid()), uint32(os.Getgid())
+	}
+	return OWNER_ID, OWNER_ID
+}
+
+// layersDir holds one directory per layer, named by its diff ID, shared by
+// every image that contains the layer.
+func layersDir() string {
+	return filepath.Join(config.ImagesDir, ".layers", "sha256")
+}
+
+// layerDir returns the directory of the layer with the given diff ID.
+func layerDir(diffID string) (string, error) {
+	encoded, err := parseDigest(diffID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(layersDir(), encoded), nil
+}
+
+// keepLayer marks an existing layer as just used by refreshing its change
+// time, so that a concurrent PruneLayers leaves it alone until the record of
+// the image being registered refers to it.
+func keepLayer(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(dir, time.Now(), fi.ModTime())
+}
+
+// decompress detects the compression of a layer blob by its magic number.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		return gz, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return nil, fmt.Errorf("zstd-compressed layers are not supported")
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// extractLayer unpacks an uncompressed layer tarball into the empty
+// directory root, in the form overlayfs expects of a lower directory:
+// whiteouts become 0:0 character devices and opaque directories are marked
+// with an xattr. The kernel lets unprivileged users create such devices.
+//
+// Ownership is not kept: everything belongs to the Owner of images, like an
+// unpack with umoci --rootless. Device nodes are skipped for the same reason.
+func extractLayer(root string, r io.Reader) error {
+	tr := tar.NewReader(r)
+
+	type dirAttrs struct {
+		path  string
+		mode  os.FileMode
+		atime time.Time
+		mtime time.Time
+	}
+	var dirs []dirAttrs
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid layer tarball: %w", err)
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		dir, base := path.Split(name)
+
+		parent, err := securejoin.SecureJoin(root, dir)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return err
+		}
+
+		if base == opaqueWhiteout {
+			if err := unix.Lsetxattr(parent, opaqueXattr, []byte("y"), 0); err != nil {
+				return fmt.Errorf("error marking %q opaque: %w", dir, err)
+			}
+			continue
+		}
+
+		// The last component is joined without resolving it, so that an
+		// entry replaces a symlink instead of following it.
+		target := filepath.Join(parent, strings.TrimPrefix(base, whiteoutPrefix))
+		if !isDir(target, hdr) {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			if err := unix.Mknod(target, unix.S_IFCHR, int(unix.Mkdev(0, 0))); err != nil {
+				return fmt.Errorf("error creating whiteout for %q: %w", name, err)
+			}
+			continue
+		}
+
+		mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(target, 0755); err != nil && !os.IsExist(err) {
+				return err
+			}
+			dirs = append(dirs, dirAttrs{target, mode, hdr.AccessTime, hdr.ModTime})
+
+		case tar.TypeReg:
+			if err := writeFile(target, mode, tr); err != nil {
+				return fmt.Errorf("error unpacking %q: %w", name, err)
+			}
+			if err := os.Chtimes(target, hdr.AccessTime, hdr.ModTime); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			// Targets are resolved inside the container, so absolute ones
+			// are fine here.
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+
+		case tar.TypeLink:
+			// Hard links always point at an earlier entry of the same layer.
+			linkDir, linkBase := path.Split(path.Clean("/" + hdr.Linkname))
+			linkParent, err := securejoin.SecureJoin(root, linkDir)
+			if err != nil {
+				return err
+			}
+			if err := os.Link(filepath.Join(linkParent, linkBase), target); err != nil {
+				return fmt.Errorf("error unpacking hard link %q: %w", name, err)
+			}
+
+		case tar.TypeFifo:
+			if err := unix.Mkfifo(target, uint32(mode.Perm())); err != nil {
+				return err
+			}
+
+		default:
+			// Devices need privileges the sandbox does not have, and
+			// anything else carries no file.
+		}
+	}
+
+	// Directories get their attributes last, since unpacking into them
+	// updates their modification time and needs them to be writable.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].atime, dirs[i].mtime); err != nil {
+			return err
+		}
+	}
+
+	return chownLayer(root)
+}
+
+// chownLayer gives the files of the layer in root to the Owner of images.
+// Changing the owner clears setuid and setgid bits, so they are set again.
+func chownLayer(root string) error {
+	uid, gid := Owner()
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Lchown(path, int(uid), int(gid)); err != nil {
+			return err
+		}
+		if info.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 && info.Mode()&os.ModeSymlink == 0 {
+			return os.Chmod(path, info.Mode())
+		}
+		return nil
+	})
+}
+
+// isDir tells whether a directory entry is about to be unpacked onto an
+// existing directory, which keeps what is already inside.
+func isDir(target string, hdr *tar.Header) bool {
+	fi, err := os.Lstat(target)
+	return err == nil && fi.IsDir() && hdr.Typeflag == tar.TypeDir
+}
+
+func writeFile(path string, mode os.FileMode, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Chmod rather than the create mode, which the umask would mask.
+	return os.Chmod(path, mode)
+}
@@ -0,0 +1,148 @@
+package image
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+)
+
+// PruneLayers removes the layers no image refers to, along with whatever
+// failed imports, builds and removals left behind. Anything changed within
+// minAge is spared, which covers imports and builds in progress as long as
+// minAge is longer than they take. With dryRun nothing is removed. It
+// returns the diff IDs of the layers removed and the bytes freed.
+func PruneLayers(minAge time.Duration, dryRun bool) ([]string, int64, error) {
+	used, err := usedLayers()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		removed []string
+		freed   int64
+	)
+	remove := func(path string) error {
+		size, err := DiskUsage(path)
+		if err != nil {
+			return err
+		}
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+		freed += size
+		return nil
+	}
+
+	entries, err := os.ReadDir(layersDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(layersDir(), entry.Name())
+		if !ChangedBefore(path, time.Now().Add(-minAge)) {
+			continue
+		}
+
+		if strings.HasPrefix(entry.Name(), ".") {
+			if err := remove(path); err != nil {
+				return removed, freed, err
+			}
+			continue
+		}
+
+		diffID := sha256Prefix + entry.Name()
+		if used[diffID] {
+			continue
+		}
+		if err := remove(path); err != nil {
+			return removed, freed, err
+		}
+		removed = append(removed, diffID)
+	}
+
+	// Temporary directories and records of imports and removals.
+	entries, err = os.ReadDir(config.ImagesDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, ".import-") && !strings.HasPrefix(name, ".delete-") && !strings.HasPrefix(name, ".record-") {
+			continue
+		}
+		path := filepath.Join(config.ImagesDir, name)
+		if !ChangedBefore(path, time.Now().Add(-minAge)) {
+			continue
+		}
+		if err := remove(path); err != nil {
+			return removed, freed, err
+		}
+	}
+
+	return removed, freed, nil
+}
+
+// Usage returns the disk space taken by images, which only counts images
+// imported before layers were shared in full, and by layers.
+func Usage() (images, layers int64, err error) {
+	total, err := DiskUsage(config.ImagesDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	layers, err = DiskUsage(layersDir())
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+	return total - layers, layers, nil
+}
+
+// usedLayers returns the diff IDs that image records refer to.
+func usedLayers() (map[string]bool, error) {
+	entries, err := os.ReadDir(config.ImagesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(config.ImagesDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var img Image
+		if err := json.Unmarshal(b, &img); err != nil {
+			// Removing the layers of an image because its record is broken
+			// would lose more than it saves.
+			return nil, err
+		}
+		for _, diffID := range img.Layers {
+			used[diffID] = true
+		}
+	}
+	return used, nil
+}
+
+// ChangedBefore tells whether path was last changed, including renames and
+// changes to its metadata, before t. Paths that cannot be examined are not.
+func ChangedBefore(path string, t time.Time) bool {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.ModTime().Before(t)
+	}
+	return time.Unix(st.Ctim.Unix()).Before(t)
+}
@@ -0,0 +1,90 @@
+package image
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+// LookupUser resolves user, in any form an OCI config allows ("name",
+// "uid", "name:group" or "uid:gid"), against the passwd and group files of
+// the image called name. An empty user is root.
+func LookupUser(name, user string) (uid, gid uint32, err error) {
+	if user == "" {
+		return 0, 0, nil
+	}
+
+	userPart, groupPart, hasGroup := strings.Cut(user, ":")
+
+	// A numeric user without a passwd entry gets gid 0, as with Docker.
+	if id, err := strconv.ParseUint(userPart, 10, 32); err == nil {
+		uid = uint32(id)
+		if entry, ok := findEntry(name, "/etc/passwd", 2, userPart); ok {
+			gid, _ = parseID(entry[3])
+		}
+	} else {
+		entry, ok := findEntry(name, "/etc/passwd", 0, userPart)
+		if !ok {
+			return 0, 0, fmt.Errorf("user %q not found in image %q", userPart, name)
+		}
+		if uid, err = parseID(entry[2]); err != nil {
+			return 0, 0, fmt.Errorf("invalid passwd entry for %q: %w", userPart, err)
+		}
+		if gid, err = parseID(entry[3]); err != nil {
+			return 0, 0, fmt.Errorf("invalid passwd entry for %q: %w", userPart, err)
+		}
+	}
+
+	if !hasGroup {
+		return uid, gid, nil
+	}
+
+	if id, err := strconv.ParseUint(groupPart, 10, 32); err == nil {
+		return uid, uint32(id), nil
+	}
+	entry, ok := findEntry(name, "/etc/group", 0, groupPart)
+	if !ok {
+		return 0, 0, fmt.Errorf("group %q not found in image %q", groupPart, name)
+	}
+	if gid, err = parseID(entry[2]); err != nil {
+		return 0, 0, fmt.Errorf("invalid group entry for %q: %w", groupPart, err)
+	}
+	return uid, gid, nil
+}
+
+// findEntry returns the first line of a colon-separated database in the
+// image whose field at index key equals value. Both passwd and group
+// entries have at least four fields.
+func findEntry(name, file string, key int, value string) ([]string, bool) {
+	path, err := securejoin.SecureJoin(Dir(name), file)
+	if err != nil {
+		return nil, false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 4 {
+			continue
+		}
+		if fields[key] == value {
+			return fields, true
+		}
+	}
+	return nil, false
+}
+
+func parseID(s string) (uint32, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	return uint32(id), err
+}
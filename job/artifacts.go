@@ -0,0 +1,113 @@
+package job
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/sandbox"
+)
+
+// collectArtifacts gathers the regular files in boxDir selected by patterns.
+// Files are returned inline until config.MaxArtifactSize or
+// config.MaxArtifactsSize would be exceeded; the rest are reported with
+// Omitted set. Symlinks are never followed.
+func collectArtifacts(boxDir string, patterns []string) ([]sandbox.Artifact, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	artifacts := make([]sandbox.Artifact, 0)
+	budget := config.MaxArtifactsSize
+
+	err := filepath.WalkDir(boxDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(boxDir, p)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+
+		if !matchAny(patterns, name) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		artifact := sandbox.Artifact{
+			Path: name,
+			Size: info.Size(),
+		}
+
+		if info.Size() > config.MaxArtifactSize || info.Size() > budget {
+			artifact.Omitted = true
+		} else {
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			artifact.Content = content
+			budget -= int64(len(content))
+		}
+
+		artifacts = append(artifacts, artifact)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return artifacts, nil
+}
+
+// resolveArtifact maps name to a regular file inside boxDir, provided it is
+// selected by one of the output patterns.
+func resolveArtifact(boxDir string, patterns []string, name string) (string, error) {
+	if err := verifyPath(name); err != nil {
+		return "", err
+	}
+	if !matchAny(patterns, name) {
+		return "", fmt.Errorf("%s is not an output of this step", name)
+	}
+
+	// A path that resolves differently from the plain join went through a
+	// symlink, which could expose files that are not outputs.
+	p, err := securejoin.SecureJoin(boxDir, name)
+	if err != nil {
+		return "", err
+	}
+	if p != filepath.Join(boxDir, name) {
+		return "", fmt.Errorf("%s is not a regular file", name)
+	}
+
+	info, err := os.Lstat(p)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", name)
+	}
+
+	return p, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchPath(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
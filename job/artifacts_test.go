@@ -0,0 +1,95 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectArtifacts(t *testing.T) {
+	maxSize, maxTotal := config.MaxArtifactSize, config.MaxArtifactsSize
+	config.MaxArtifactSize, config.MaxArtifactsSize = 4, 6
+	defer func() { config.MaxArtifactSize, config.MaxArtifactsSize = maxSize, maxTotal }()
+
+	boxDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(boxDir, "reports"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(boxDir, "reports", "a.xml"), []byte("\x00\x01\x02"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(boxDir, "reports", "b.xml"), []byte("abcd"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(boxDir, "reports", "c.xml"), []byte("toolarge"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(boxDir, "main.cpp"), []byte("int main() {}"), 0644))
+	require.NoError(t, os.Symlink("/etc/passwd", filepath.Join(boxDir, "reports", "d.xml")))
+
+	artifacts, err := collectArtifacts(boxDir, []string{"reports/*.xml"})
+	require.NoError(t, err)
+	require.Len(t, artifacts, 3)
+
+	require.Equal(t, "reports/a.xml", artifacts[0].Path)
+	require.Equal(t, []byte("\x00\x01\x02"), artifacts[0].Content)
+	require.False(t, artifacts[0].Omitted)
+
+	// b.xml fits the per-file limit but not the remaining total budget.
+	require.Equal(t, "reports/b.xml", artifacts[1].Path)
+	require.True(t, artifacts[1].Omitted)
+	require.Nil(t, artifacts[1].Content)
+
+	require.Equal(t, "reports/c.xml", artifacts[2].Path)
+	require.Equal(t, int64(8), artifacts[2].Size)
+	require.True(t, artifacts[2].Omitted)
+}
+
+func TestResolveArtifact(t *testing.T) {
+	boxDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(boxDir, "out.png"), []byte("png"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(boxDir, "secret.txt"), []byte("s"), 0644))
+	require.NoError(t, os.Symlink("secret.txt", filepath.Join(boxDir, "link.png")))
+
+	p, err := resolveArtifact(boxDir, []string{"*.png"}, "out.png")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(boxDir, "out.png"), p)
+
+	_, err = resolveArtifact(boxDir, []string{"*.png"}, "secret.txt")
+	require.Error(t, err)
+
+	_, err = resolveArtifact(boxDir, []string{"*.png"}, "link.png")
+	require.Error(t, err)
+
+	_, err = resolveArtifact(boxDir, []string{"**"}, "../escape.png")
+	require.Error(t, err)
+}
+
+func TestGetArtifactPathWhileRunning(t *testing.T) {
+	storageDir := config.StorageDir
+	config.StorageDir = t.TempDir()
+	defer func() { config.StorageDir = storageDir }()
+
+	j := &Job{ID: "job", Procs: []Process{{Outputs: []string{"*.txt"}}, {}}}
+	require.NoError(t, os.MkdirAll(getProcFileDir(j.ID, 0), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(getProcFileDir(j.ID, 0), "out.txt"), []byte("1"), 0644))
+
+	_, err := j.GetArtifactPath(0, "out.txt")
+	require.Error(t, err)
+
+	// The first step has finished and the second one is still running.
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.next()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := j.GetArtifactPath(0, "out.txt")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetching an artifact waited for the running step")
+	}
+
+	_, err = j.GetArtifactPath(1, "out.txt")
+	require.Error(t, err)
+}
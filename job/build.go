@@ -0,0 +1,155 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/image"
+	"github.com/joshjms/castletown/sandbox"
+)
+
+// Limits of build steps that do not set their own, loose enough for package
+// managers.
+const (
+	BUILD_TIME_LIMIT_MS      = 10 * 60 * 1000
+	BUILD_MEMORY_LIMIT_MB    = 2048
+	BUILD_PROC_LIMIT         = 1024
+	BUILD_FILE_SIZE_LIMIT_MB = 1024
+	BUILD_NOFILE_LIMIT       = 1024
+)
+
+var (
+	buildsMu sync.Mutex
+	builds   = map[string]bool{}
+)
+
+// IsBuilding tells whether the build with the given ID is running.
+func IsBuilding(id string) bool {
+	buildsMu.Lock()
+	defer buildsMu.Unlock()
+
+	return builds[id]
+}
+
+// Build makes a new image by running steps on top of a base image and
+// saving what they change to its root filesystem as a new layer.
+type Build struct {
+	ID    string    `json:"id"`
+	Name  string    `json:"name"`
+	Base  string    `json:"base"`
+	Files []File    `json:"files"`
+	Procs []Process `json:"steps"`
+
+	// Principal is the name of who started the build.
+	Principal string `json:"-"`
+}
+
+// Run runs the build's steps one after another, each seeing the changes of
+// those before it, and registers the result as the image Name. Unlike job
+// steps, build steps run as the owner of the image's files, an unprivileged
+// host user, and with the host's network, so that they can install
+// packages. A step that does not finish with STATUS_OK fails the build; the
+// reports tell why.
+func (b *Build) Run(ctx context.Context) (*image.Image, []sandbox.Report, error) {
+	if err := image.ValidateName(b.Name); err != nil {
+		return nil, nil, err
+	}
+	if _, err := os.Lstat(image.Dir(b.Name)); err == nil {
+		return nil, nil, fmt.Errorf("image %q already exists", b.Name)
+	}
+
+	// Catch a base that cannot be built on before spending time on steps.
+	base, err := image.Get(b.Base)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !base.Layered {
+		return nil, nil, fmt.Errorf("image %q was not imported as layers, import it again to build on it", b.Base)
+	}
+
+	buildsMu.Lock()
+	if builds[b.ID] {
+		buildsMu.Unlock()
+		return nil, nil, fmt.Errorf("build %q is already running", b.ID)
+	}
+	builds[b.ID] = true
+	buildsMu.Unlock()
+
+	defer func() {
+		buildsMu.Lock()
+		delete(builds, b.ID)
+		buildsMu.Unlock()
+	}()
+
+	procs := make([]Process, len(b.Procs))
+	for i, proc := range b.Procs {
+		if proc.Image != "" && proc.Image != b.Base {
+			return nil, nil, fmt.Errorf("step %d: build steps run on the base image, not %q", i, proc.Image)
+		}
+		if proc.Interactor != nil || len(proc.Services) > 0 {
+			return nil, nil, fmt.Errorf("step %d: build steps cannot have an interactor or services", i)
+		}
+		proc.Image = b.Base
+		procs[i] = proc
+	}
+
+	buildDir := filepath.Join(config.OverlayFSDir, fmt.Sprintf("build-%s", b.ID))
+	defer os.RemoveAll(buildDir)
+	defer os.RemoveAll(getRootFileDir(b.ID))
+	defer forgetEvents(b.ID)
+
+	j := &Job{
+		ID:        b.ID,
+		Files:     b.Files,
+		Procs:     procs,
+		Principal: b.Principal,
+		upperDir:  filepath.Join(buildDir, "upper"),
+	}
+	if err := j.Prepare(ctx); err != nil {
+		return nil, nil, fmt.Errorf("error preparing build: %w", err)
+	}
+
+	reports, err := j.ExecuteAll(ctx)
+	if err != nil {
+		return nil, reports, fmt.Errorf("error executing build: %w", err)
+	}
+	for i, report := range reports {
+		if report.Status != sandbox.STATUS_OK {
+			return nil, reports, fmt.Errorf("step %d finished with %s", i, report.Status)
+		}
+	}
+
+	img, err := image.Commit(b.Base, b.Name, j.upperDir)
+	if err != nil {
+		return nil, reports, err
+	}
+	return img, reports, nil
+}
+
+// applyBuildProfile loosens the configuration of a build step and points
+// its root filesystem changes at the build's upper directory. Limits set
+// by the step itself are kept.
+func applyBuildProfile(cfg *sandbox.Config, proc Process, upperDir string) {
+	cfg.UpperDir = upperDir
+	cfg.HostNetwork = true
+	cfg.RootOwnsImage = true
+
+	if proc.TimeLimitMs == 0 {
+		cfg.TimeLimitMs = BUILD_TIME_LIMIT_MS
+	}
+	if proc.MemoryLimitMB == 0 {
+		cfg.Cgroup.Memory = BUILD_MEMORY_LIMIT_MB * 1024 * 1024
+	}
+	if proc.ProcLimit == 0 {
+		cfg.Cgroup.PidsLimit = BUILD_PROC_LIMIT
+	}
+	if proc.FileSizeLimitMB == 0 {
+		fsize := uint64(BUILD_FILE_SIZE_LIMIT_MB) * 1024 * 1024
+		cfg.Rlimit.Fsize = &sandbox.Rlimit{Hard: fsize, Soft: fsize}
+	}
+	cfg.Rlimit.NoFile = &sandbox.Rlimit{Hard: BUILD_NOFILE_LIMIT, Soft: BUILD_NOFILE_LIMIT}
+}
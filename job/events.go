@@ -0,0 +1,200 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/joshjms/castletown/sandbox"
+)
+
+// EventType tells what happened to a job.
+type EventType string
+
+const (
+	EVENT_JOB_ACCEPTED  EventType = "jobAccepted"
+	EVENT_STEP_STARTED  EventType = "stepStarted"
+	EVENT_STEP_FINISHED EventType = "stepFinished"
+	EVENT_JOB_DONE      EventType = "jobDone"
+	EVENT_OUTPUT        EventType = "output"
+)
+
+// MAX_SUBSCRIBER_OUTPUT is how many bytes of output may wait for a
+// subscriber. Output beyond it is dropped for that subscriber alone.
+const MAX_SUBSCRIBER_OUTPUT = 1024 * 1024
+
+// Event is published while a job runs. Step is the index of the step the
+// event is about, or of the next step to run for job events. Report is set
+// for EVENT_STEP_FINISHED and Output for EVENT_OUTPUT, which carries what
+// the step's process writes as it runs. Error is set for EVENT_JOB_DONE
+// when the job stopped because a step could not be run, as opposed to
+// failing.
+type Event struct {
+	Type   EventType            `json:"type"`
+	JobID  string               `json:"jobId"`
+	Step   int                  `json:"step"`
+	Steps  int                  `json:"steps"`
+	Report *sandbox.Report      `json:"report,omitempty"`
+	Output *sandbox.OutputChunk `json:"output,omitempty"`
+	Error  string               `json:"error,omitempty"`
+	Time   time.Time            `json:"time"`
+
+	// Principal is the name of who submitted the job.
+	Principal string `json:"-"`
+}
+
+var errSubscriptionClosed = errors.New("subscription closed")
+
+// eventBus hands the events of each job to its subscribers and keeps those
+// of the job's latest run, except output, for subscribers that come late.
+type eventBus struct {
+	subs    map[string]map[*Subscription]struct{}
+	history map[string][]Event
+
+	mu sync.Mutex
+}
+
+var events = &eventBus{
+	subs:    make(map[string]map[*Subscription]struct{}),
+	history: make(map[string][]Event),
+}
+
+// Subscription receives the events of one job in the order they happened.
+// Events queue up until Next takes them, so a slow subscriber never holds
+// the job up. Output is the exception: once MAX_SUBSCRIBER_OUTPUT bytes of
+// it are waiting, more is dropped and counted in the next chunk taken.
+type Subscription struct {
+	jobID  string
+	queue  []Event
+	notify chan struct{}
+	closed bool
+
+	queuedOutput int
+	dropped      map[string]int64
+
+	mu sync.Mutex
+}
+
+// Subscribe starts receiving the events of the job with the given ID. With
+// replay, the events of the job's current or latest run come first.
+func Subscribe(jobID string, replay bool) *Subscription {
+	s := &Subscription{
+		jobID:   jobID,
+		notify:  make(chan struct{}, 1),
+		dropped: make(map[string]int64),
+	}
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	if replay {
+		s.queue = append(s.queue, events.history[jobID]...)
+		if len(s.queue) > 0 {
+			s.notify <- struct{}{}
+		}
+	}
+	if events.subs[jobID] == nil {
+		events.subs[jobID] = make(map[*Subscription]struct{})
+	}
+	events.subs[jobID][s] = struct{}{}
+	return s
+}
+
+// Next waits for the next event.
+func (s *Subscription) Next(ctx context.Context) (Event, error) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return Event{}, errSubscriptionClosed
+		}
+		if len(s.queue) > 0 {
+			ev := s.queue[0]
+			s.queue = s.queue[1:]
+			if ev.Output != nil {
+				s.queuedOutput -= len(ev.Output.Data)
+			}
+			s.mu.Unlock()
+			return ev, nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.notify:
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		}
+	}
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	events.mu.Lock()
+	delete(events.subs[s.jobID], s)
+	if len(events.subs[s.jobID]) == 0 {
+		delete(events.subs, s.jobID)
+	}
+	events.mu.Unlock()
+
+	s.mu.Lock()
+	s.closed = true
+	s.queue = nil
+	s.mu.Unlock()
+}
+
+func (s *Subscription) push(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if ev.Output != nil {
+		chunk := *ev.Output
+		if s.queuedOutput+len(chunk.Data) > MAX_SUBSCRIBER_OUTPUT {
+			s.dropped[chunk.Stream] += chunk.Dropped + int64(len(chunk.Data))
+			return
+		}
+		chunk.Dropped += s.dropped[chunk.Stream]
+		delete(s.dropped, chunk.Stream)
+		s.queuedOutput += len(chunk.Data)
+		ev.Output = &chunk
+	}
+
+	s.queue = append(s.queue, ev)
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// publish hands ev to the subscribers of its job. A new run of the job
+// replaces the history of the previous one.
+func publish(ev Event) {
+	ev.Time = time.Now()
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	switch ev.Type {
+	case EVENT_OUTPUT:
+	case EVENT_JOB_ACCEPTED:
+		events.history[ev.JobID] = []Event{ev}
+	default:
+		events.history[ev.JobID] = append(events.history[ev.JobID], ev)
+	}
+
+	for s := range events.subs[ev.JobID] {
+		s.push(ev)
+	}
+}
+
+// forgetEvents drops the history of the job with the given ID.
+func forgetEvents(jobID string) {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	delete(events.history, jobID)
+}
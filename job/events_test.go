@@ -0,0 +1,95 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joshjms/castletown/sandbox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	j := &Job{ID: "events", Procs: make([]Process, 2)}
+	defer forgetEvents(j.ID)
+
+	live := Subscribe(j.ID, false)
+	defer live.Close()
+
+	j.publish(EVENT_JOB_ACCEPTED, 0, nil, nil)
+	j.publish(EVENT_STEP_STARTED, 0, nil, nil)
+	j.publish(EVENT_STEP_FINISHED, 0, &sandbox.Report{Status: sandbox.STATUS_OK}, nil)
+
+	// A late subscriber catches up on the run so far.
+	late := Subscribe(j.ID, true)
+	defer late.Close()
+
+	j.publish(EVENT_JOB_DONE, 1, nil, nil)
+
+	want := []EventType{EVENT_JOB_ACCEPTED, EVENT_STEP_STARTED, EVENT_STEP_FINISHED, EVENT_JOB_DONE}
+	for _, sub := range []*Subscription{live, late} {
+		for i, typ := range want {
+			ev, err := sub.Next(ctx)
+			require.NoError(t, err)
+			require.Equal(t, typ, ev.Type, "event %d", i)
+			require.Equal(t, j.ID, ev.JobID)
+			require.Equal(t, 2, ev.Steps)
+			if typ == EVENT_STEP_FINISHED {
+				require.NotNil(t, ev.Report)
+			}
+		}
+	}
+
+	// The next run of the job starts a new history.
+	j.publish(EVENT_JOB_ACCEPTED, 1, nil, nil)
+	again := Subscribe(j.ID, true)
+	defer again.Close()
+	ev, err := again.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, EVENT_JOB_ACCEPTED, ev.Type)
+	require.Equal(t, 1, ev.Step)
+
+	short, cancelShort := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelShort()
+	_, err = again.Next(short)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestEventsOutput(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	j := &Job{ID: "events-output", Procs: make([]Process, 1)}
+	defer forgetEvents(j.ID)
+
+	sub := Subscribe(j.ID, false)
+	defer sub.Close()
+
+	big := make([]byte, MAX_SUBSCRIBER_OUTPUT)
+	for _, data := range [][]byte{big, []byte("lost"), []byte("also lost")} {
+		publish(Event{Type: EVENT_OUTPUT, JobID: j.ID, Output: &sandbox.OutputChunk{Stream: sandbox.STREAM_STDOUT, Data: data}})
+	}
+
+	ev, err := sub.Next(ctx)
+	require.NoError(t, err)
+	require.Len(t, ev.Output.Data, MAX_SUBSCRIBER_OUTPUT)
+
+	// The subscriber fell behind, so the chunks after the first were
+	// dropped; once it caught up the next chunk tells it so.
+	publish(Event{Type: EVENT_OUTPUT, JobID: j.ID, Output: &sandbox.OutputChunk{Stream: sandbox.STREAM_STDOUT, Data: []byte("later")}})
+	ev, err = sub.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "later", string(ev.Output.Data))
+	require.EqualValues(t, len("lost")+len("also lost"), ev.Output.Dropped)
+
+	// Output is not replayed to late subscribers.
+	late := Subscribe(j.ID, true)
+	defer late.Close()
+	short, cancelShort := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelShort()
+	_, err = late.Next(short)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
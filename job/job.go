@@ -2,28 +2,84 @@ package job
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
+	"time"
 
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/image"
+	"github.com/joshjms/castletown/logging"
+	"github.com/joshjms/castletown/metrics"
 	"github.com/joshjms/castletown/sandbox"
+	"github.com/joshjms/castletown/tracing"
 )
 
+// ErrNotOwner is returned for requests to append to the job of another
+// principal.
+var ErrNotOwner = errors.New("job belongs to another principal")
+
+// InvalidError is returned for jobs that cannot run as the client sent
+// them, such as those naming an unknown image or an output that no earlier
+// step has.
+type InvalidError struct {
+	Err error
+}
+
+func (e *InvalidError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
 type Job struct {
 	ID    string    `json:"id"`
 	Files []File    `json:"files"`
 	Procs []Process `json:"steps"`
 
-	step int
+	// Principal is the name of who submitted the job, empty without
+	// authentication. Only they may append to the job or use its results.
+	Principal string `json:"-"`
+
+	step    int
+	reports []sandbox.Report
+
+	// upperDir is set for builds, whose steps all change the same root
+	// filesystem.
+	upperDir string
 
 	mu sync.Mutex
+
+	// outputs holds the Outputs of each finished step. It has a lock of its
+	// own so that their artifacts can be fetched while mu is held by a run
+	// of later steps.
+	outputs   [][]string
+	outputsMu sync.Mutex
 }
 
 type File struct {
-	Name    string `json:"name"`
-	Content string `json:"content"`
+	Name    string   `json:"name"`
+	Content []byte   `json:"content"`
+	Type    FileType `json:"type,omitempty"`
+	Mode    uint32   `json:"mode,omitempty"`
 }
 
+// FileType tells how a File is materialized in the box. Archives are
+// extracted into the directory given by the file name.
+type FileType string
+
+const (
+	FILE_TYPE_REGULAR FileType = "file"
+	FILE_TYPE_DIR     FileType = "dir"
+	FILE_TYPE_TAR     FileType = "tar"
+	FILE_TYPE_ZIP     FileType = "zip"
+)
+
 type Process struct {
+	Name          string   `json:"name"`
 	Image         string   `json:"image"`
 	Cmd           []string `json:"cmd"`
 	Stdin         string   `json:"stdin"`
@@ -32,44 +88,178 @@ type Process struct {
 	ProcLimit     int64    `json:"procLimit"`
 	Files         []string `json:"files"`
 	Persist       []string `json:"persist"`
+	Outputs       []string `json:"outputs"`
+
+	StdinFile       string `json:"stdinFile"`
+	StdinFrom       string `json:"stdinFrom"`
+	StdoutFile      string `json:"stdoutFile"`
+	StderrFile      string `json:"stderrFile"`
+	FileSizeLimitMB int64  `json:"fileSizeLimitMB"`
+
+	// Env, WorkingDir and User take priority over the image's config, which
+	// in turn takes priority over the sandbox defaults. Env entries replace
+	// those of the same name. User is "name", "uid", "name:group" or
+	// "uid:gid", resolved against the image's /etc/passwd and /etc/group.
+	Env        []string `json:"env,omitempty"`
+	WorkingDir string   `json:"workingDir,omitempty"`
+	User       string   `json:"user,omitempty"`
+
+	// Interactor turns the step into an interactive run: the interactor is
+	// started next to this process with the stdout of each connected to the
+	// stdin of the other.
+	Interactor *Process `json:"interactor,omitempty"`
+
+	// Services are started before this process and stopped after it. All of
+	// them share one loopback-only network namespace and one IPC namespace,
+	// so the process can reach them on 127.0.0.1.
+	Services []Process `json:"services,omitempty"`
+
+	// Readiness tells when a service is ready; the step's process only
+	// starts once every service with a check has passed it.
+	Readiness *Readiness `json:"readiness,omitempty"`
+}
+
+type Readiness struct {
+	TCPPort   int   `json:"tcpPort"`
+	TimeoutMs int64 `json:"timeoutMs"`
 }
 
-func (j *Job) Prepare() error {
+// Prepare checks the job and makes the directories of its files, tracing it
+// as a child of the span in ctx.
+func (j *Job) Prepare(ctx context.Context) (err error) {
+	_, span := tracing.Start(ctx, "job.Prepare", tracing.KEY_JOB_ID.String(j.ID))
+	defer func() { tracing.End(span, err) }()
+
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
 	if len(j.Procs) == 0 {
-		return fmt.Errorf("no processes specified")
+		return &InvalidError{errors.New("no processes specified")}
 	}
 
 	if err := verifyImages(j.Procs); err != nil {
-		return fmt.Errorf("invalid images: %w", err)
+		return &InvalidError{fmt.Errorf("invalid images: %w", err)}
+	}
+
+	if err := verifyFiles(j.Files, j.Procs); err != nil {
+		return &InvalidError{fmt.Errorf("invalid files: %w", err)}
+	}
+
+	if err := verifySteps(j.Procs); err != nil {
+		return &InvalidError{fmt.Errorf("invalid steps: %w", err)}
 	}
 
+	// Invalid requests are the client's to report, but this is not.
 	if err := prepareFileDirs(j.ID, j.Procs); err != nil {
-		return fmt.Errorf("error preparing file directories: %w", err)
+		err = fmt.Errorf("error preparing file directories: %w", err)
+		j.logger(j.step).Error("job setup failed", logging.Error(err))
+		return err
 	}
 
 	return nil
 }
 
+// ExecuteAll runs the steps that have not run yet, publishing an event as
+// the job is accepted, as each step starts and finishes and as the job is
+// done. A step that cannot be run fails the job, but the reports of the
+// steps that ran before it are returned with the error, so that what they
+// used is still accounted for.
 func (j *Job) ExecuteAll(ctx context.Context) ([]sandbox.Report, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
 	var reports []sandbox.Report
 
+	j.publish(EVENT_JOB_ACCEPTED, j.step, nil, nil)
 	for j.step < len(j.Procs) {
-		report, err := j.execute(ctx)
+		step := j.step
+		j.publish(EVENT_STEP_STARTED, step, nil, nil)
+
+		// Sandboxes add their own ID to the lines they log.
+		log := j.logger(step)
+		stepLog := log.With(logging.KEY_SANDBOX_ID, stepSandboxId(j.ID, step))
+		stepLog.Debug("step started", "image", j.Procs[step].Image)
+
+		stepCtx, span := tracing.Start(ctx, "job.execute",
+			tracing.KEY_JOB_ID.String(j.ID),
+			tracing.KEY_STEP.Int(step),
+			tracing.KEY_IMAGE.String(j.Procs[step].Image),
+		)
+		report, err := j.execute(logging.NewContext(stepCtx, log))
+		if err == nil {
+			span.SetAttributes(tracing.KEY_STATUS.String(string(report.Status)))
+		}
+		tracing.End(span, err)
 		if err != nil {
-			return nil, err
+			stepLog.Error("step failed", logging.Error(err))
+			j.publish(EVENT_JOB_DONE, step, nil, err)
+			return reports, err
 		}
+		stepLog.Info("step finished", "status", report.Status, "cpu_time_us", report.CPUTime, "memory_bytes", report.Memory)
+		j.publish(EVENT_STEP_FINISHED, step, &report, nil)
+		observeReport(j.Procs[step].Image, report)
 		reports = append(reports, report)
 	}
+	j.publish(EVENT_JOB_DONE, j.step, nil, nil)
 
 	return reports, nil
 }
 
+// logger returns the logger of lines about step of j.
+func (j *Job) logger(step int) *slog.Logger {
+	return slog.With(logging.KEY_JOB_ID, j.ID, logging.KEY_STEP, step)
+}
+
+// stepSandboxId is the ID of the sandbox that runs the process of step.
+func stepSandboxId(jobId string, step int) string {
+	return fmt.Sprintf("%s-%d", jobId, step)
+}
+
+// destroySandbox destroys a sandbox nothing waits on anymore, logging
+// failures to the logger ctx carries.
+func destroySandbox(ctx context.Context, id string) {
+	if err := sandbox.GetManager().DestroySandbox(ctx, id); err != nil {
+		logging.FromContext(ctx).Error("error destroying sandbox", logging.KEY_SANDBOX_ID, id, logging.Error(err))
+	}
+}
+
+// observeReport counts a finished step in the metrics.
+func observeReport(image string, report sandbox.Report) {
+	metrics.Verdicts.WithLabelValues(string(report.Status), image).Inc()
+	metrics.StepCPU.Observe((time.Duration(report.CPUTime) * time.Microsecond).Seconds())
+	metrics.StepMemory.Observe(float64(report.Memory))
+}
+
+// newOutputStream publishes the output of step as it runs.
+func (j *Job) newOutputStream(step int) *sandbox.OutputStream {
+	id, steps, principal := j.ID, len(j.Procs), j.Principal
+	return sandbox.NewOutputStream(config.OutputStreamRate, func(chunk sandbox.OutputChunk) {
+		publish(Event{
+			Type:      EVENT_OUTPUT,
+			JobID:     id,
+			Step:      step,
+			Steps:     steps,
+			Output:    &chunk,
+			Principal: principal,
+		})
+	})
+}
+
+func (j *Job) publish(typ EventType, step int, report *sandbox.Report, err error) {
+	ev := Event{
+		Type:      typ,
+		JobID:     j.ID,
+		Step:      step,
+		Steps:     len(j.Procs),
+		Report:    report,
+		Principal: j.Principal,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	publish(ev)
+}
+
 func (j *Job) execute(ctx context.Context) (sandbox.Report, error) {
 	proc := j.Procs[j.step]
 	fileDeps, err := getFileDependencies(j.ID, j.Procs, j.Files, j.step)
@@ -77,12 +267,166 @@ func (j *Job) execute(ctx context.Context) (sandbox.Report, error) {
 		return sandbox.Report{}, fmt.Errorf("error getting file dependencies: %w", err)
 	}
 
+	cfg, err := makeConfig(proc, getProcFileDir(j.ID, j.step), fileDeps)
+	if err != nil {
+		return sandbox.Report{}, fmt.Errorf("cannot configure process %d: %w", j.step, err)
+	}
+	if j.upperDir != "" {
+		applyBuildProfile(cfg, proc, j.upperDir)
+	}
+	cfg.Output = j.newOutputStream(j.step)
+	if proc.StdinFrom != "" {
+		stdin, err := j.getStepOutput(proc.StdinFrom)
+		if err != nil {
+			return sandbox.Report{}, &InvalidError{fmt.Errorf("cannot resolve stdinFrom of process %d: %w", j.step, err)}
+		}
+		cfg.Stdin = string(stdin)
+	}
+
+	containerId := stepSandboxId(j.ID, j.step)
+	if err := sandbox.GetManager().NewSandbox(containerId, cfg); err != nil {
+		return sandbox.Report{}, fmt.Errorf("cannot create sandbox for process %d: %v", j.step, err)
+	}
+	defer destroySandbox(ctx, containerId)
+
+	var report sandbox.Report
+	switch {
+	case proc.Interactor != nil:
+		report, err = j.executeInteractive(ctx, containerId)
+	case len(proc.Services) > 0:
+		report, err = j.executePod(ctx, containerId, cfg)
+	default:
+		report, err = sandbox.GetManager().RunSandbox(ctx, containerId)
+	}
+	if err != nil {
+		return sandbox.Report{}, fmt.Errorf("error running process %d: %v", j.step, err)
+	}
+
+	report.Artifacts, err = collectArtifacts(cfg.BoxDir, proc.Outputs)
+	if err != nil {
+		return sandbox.Report{}, fmt.Errorf("error collecting outputs of process %d: %v", j.step, err)
+	}
+
+	j.reports = append(j.reports, report)
+	j.next()
+
+	return report, nil
+}
+
+// executeInteractive runs the current step's interactor next to the already
+// created solution sandbox, connected to it through pipes.
+func (j *Job) executeInteractive(ctx context.Context, solutionId string) (sandbox.Report, error) {
+	interactor := *j.Procs[j.step].Interactor
+
+	fileDeps, err := getInteractorFileDependencies(j.ID, j.Procs, j.Files, j.step)
+	if err != nil {
+		return sandbox.Report{}, fmt.Errorf("error getting interactor file dependencies: %w", err)
+	}
+
+	cfg, err := makeConfig(interactor, getInteractorFileDir(j.ID, j.step), fileDeps)
+	if err != nil {
+		return sandbox.Report{}, fmt.Errorf("cannot configure interactor: %w", err)
+	}
+
+	interactorId := fmt.Sprintf("%s-%d-interactor", j.ID, j.step)
+	if err := sandbox.GetManager().NewSandbox(interactorId, cfg); err != nil {
+		return sandbox.Report{}, fmt.Errorf("cannot create interactor sandbox: %v", err)
+	}
+	defer destroySandbox(ctx, interactorId)
+
+	return sandbox.GetManager().RunInteractive(ctx, solutionId, interactorId)
+}
+
+// executePod runs the current step's process in a pod together with its
+// services. mainCfg is the configuration of the already created sandbox of
+// the step's process.
+func (j *Job) executePod(ctx context.Context, mainId string, mainCfg *sandbox.Config) (sandbox.Report, error) {
+	podId := fmt.Sprintf("%s-%d", j.ID, j.step)
+	pod, err := sandbox.GetManager().NewPod(podId)
+	if err != nil {
+		return sandbox.Report{}, fmt.Errorf("cannot create pod: %v", err)
+	}
+	defer func() {
+		if err := sandbox.GetManager().DestroyPod(podId); err != nil {
+			logging.FromContext(ctx).Error("error destroying pod", "pod_id", podId, logging.Error(err))
+		}
+	}()
+
+	mainCfg.NetNSPath = pod.NetNSPath()
+	mainCfg.IPCNSPath = pod.IPCNSPath()
+
+	services := make([]sandbox.PodService, len(j.Procs[j.step].Services))
+	for i, svc := range j.Procs[j.step].Services {
+		fileDeps, err := getServiceFileDependencies(j.ID, j.Procs, j.Files, j.step, i)
+		if err != nil {
+			return sandbox.Report{}, fmt.Errorf("error getting file dependencies of service %d: %w", i, err)
+		}
+
+		cfg, err := makeConfig(svc, getServiceFileDir(j.ID, j.step, i), fileDeps)
+		if err != nil {
+			return sandbox.Report{}, fmt.Errorf("cannot configure service %d: %w", i, err)
+		}
+		cfg.NetNSPath = pod.NetNSPath()
+		cfg.IPCNSPath = pod.IPCNSPath()
+
+		serviceId := fmt.Sprintf("%s-%d-service-%d", j.ID, j.step, i)
+		if err := sandbox.GetManager().NewSandbox(serviceId, cfg); err != nil {
+			return sandbox.Report{}, fmt.Errorf("cannot create sandbox for service %d: %v", i, err)
+		}
+		defer destroySandbox(ctx, serviceId)
+
+		services[i] = sandbox.PodService{ID: serviceId}
+		if svc.Readiness != nil {
+			services[i].Readiness = &sandbox.Readiness{
+				TCPPort:   svc.Readiness.TCPPort,
+				TimeoutMs: svc.Readiness.TimeoutMs,
+			}
+		}
+	}
+
+	return sandbox.GetManager().RunPod(ctx, podId, mainId, services)
+}
+
+// makeConfig builds the sandbox configuration for proc, applying the
+// image's config and then proc's own settings on top of the defaults. An
+// empty Cmd runs the image's entrypoint and command; otherwise Cmd is run as
+// is, without the entrypoint.
+func makeConfig(proc Process, boxDir string, fileDeps []sandbox.File) (*sandbox.Config, error) {
+	imgConfig, err := image.GetConfig(proc.Image)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config of image %q: %w", proc.Image, err)
+	}
+
 	cfg := sandbox.GetDefaultConfig()
 	cfg.Args = proc.Cmd
+	if len(cfg.Args) == 0 {
+		cfg.Args = append(append([]string{}, imgConfig.Entrypoint...), imgConfig.Cmd...)
+	}
 	cfg.RootfsImageDir = getImageDir(proc.Image)
-	cfg.BoxDir = getProcFileDir(j.ID, j.step)
+	cfg.RootfsLayers, err = image.LayerDirs(proc.Image)
+	if err != nil {
+		return nil, err
+	}
+	cfg.BoxDir = boxDir
 	cfg.Files = fileDeps
 
+	cfg.Env = mergeEnv(cfg.Env, imgConfig.Env, proc.Env)
+	if imgConfig.WorkingDir != "" {
+		cfg.Cwd = imgConfig.WorkingDir
+	}
+	if proc.WorkingDir != "" {
+		cfg.Cwd = proc.WorkingDir
+	}
+
+	user := imgConfig.User
+	if proc.User != "" {
+		user = proc.User
+	}
+	cfg.UID, cfg.GID, err = image.LookupUser(proc.Image, user)
+	if err != nil {
+		return nil, err
+	}
+
 	if proc.TimeLimitMs > 0 {
 		cfg.TimeLimitMs = int64(proc.TimeLimitMs)
 	}
@@ -92,26 +436,72 @@ func (j *Job) execute(ctx context.Context) (sandbox.Report, error) {
 	if proc.ProcLimit > 0 {
 		cfg.Cgroup.PidsLimit = proc.ProcLimit
 	}
+	if proc.FileSizeLimitMB > 0 {
+		fsize := uint64(proc.FileSizeLimitMB) * 1024 * 1024
+		cfg.Rlimit.Fsize = &sandbox.Rlimit{Hard: fsize, Soft: fsize}
+	}
 	cfg.Stdin = proc.Stdin
+	cfg.StdinFile = proc.StdinFile
+	cfg.StdoutFile = proc.StdoutFile
+	cfg.StderrFile = proc.StderrFile
+	cfg.MaxOutputSize = config.MaxOutputSize
 
-	containerId := fmt.Sprintf("%s-%d", j.ID, j.step)
-	if err := sandbox.GetManager().NewSandbox(containerId, cfg); err != nil {
-		return sandbox.Report{}, fmt.Errorf("cannot create sandbox for process %d: %v", j.step, err)
-	}
-	defer sandbox.GetManager().DestroySandbox(containerId)
+	return cfg, nil
+}
 
-	report, err := sandbox.GetManager().RunSandbox(ctx, containerId)
+// getStepOutput returns the captured output referenced by ref, which has the
+// form "<step>.stdout" or "<step>.stderr". The step must already have run,
+// and its output must not have been truncated, so that the next step never
+// takes part of it for the whole.
+func (j *Job) getStepOutput(ref string) ([]byte, error) {
+	step, stream, err := parseStepOutputRef(j.Procs, j.step, ref)
 	if err != nil {
-		return sandbox.Report{}, fmt.Errorf("error running process %d: %v", j.step, err)
+		return nil, err
 	}
 
-	j.next()
+	if step >= len(j.reports) {
+		return nil, fmt.Errorf("step %d has not been executed", step)
+	}
 
-	return report, nil
+	if stream == "stderr" {
+		if j.Procs[step].StderrFile != "" {
+			return nil, fmt.Errorf("step %d redirects stderr to a file, use stdinFile instead", step)
+		}
+		if j.reports[step].StderrTruncated {
+			return nil, fmt.Errorf("stderr of step %d was truncated", step)
+		}
+		return j.reports[step].Stderr, nil
+	}
+
+	if j.Procs[step].StdoutFile != "" {
+		return nil, fmt.Errorf("step %d redirects stdout to a file, use stdinFile instead", step)
+	}
+	if j.reports[step].StdoutTruncated {
+		return nil, fmt.Errorf("stdout of step %d was truncated", step)
+	}
+	return j.reports[step].Stdout, nil
+}
+
+// GetArtifactPath returns the host path of an output file produced by an
+// already executed step. Only files selected by the step's Outputs are
+// exposed. It does not wait for the steps still running.
+func (j *Job) GetArtifactPath(step int, name string) (string, error) {
+	j.outputsMu.Lock()
+	if step < 0 || step >= len(j.outputs) {
+		j.outputsMu.Unlock()
+		return "", fmt.Errorf("step %d has not been executed", step)
+	}
+	outputs := j.outputs[step]
+	j.outputsMu.Unlock()
+
+	return resolveArtifact(getProcFileDir(j.ID, step), outputs, name)
 }
 
 func (j *Job) next() bool {
 	if j.step < len(j.Procs) {
+		j.outputsMu.Lock()
+		j.outputs = append(j.outputs, j.Procs[j.step].Outputs)
+		j.outputsMu.Unlock()
 		j.step++
 		return true
 	}
@@ -1,12 +1,16 @@
 package job_test
 
 import (
+	"bufio"
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/image"
 	"github.com/joshjms/castletown/job"
 	"github.com/joshjms/castletown/sandbox"
 	"github.com/stretchr/testify/require"
@@ -47,7 +51,7 @@ func TestJob(t *testing.T) {
 		Files: []job.File{
 			{
 				Name: "main.cpp",
-				Content: `
+				Content: []byte(`
 #include <iostream>
 int main() {
 	int n;
@@ -55,12 +59,12 @@ int main() {
 	std::cout << n * n << std::endl;
 	return 0;
 }
-`,
+`),
 			},
 		},
 	}
 
-	err = j.Prepare()
+	err = j.Prepare(context.Background())
 	require.NoError(t, err, "error preparing job: %v", err)
 
 	reports, err := j.ExecuteAll(context.Background())
@@ -68,7 +72,7 @@ int main() {
 	require.Len(t, reports, 2, "expected 2 reports, got %d", len(reports))
 	require.Equal(t, sandbox.STATUS_OK, reports[0].Status, "expected first report status to be OK, got %v", reports[0].Status)
 	require.Equal(t, sandbox.STATUS_OK, reports[1].Status, "expected second report status to be OK, got %v", reports[1].Status)
-	require.Equal(t, "25\n", reports[1].Stdout, "expected second report output to be '25', got '%s'", reports[1].Stdout)
+	require.Equal(t, "25\n", string(reports[1].Stdout), "expected second report output to be '25', got '%s'", reports[1].Stdout)
 }
 
 func TestJobAppend(t *testing.T) {
@@ -96,7 +100,7 @@ func TestJobAppend(t *testing.T) {
 		Files: []job.File{
 			{
 				Name: "main.cpp",
-				Content: `
+				Content: []byte(`
 #include <iostream>
 int main() {
 	int n;
@@ -104,13 +108,13 @@ int main() {
 	std::cout << n * n << std::endl;
 	return 0;
 }
-`,
+`),
 			},
 		},
 	}
 
 	pool.AddOrAppendJob(firstJob)
-	err = firstJob.Prepare()
+	err = firstJob.Prepare(context.Background())
 	require.NoError(t, err, "error preparing job: %v", err)
 
 	reports, err := firstJob.ExecuteAll(context.Background())
@@ -119,7 +123,7 @@ int main() {
 	require.Len(t, reports, 2, "expected 2 reports, got %d", len(reports))
 	require.Equal(t, sandbox.STATUS_OK, reports[0].Status, "expected first report status to be OK, got %v", reports[0].Status)
 	require.Equal(t, sandbox.STATUS_OK, reports[1].Status, "expected second report status to be OK, got %v", reports[1].Status)
-	require.Equal(t, "25\n", reports[1].Stdout, "expected second report output to be '25', got '%s'", reports[1].Stdout)
+	require.Equal(t, "25\n", string(reports[1].Stdout), "expected second report output to be '25', got '%s'", reports[1].Stdout)
 
 	anotherJob := &job.Job{
 		ID: jobId,
@@ -144,9 +148,196 @@ int main() {
 	require.NoError(t, err, "error executing pooled job: %v", err)
 	require.Len(t, reports, 1, "expected 1 report, got %d", len(reports))
 	require.Equal(t, sandbox.STATUS_OK, reports[0].Status, "expected first report status to be OK, got %v", reports[0].Status)
-	require.Equal(t, "100\n", reports[0].Stdout, "expected first report output to be '100', got '%s'", reports[0].Stdout)
+	require.Equal(t, "100\n", string(reports[0].Stdout), "expected first report output to be '100', got '%s'", reports[0].Stdout)
 
 	pool.RemoveJob(jobId)
 	_, exists = pool.Jobs[jobId]
 	require.False(t, exists, "expected job to be removed from pool")
 }
+
+func TestJobInteractive(t *testing.T) {
+	j := &job.Job{
+		ID: uuid.NewString(),
+		Procs: []job.Process{
+			{
+				Image: "gcc:15-bookworm",
+				Cmd:   []string{"/bin/sh", "-c", "read n; echo $((n * 2))"},
+				Interactor: &job.Process{
+					Image: "gcc:15-bookworm",
+					Cmd:   []string{"/bin/sh", "-c", "echo 21; read ans; [ \"$ans\" = 42 ]"},
+				},
+			},
+		},
+	}
+
+	err := j.Prepare(context.Background())
+	require.NoError(t, err, "error preparing job: %v", err)
+
+	reports, err := j.ExecuteAll(context.Background())
+	require.NoError(t, err, "error executing job: %v", err)
+	require.Len(t, reports, 1, "expected 1 report, got %d", len(reports))
+	require.NotNil(t, reports[0].Interactor, "expected interactor report")
+	require.Equal(t, sandbox.VERDICT_ACCEPTED, reports[0].Verdict, "expected verdict to be ACCEPTED, got %v", reports[0].Verdict)
+}
+
+func TestJobPod(t *testing.T) {
+	j := &job.Job{
+		ID: uuid.NewString(),
+		Procs: []job.Process{
+			{
+				Image: "gcc:15-bookworm",
+				Cmd:   []string{"perl", "-MIO::Socket::INET", "-e", `$c = IO::Socket::INET->new("127.0.0.1:8080") or die; print <$c>`},
+				Services: []job.Process{
+					{
+						Image:       "gcc:15-bookworm",
+						Cmd:         []string{"perl", "-MIO::Socket::INET", "-e", `$s = IO::Socket::INET->new(LocalPort => 8080, Listen => 1, ReuseAddr => 1) or die; while ($c = $s->accept) { print $c "hello\n"; close $c }`},
+						TimeLimitMs: 10000,
+						Readiness:   &job.Readiness{TCPPort: 8080, TimeoutMs: 5000},
+					},
+				},
+			},
+		},
+	}
+
+	err := j.Prepare(context.Background())
+	require.NoError(t, err, "error preparing job: %v", err)
+
+	reports, err := j.ExecuteAll(context.Background())
+	require.NoError(t, err, "error executing job: %v", err)
+	require.Len(t, reports, 1, "expected 1 report, got %d", len(reports))
+	require.Equal(t, sandbox.STATUS_OK, reports[0].Status, "expected status to be OK, got %v", reports[0].Status)
+	require.Equal(t, "hello\n", string(reports[0].Stdout), "expected output to be 'hello', got '%s'", reports[0].Stdout)
+	require.Len(t, reports[0].Services, 1, "expected 1 service report")
+	require.Equal(t, sandbox.STATUS_TERMINATED, reports[0].Services[0].Status, "expected service to be terminated, got %v", reports[0].Services[0].Status)
+}
+
+func TestJobSession(t *testing.T) {
+	j := &job.Job{
+		ID: uuid.NewString(),
+		Procs: []job.Process{
+			{
+				Image: "gcc:15-bookworm",
+				Cmd:   []string{"/bin/sh", "-c", `while read l; do echo "> $l"; done`},
+			},
+		},
+	}
+
+	err := j.Prepare(context.Background())
+	require.NoError(t, err, "error preparing job: %v", err)
+
+	sess, err := j.StartSession(context.Background(), sandbox.SessionLimits{IdleTimeout: 5 * time.Second}, nil)
+	require.NoError(t, err, "error starting session: %v", err)
+	defer sess.Close()
+
+	stdout := bufio.NewReader(sess.Stdout())
+	for _, input := range []string{"1 + 1", "print(2)"} {
+		_, err := sess.Write([]byte(input + "\n"))
+		require.NoError(t, err)
+
+		line, err := stdout.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "> "+input+"\n", line)
+	}
+
+	require.NoError(t, sess.CloseStdin())
+
+	report, err := sess.Wait()
+	require.NoError(t, err, "error waiting for session: %v", err)
+	require.Equal(t, sandbox.STATUS_OK, report.Status, "expected status to be OK, got %v", report.Status)
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(config.StorageDir, j.ID))
+		return os.IsNotExist(err)
+	}, 5*time.Second, 50*time.Millisecond, "session files were not removed")
+}
+
+func TestJobSessionRemovesFilesOnError(t *testing.T) {
+	j := &job.Job{
+		ID:    uuid.NewString(),
+		Procs: []job.Process{{Image: "gcc:15-bookworm", Stdin: "1 + 1"}},
+	}
+
+	dir := filepath.Join(config.StorageDir, j.ID, "proc-0")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	_, err := j.StartSession(context.Background(), sandbox.SessionLimits{}, nil)
+	require.Error(t, err)
+	require.NoDirExists(t, filepath.Join(config.StorageDir, j.ID))
+}
+
+func TestJobSessionIdleTimeout(t *testing.T) {
+	j := &job.Job{
+		ID: uuid.NewString(),
+		Procs: []job.Process{
+			{
+				Image: "gcc:15-bookworm",
+				Cmd:   []string{"cat"},
+			},
+		},
+	}
+
+	err := j.Prepare(context.Background())
+	require.NoError(t, err, "error preparing job: %v", err)
+
+	sess, err := j.StartSession(context.Background(), sandbox.SessionLimits{IdleTimeout: 500 * time.Millisecond}, nil)
+	require.NoError(t, err, "error starting session: %v", err)
+	defer sess.Close()
+
+	report, err := sess.Wait()
+	require.NoError(t, err, "error waiting for session: %v", err)
+	require.Equal(t, sandbox.STATUS_TERMINATED, report.Status, "expected status to be TERMINATED, got %v", report.Status)
+}
+
+func TestJobTerminalSession(t *testing.T) {
+	j := &job.Job{
+		ID: uuid.NewString(),
+		Procs: []job.Process{
+			{
+				Image: "gcc:15-bookworm",
+				Cmd:   []string{"/bin/sh", "-c", "[ -t 0 ] && [ -t 1 ] && stty size"},
+			},
+		},
+	}
+
+	err := j.Prepare(context.Background())
+	require.NoError(t, err, "error preparing job: %v", err)
+
+	sess, err := j.StartSession(context.Background(), sandbox.SessionLimits{IdleTimeout: 5 * time.Second}, &sandbox.Terminal{Width: 100, Height: 30})
+	require.NoError(t, err, "error starting session: %v", err)
+	defer sess.Close()
+
+	line, _ := bufio.NewReader(sess.Stdout()).ReadString('\n')
+	require.Equal(t, "30 100\r\n", line)
+
+	report, err := sess.Wait()
+	require.NoError(t, err, "error waiting for session: %v", err)
+	require.Equal(t, sandbox.STATUS_OK, report.Status, "expected status to be OK, got %v", report.Status)
+}
+
+func TestBuild(t *testing.T) {
+	name := "test-build:" + uuid.NewString()[:8]
+	b := &job.Build{
+		ID:   uuid.NewString(),
+		Name: name,
+		Base: "gcc:15-bookworm",
+		Procs: []job.Process{
+			{Cmd: []string{"sh", "-c", "echo 'int main() { return 7; }' > /usr/local/src/seven.c"}},
+			{Cmd: []string{"gcc", "-o", "/usr/local/bin/seven", "/usr/local/src/seven.c"}},
+		},
+	}
+
+	img, reports, err := b.Run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	require.Equal(t, name, img.Name)
+	t.Cleanup(func() { image.Delete(name) })
+
+	j := &job.Job{
+		ID:    uuid.NewString(),
+		Procs: []job.Process{{Image: name, Cmd: []string{"/usr/local/bin/seven"}}},
+	}
+	require.NoError(t, j.Prepare(context.Background()))
+	reports, err = j.ExecuteAll(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 7, reports[0].ExitCode)
+}
@@ -1,6 +1,11 @@
 package job
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+
+	"github.com/joshjms/castletown/metrics"
+)
 
 var jp *JobPool
 
@@ -28,17 +33,23 @@ func (jp *JobPool) GetJob(id string) (*Job, bool) {
 	return job, exists
 }
 
-func (jp *JobPool) AddOrAppendJob(job *Job) *Job {
+// AddOrAppendJob adds job to the pool, or appends its files and steps to
+// the job of the same ID, which must have the same principal.
+func (jp *JobPool) AddOrAppendJob(job *Job) (*Job, error) {
 	jp.mu.Lock()
 	defer jp.mu.Unlock()
 
 	if existingJob, exists := jp.Jobs[job.ID]; exists {
+		if existingJob.Principal != job.Principal {
+			return nil, fmt.Errorf("job %q: %w", job.ID, ErrNotOwner)
+		}
 		existingJob.append(job)
 	} else {
 		jp.Jobs[job.ID] = job
+		metrics.JobPoolSize.Set(float64(len(jp.Jobs)))
 	}
 
-	return jp.Jobs[job.ID]
+	return jp.Jobs[job.ID], nil
 }
 
 func (jp *JobPool) RemoveJob(id string) {
@@ -46,6 +57,8 @@ func (jp *JobPool) RemoveJob(id string) {
 	defer jp.mu.Unlock()
 
 	delete(jp.Jobs, id)
+	metrics.JobPoolSize.Set(float64(len(jp.Jobs)))
+	forgetEvents(id)
 }
 
 func (j *Job) append(other *Job) {
@@ -0,0 +1,85 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/joshjms/castletown/logging"
+	"github.com/joshjms/castletown/sandbox"
+)
+
+// StartSession starts the only step of the job as a long-lived session whose
+// standard streams are driven by the caller. The step's time limit is the
+// CPU budget of the whole session. The job lives no longer than its session:
+// the sandbox and the job's files are removed once the session ends, or
+// right away if it cannot start. A non-nil terminal runs the process on a
+// pseudo-terminal of that size.
+func (j *Job) StartSession(ctx context.Context, limits sandbox.SessionLimits, terminal *sandbox.Terminal) (_ *sandbox.Session, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	defer func() {
+		if err != nil {
+			os.RemoveAll(getRootFileDir(j.ID))
+		}
+	}()
+
+	if len(j.Procs) != 1 {
+		return nil, fmt.Errorf("a session runs exactly one process, got %d", len(j.Procs))
+	}
+
+	proc := j.Procs[0]
+	if err := verifySession(proc); err != nil {
+		return nil, err
+	}
+
+	fileDeps, err := getFileDependencies(j.ID, j.Procs, j.Files, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error getting file dependencies: %w", err)
+	}
+
+	cfg, err := makeConfig(proc, getProcFileDir(j.ID, 0), fileDeps)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure session: %w", err)
+	}
+	cfg.Terminal = terminal
+
+	log := j.logger(0)
+	ctx = logging.NewContext(ctx, log)
+	sessionId := fmt.Sprintf("%s-session", j.ID)
+	if err := sandbox.GetManager().NewSandbox(sessionId, cfg); err != nil {
+		err = fmt.Errorf("cannot create sandbox for session: %v", err)
+		log.Error("session failed", logging.KEY_SANDBOX_ID, sessionId, logging.Error(err))
+		return nil, err
+	}
+
+	sess, err := sandbox.GetManager().StartSession(ctx, sessionId, limits)
+	if err != nil {
+		destroySandbox(ctx, sessionId)
+		err = fmt.Errorf("cannot start session: %v", err)
+		log.Error("session failed", logging.KEY_SANDBOX_ID, sessionId, logging.Error(err))
+		return nil, err
+	}
+	log.Info("session started", logging.KEY_SANDBOX_ID, sessionId)
+
+	go func() {
+		<-sess.Done()
+		destroySandbox(context.WithoutCancel(ctx), sessionId)
+		os.RemoveAll(getRootFileDir(j.ID))
+	}()
+
+	return sess, nil
+}
+
+// verifySession rejects settings that conflict with the session owning the
+// process' standard streams.
+func verifySession(proc Process) error {
+	if proc.Stdin != "" || proc.StdinFile != "" || proc.StdinFrom != "" || proc.StdoutFile != "" || proc.StderrFile != "" {
+		return fmt.Errorf("standard streams of a session cannot be set")
+	}
+	if proc.Interactor != nil || len(proc.Services) > 0 {
+		return fmt.Errorf("a session cannot have an interactor or services")
+	}
+	return nil
+}
@@ -2,23 +2,57 @@ package job
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/image"
 	"github.com/joshjms/castletown/sandbox"
 )
 
-func getImageDir(image string) string {
-	image = strings.Replace(image, ":", "-", 1)
-	return filepath.Join(config.ImagesDir, image)
+func getImageDir(name string) string {
+	return image.Dir(name)
 }
 
+// mergeEnv applies each list of KEY=value entries on top of base in order.
+// An entry replaces the earlier one with the same key in place; new keys are
+// appended.
+func mergeEnv(base []string, overrides ...[]string) []string {
+	env := append([]string{}, base...)
+	index := make(map[string]int, len(env))
+	for i, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		index[key] = i
+	}
+
+	for _, override := range overrides {
+		for _, kv := range override {
+			key, _, _ := strings.Cut(kv, "=")
+			if i, ok := index[key]; ok {
+				env[i] = kv
+				continue
+			}
+			index[key] = len(env)
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// verifyImages checks that the image of every process exists. Names are
+// validated first, since they become paths on the host.
 func verifyImages(procs []Process) error {
-	for _, process := range procs {
-		image := process.Image
-		rootfsDir := getImageDir(image)
+	for _, process := range allProcesses(procs) {
+		if err := image.ValidateName(process.Image); err != nil {
+			return err
+		}
+
+		rootfsDir := getImageDir(process.Image)
 
 		f, err := os.Stat(rootfsDir)
 		if os.IsNotExist(err) {
@@ -27,6 +61,9 @@ func verifyImages(procs []Process) error {
 		if !f.IsDir() {
 			return fmt.Errorf("rootfs path exists but is not a directory: %s", rootfsDir)
 		}
+		if _, err := image.LayerDirs(process.Image); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -38,11 +75,23 @@ func prepareFileDirs(reqId string, procs []Process) error {
 		return fmt.Errorf("cannot create root files directory: %v", err)
 	}
 
-	for i := range procs {
+	for i, proc := range procs {
 		procDir := filepath.Join(rootFileDir, fmt.Sprintf("proc-%d", i))
 		if err := os.MkdirAll(procDir, 0755); err != nil {
 			return fmt.Errorf("cannot create process directory: %v", err)
 		}
+
+		if proc.Interactor != nil {
+			if err := os.MkdirAll(getInteractorFileDir(reqId, i), 0755); err != nil {
+				return fmt.Errorf("cannot create interactor directory: %v", err)
+			}
+		}
+
+		for j := range proc.Services {
+			if err := os.MkdirAll(getServiceFileDir(reqId, i, j), 0755); err != nil {
+				return fmt.Errorf("cannot create service directory: %v", err)
+			}
+		}
 	}
 
 	return nil
@@ -56,43 +105,374 @@ func getProcFileDir(reqId string, procIndex int) string {
 	return filepath.Join(getRootFileDir(reqId), fmt.Sprintf("proc-%d", procIndex))
 }
 
-func getFileDependencies(reqId string, procs []Process, files []File, step int) ([]sandbox.File, error) {
-	fileMap := make(map[string]File)
+func verifyFiles(files []File, procs []Process) error {
 	for _, file := range files {
-		fileMap[file.Name] = file
+		if err := verifyPath(file.Name); err != nil {
+			return err
+		}
+
+		switch file.Type {
+		case "", FILE_TYPE_REGULAR, FILE_TYPE_DIR, FILE_TYPE_TAR, FILE_TYPE_ZIP:
+		default:
+			return fmt.Errorf("file %s has unknown type %q", file.Name, file.Type)
+		}
+
+		if file.Mode&^0777 != 0 {
+			return fmt.Errorf("file %s has invalid mode %#o", file.Name, file.Mode)
+		}
+	}
+
+	for _, proc := range allProcesses(procs) {
+		for _, pattern := range proc.Files {
+			if err := verifyPath(pattern); err != nil {
+				return err
+			}
+		}
+		for _, pattern := range proc.Persist {
+			if err := verifyPath(pattern); err != nil {
+				return err
+			}
+		}
+		for _, pattern := range proc.Outputs {
+			if err := verifyPath(pattern); err != nil {
+				return err
+			}
+		}
+		for _, name := range []string{proc.StdinFile, proc.StdoutFile, proc.StderrFile} {
+			if name == "" {
+				continue
+			}
+			if err := verifyPath(name); err != nil {
+				return err
+			}
+		}
+		if proc.StdinFile != "" && proc.Stdin != "" {
+			return fmt.Errorf("stdin and stdinFile are mutually exclusive")
+		}
+	}
+
+	return nil
+}
+
+func verifySteps(procs []Process) error {
+	names := make(map[string]bool)
+
+	for i, proc := range procs {
+		if proc.Name != "" {
+			if names[proc.Name] {
+				return fmt.Errorf("duplicate step name %q", proc.Name)
+			}
+			names[proc.Name] = true
+		}
+
+		if proc.Interactor != nil {
+			if err := verifyInteractive(proc); err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+
+		if err := verifyServices(proc); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+
+		if proc.StdinFrom == "" {
+			continue
+		}
+		if proc.Stdin != "" || proc.StdinFile != "" {
+			return fmt.Errorf("step %d: stdinFrom cannot be combined with stdin or stdinFile", i)
+		}
+		if _, _, err := parseStepOutputRef(procs, i, proc.StdinFrom); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyInteractive checks that neither side of an interactive step has its
+// stdin or stdout configured, since both are connected to the other side.
+func verifyInteractive(proc Process) error {
+	for _, p := range []Process{proc, *proc.Interactor} {
+		if p.Stdin != "" || p.StdinFile != "" || p.StdinFrom != "" || p.StdoutFile != "" {
+			return fmt.Errorf("stdin and stdout of interactive processes cannot be set")
+		}
+	}
+
+	if proc.Interactor.Interactor != nil {
+		return fmt.Errorf("an interactor cannot have an interactor")
+	}
+
+	return nil
+}
+
+// verifyServices checks the services of a step. Services are plain
+// processes: they cannot be interactive, have services of their own or take
+// the output of an earlier step as stdin. Only services have readiness
+// checks.
+func verifyServices(proc Process) error {
+	if proc.Readiness != nil {
+		return fmt.Errorf("readiness can only be set on services")
+	}
+
+	if len(proc.Services) > 0 && proc.Interactor != nil {
+		return fmt.Errorf("services cannot be combined with an interactor")
+	}
+
+	for i, svc := range proc.Services {
+		if svc.Interactor != nil || len(svc.Services) > 0 {
+			return fmt.Errorf("service %d cannot have an interactor or services", i)
+		}
+		if svc.StdinFrom != "" {
+			return fmt.Errorf("service %d cannot use stdinFrom", i)
+		}
+		if r := svc.Readiness; r != nil {
+			if r.TCPPort <= 0 || r.TCPPort > 65535 {
+				return fmt.Errorf("service %d has invalid readiness port %d", i, r.TCPPort)
+			}
+			if r.TimeoutMs < 0 {
+				return fmt.Errorf("service %d has negative readiness timeout", i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseStepOutputRef resolves a "<step>.stdout" or "<step>.stderr" reference
+// made by step current. <step> is either a step name or a step index and must
+// refer to an earlier step.
+func parseStepOutputRef(procs []Process, current int, ref string) (int, string, error) {
+	dot := strings.LastIndex(ref, ".")
+	if dot < 0 {
+		return 0, "", fmt.Errorf("invalid output reference %q", ref)
+	}
+
+	name, stream := ref[:dot], ref[dot+1:]
+	if stream != "stdout" && stream != "stderr" {
+		return 0, "", fmt.Errorf("invalid output reference %q: unknown stream %q", ref, stream)
+	}
+
+	step := -1
+	for i, proc := range procs {
+		if proc.Name != "" && proc.Name == name {
+			step = i
+			break
+		}
+	}
+	if step < 0 {
+		idx, err := strconv.Atoi(name)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid output reference %q: no step named %q", ref, name)
+		}
+		step = idx
+	}
+
+	if step < 0 || step >= current {
+		return 0, "", fmt.Errorf("invalid output reference %q: step must run before step %d", ref, current)
+	}
+
+	return step, stream, nil
+}
+
+// allProcesses returns procs followed by the interactors and services of
+// their steps, for checks that apply to every process of a job.
+func allProcesses(procs []Process) []Process {
+	all := append([]Process(nil), procs...)
+	for _, proc := range procs {
+		if proc.Interactor != nil {
+			all = append(all, *proc.Interactor)
+		}
+		all = append(all, proc.Services...)
+	}
+	return all
+}
+
+// verifyPath rejects paths that could point outside of a box.
+func verifyPath(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty path")
+	}
+	if path.IsAbs(name) {
+		return fmt.Errorf("path %s must be relative", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return fmt.Errorf("path %s must not contain ..", name)
+		}
+	}
+	return nil
+}
+
+func getInteractorFileDir(reqId string, procIndex int) string {
+	return filepath.Join(getRootFileDir(reqId), fmt.Sprintf("proc-%d-interactor", procIndex))
+}
+
+func getServiceFileDir(reqId string, procIndex, serviceIndex int) string {
+	return filepath.Join(getRootFileDir(reqId), fmt.Sprintf("proc-%d-service-%d", procIndex, serviceIndex))
+}
+
+func getFileDependencies(reqId string, procs []Process, files []File, step int) ([]sandbox.File, error) {
+	return resolveFileDependencies(reqId, procs, files, step, procs[step], getProcFileDir(reqId, step))
+}
+
+// getInteractorFileDependencies resolves the files of the interactor of step
+// into its own box. Persisted files of earlier steps are available to it.
+func getInteractorFileDependencies(reqId string, procs []Process, files []File, step int) ([]sandbox.File, error) {
+	return resolveFileDependencies(reqId, procs, files, step, *procs[step].Interactor, getInteractorFileDir(reqId, step))
+}
+
+// getServiceFileDependencies resolves the files of a service of step into
+// its own box.
+func getServiceFileDependencies(reqId string, procs []Process, files []File, step, service int) ([]sandbox.File, error) {
+	return resolveFileDependencies(reqId, procs, files, step, procs[step].Services[service], getServiceFileDir(reqId, step, service))
+}
+
+func resolveFileDependencies(reqId string, procs []Process, files []File, step int, proc Process, procDir string) ([]sandbox.File, error) {
+	persisted, err := getPersistedFiles(reqId, procs, step)
+	if err != nil {
+		return nil, fmt.Errorf("error collecting persisted files: %w", err)
 	}
 
 	fileDeps := make([]sandbox.File, 0)
-	lastOcc := make(map[string]int)
 
-	procDir := getProcFileDir(reqId, step)
+	// The stdin file is an implicit dependency of the step.
+	patterns := proc.Files
+	if stdinFile := proc.StdinFile; stdinFile != "" && !matchAny(patterns, stdinFile) {
+		patterns = append(patterns[:len(patterns):len(patterns)], stdinFile)
+	}
+
+	for _, pattern := range patterns {
+		matched := false
+
+		// Files persisted by earlier steps take precedence over job files.
+		for _, p := range persisted {
+			if matchPath(pattern, p.name) {
+				fileDeps = append(fileDeps, sandbox.File{
+					Src: filepath.Join(getProcFileDir(reqId, p.step), p.name),
+					Dst: filepath.Join(procDir, p.name),
+				})
+				matched = true
+			}
+		}
+		if matched {
+			continue
+		}
+
+		for _, file := range files {
+			if matchPath(pattern, file.Name) {
+				fileDeps = append(fileDeps, toSandboxFile(file, procDir))
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("file %s not found", pattern)
+		}
+	}
+
+	return fileDeps, nil
+}
+
+type persistedFile struct {
+	name string
+	step int
+}
+
+// getPersistedFiles walks the boxes of all steps before step and returns
+// every entry selected by that step's Persist patterns, sorted by name so
+// that directories precede their contents. When several steps persist the
+// same path, the latest one wins.
+func getPersistedFiles(reqId string, procs []Process, step int) ([]persistedFile, error) {
+	lastOcc := make(map[string]int)
 
 	for i, proc := range procs[:step] {
-		for _, fileName := range proc.Persist {
-			lastOcc[fileName] = i
+		if len(proc.Persist) == 0 {
+			continue
+		}
+
+		procDir := getProcFileDir(reqId, i)
+		err := filepath.WalkDir(procDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == procDir {
+				return nil
+			}
+
+			name, err := filepath.Rel(procDir, p)
+			if err != nil {
+				return err
+			}
+			name = filepath.ToSlash(name)
+
+			if matchAny(proc.Persist, name) {
+				lastOcc[name] = i
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	proc := procs[step]
+	persisted := make([]persistedFile, 0, len(lastOcc))
+	for name, i := range lastOcc {
+		persisted = append(persisted, persistedFile{name: name, step: i})
+	}
+	sort.Slice(persisted, func(a, b int) bool {
+		return persisted[a].name < persisted[b].name
+	})
+
+	return persisted, nil
+}
+
+func toSandboxFile(file File, procDir string) sandbox.File {
+	f := sandbox.File{
+		Content: file.Content,
+		Dst:     filepath.Join(procDir, file.Name),
+		Mode:    os.FileMode(file.Mode),
+	}
 
-	for _, fileName := range proc.Files {
-		if _, exists := lastOcc[fileName]; !exists {
-			file, exists := fileMap[fileName]
-			if !exists {
-				return nil, fmt.Errorf("file %s not found", fileName)
+	switch file.Type {
+	case FILE_TYPE_DIR:
+		f.Dir = true
+	case FILE_TYPE_TAR:
+		f.Archive = sandbox.ARCHIVE_TAR
+	case FILE_TYPE_ZIP:
+		f.Archive = sandbox.ARCHIVE_ZIP
+	}
+
+	return f
+}
+
+// matchPath reports whether name is selected by pattern. Each segment of
+// pattern uses path.Match syntax, "**" matches any number of segments, and a
+// pattern that selects a directory also selects everything below it.
+func matchPath(pattern, name string) bool {
+	return matchSegments(
+		strings.Split(path.Clean(pattern), "/"),
+		strings.Split(path.Clean(name), "/"),
+	)
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
 			}
+			return false
+		}
 
-			fileDeps = append(fileDeps, sandbox.File{
-				Content: file.Content,
-				Dst:     filepath.Join(procDir, fileName),
-			})
-		} else {
-			fileDeps = append(fileDeps, sandbox.File{
-				Src: filepath.Join(getProcFileDir(reqId, lastOcc[fileName]), fileName),
-				Dst: filepath.Join(procDir, fileName),
-			})
+		if len(name) == 0 {
+			return false
 		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
 	}
 
-	return fileDeps, nil
+	return true
 }
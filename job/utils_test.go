@@ -0,0 +1,273 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/sandbox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"main", "main", true},
+		{"main", "main.cpp", false},
+		{"build", "build/classes/App.class", true},
+		{"build/**", "build", true},
+		{"build/**", "build/classes/App.class", true},
+		{"build/**", "buildx/App.class", false},
+		{"*.py", "main.py", true},
+		{"*.py", "pkg/main.py", false},
+		{"**/*.py", "pkg/sub/main.py", true},
+		{"src/**/test_*.py", "src/a/b/test_x.py", true},
+		{"src/**/test_*.py", "src/a/b/x.py", false},
+		{".", ".", true},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.want, matchPath(tc.pattern, tc.name), "matchPath(%q, %q)", tc.pattern, tc.name)
+	}
+}
+
+func TestVerifyFiles(t *testing.T) {
+	require.NoError(t, verifyFiles([]File{{Name: "src/main.py"}, {Name: ".", Type: FILE_TYPE_ZIP}}, nil))
+	require.Error(t, verifyFiles([]File{{Name: "/etc/passwd"}}, nil))
+	require.Error(t, verifyFiles([]File{{Name: "a/../../b"}}, nil))
+	require.Error(t, verifyFiles([]File{{Name: "a", Type: "rar"}}, nil))
+	require.Error(t, verifyFiles([]File{{Name: "a", Mode: 04755}}, nil))
+	require.Error(t, verifyFiles(nil, []Process{{Persist: []string{"../out"}}}))
+}
+
+func TestGetFileDependenciesPersistGlob(t *testing.T) {
+	storageDir := config.StorageDir
+	config.StorageDir = t.TempDir()
+	defer func() { config.StorageDir = storageDir }()
+
+	reqId := "persist-glob"
+	procs := []Process{
+		{Persist: []string{"build/**", "report.txt"}},
+		{Files: []string{"build", "report.txt", "input.txt"}},
+	}
+	files := []File{
+		{Name: "input.txt", Content: []byte("data"), Mode: 0600},
+	}
+
+	require.NoError(t, prepareFileDirs(reqId, procs))
+
+	buildDir := filepath.Join(getProcFileDir(reqId, 0), "build", "classes")
+	require.NoError(t, os.MkdirAll(buildDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(buildDir, "App.class"), []byte{0xca, 0xfe}, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(getProcFileDir(reqId, 0), "report.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(getProcFileDir(reqId, 0), "scratch.txt"), nil, 0644))
+
+	deps, err := getFileDependencies(reqId, procs, files, 1)
+	require.NoError(t, err)
+
+	dsts := make([]string, len(deps))
+	for i, dep := range deps {
+		dst, err := filepath.Rel(getProcFileDir(reqId, 1), dep.Dst)
+		require.NoError(t, err)
+		dsts[i] = dst
+	}
+	require.Equal(t, []string{"build", "build/classes", "build/classes/App.class", "report.txt", "input.txt"}, dsts)
+	require.Equal(t, sandbox.File{
+		Content: []byte("data"),
+		Dst:     filepath.Join(getProcFileDir(reqId, 1), "input.txt"),
+		Mode:    0600,
+	}, deps[4])
+
+	_, err = getFileDependencies(reqId, []Process{{}, {Files: []string{"scratch.txt"}}}, files, 1)
+	require.Error(t, err)
+}
+
+func TestGetFileDependenciesStdinFile(t *testing.T) {
+	storageDir := config.StorageDir
+	config.StorageDir = t.TempDir()
+	defer func() { config.StorageDir = storageDir }()
+
+	reqId := "stdin-file"
+	procs := []Process{
+		{StdoutFile: "tests/1.in", Persist: []string{"tests"}},
+		{Files: []string{"main"}, StdinFile: "tests/1.in"},
+	}
+	files := []File{{Name: "main"}}
+
+	require.NoError(t, prepareFileDirs(reqId, procs))
+	require.NoError(t, os.MkdirAll(filepath.Join(getProcFileDir(reqId, 0), "tests"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(getProcFileDir(reqId, 0), "tests", "1.in"), []byte("5\n"), 0644))
+
+	deps, err := getFileDependencies(reqId, procs, files, 1)
+	require.NoError(t, err)
+	require.Len(t, deps, 2)
+	require.Equal(t, filepath.Join(getProcFileDir(reqId, 0), "tests", "1.in"), deps[1].Src)
+	require.Equal(t, []string{"main"}, procs[1].Files)
+
+	require.Error(t, verifyFiles(nil, []Process{{Stdin: "1", StdinFile: "in.txt"}}))
+	require.Error(t, verifyFiles(nil, []Process{{StdoutFile: "../out.txt"}}))
+}
+
+func TestParseStepOutputRef(t *testing.T) {
+	procs := []Process{{Name: "gen"}, {}, {Name: "sol"}}
+
+	step, stream, err := parseStepOutputRef(procs, 2, "gen.stdout")
+	require.NoError(t, err)
+	require.Equal(t, 0, step)
+	require.Equal(t, "stdout", stream)
+
+	step, stream, err = parseStepOutputRef(procs, 2, "1.stderr")
+	require.NoError(t, err)
+	require.Equal(t, 1, step)
+	require.Equal(t, "stderr", stream)
+
+	_, _, err = parseStepOutputRef(procs, 2, "sol.stdout")
+	require.Error(t, err, "a step cannot read its own output")
+	_, _, err = parseStepOutputRef(procs, 2, "gen.exitcode")
+	require.Error(t, err)
+	_, _, err = parseStepOutputRef(procs, 2, "missing.stdout")
+	require.Error(t, err)
+}
+
+func TestVerifyImagesName(t *testing.T) {
+	imagesDir := config.ImagesDir
+	t.Cleanup(func() { config.ImagesDir = imagesDir })
+	config.ImagesDir = t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(config.ImagesDir, "gcc-15-bookworm"), 0755))
+	require.NoError(t, verifyImages([]Process{{Image: "gcc:15-bookworm"}}))
+
+	for _, name := range []string{"", "../../..", "gcc/../../etc", "/etc"} {
+		require.ErrorContains(t, verifyImages([]Process{{Image: name}}), "invalid image name")
+		require.ErrorContains(t, verifyImages([]Process{{Image: "gcc:15-bookworm", Services: []Process{{Image: name}}}}), "invalid image name")
+	}
+}
+
+func TestVerifySteps(t *testing.T) {
+	require.NoError(t, verifySteps([]Process{{Name: "gen"}, {StdinFrom: "gen.stdout"}}))
+	require.Error(t, verifySteps([]Process{{Name: "a"}, {Name: "a"}}))
+	require.Error(t, verifySteps([]Process{{StdinFrom: "0.stdout"}}))
+	require.Error(t, verifySteps([]Process{{Name: "gen"}, {StdinFrom: "gen.stdout", Stdin: "x"}}))
+}
+
+func TestGetStepOutput(t *testing.T) {
+	j := &Job{
+		Procs: []Process{
+			{Name: "gen"},
+			{Name: "file", StdoutFile: "out.txt"},
+			{Name: "long"},
+			{StdinFrom: "gen.stdout"},
+		},
+		reports: []sandbox.Report{
+			{Stdout: []byte("3\n1 2 3\n"), Stderr: []byte("seed=42\n")},
+			{},
+			{Stdout: []byte("1 2"), StdoutTruncated: true},
+		},
+		step: 3,
+	}
+
+	out, err := j.getStepOutput("gen.stdout")
+	require.NoError(t, err)
+	require.Equal(t, "3\n1 2 3\n", string(out))
+
+	out, err = j.getStepOutput("gen.stderr")
+	require.NoError(t, err)
+	require.Equal(t, "seed=42\n", string(out))
+
+	_, err = j.getStepOutput("file.stdout")
+	require.Error(t, err)
+
+	_, err = j.getStepOutput("long.stdout")
+	require.ErrorContains(t, err, "truncated")
+}
+
+func TestVerifyInteractive(t *testing.T) {
+	require.NoError(t, verifySteps([]Process{{Cmd: []string{"./sol"}, Interactor: &Process{Cmd: []string{"./interactor"}}}}))
+	require.Error(t, verifySteps([]Process{{Stdin: "1", Interactor: &Process{}}}))
+	require.Error(t, verifySteps([]Process{{Interactor: &Process{StdoutFile: "log"}}}))
+	require.Error(t, verifySteps([]Process{{Interactor: &Process{Interactor: &Process{}}}}))
+	require.Error(t, verifyFiles(nil, []Process{{Interactor: &Process{Files: []string{"../x"}}}}))
+}
+
+func TestVerifyServices(t *testing.T) {
+	service := Process{Cmd: []string{"./server"}, Readiness: &Readiness{TCPPort: 8080}}
+	require.NoError(t, verifySteps([]Process{{Cmd: []string{"./test"}, Services: []Process{service}}}))
+	require.Error(t, verifySteps([]Process{{Readiness: &Readiness{TCPPort: 8080}}}))
+	require.Error(t, verifySteps([]Process{{Services: []Process{{Readiness: &Readiness{TCPPort: 0}}}}}))
+	require.Error(t, verifySteps([]Process{{Services: []Process{{Services: []Process{service}}}}}))
+	require.Error(t, verifySteps([]Process{{Services: []Process{service}, Interactor: &Process{}}}))
+	require.Error(t, verifyFiles(nil, []Process{{Services: []Process{{Files: []string{"/etc/passwd"}}}}}))
+}
+
+func TestMergeEnv(t *testing.T) {
+	env := mergeEnv(
+		[]string{"PATH=/usr/bin", "HOME=/root"},
+		[]string{"PATH=/usr/local/bin:/usr/bin", "LANG=C.UTF-8"},
+		[]string{"LANG=en_US.UTF-8", "DEBUG=1"},
+	)
+	require.Equal(t, []string{"PATH=/usr/local/bin:/usr/bin", "HOME=/root", "LANG=en_US.UTF-8", "DEBUG=1"}, env)
+}
+
+func TestMakeConfigImageDefaults(t *testing.T) {
+	imagesDir := config.ImagesDir
+	config.ImagesDir = t.TempDir()
+	t.Cleanup(func() { config.ImagesDir = imagesDir })
+
+	rootfs := filepath.Join(config.ImagesDir, "test-latest")
+	require.NoError(t, os.MkdirAll(filepath.Join(rootfs, "etc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(rootfs, "etc/passwd"), []byte("root:x:0:0::/root:/bin/sh\napp:x:1000:1001::/home/app:/bin/sh\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(config.ImagesDir, "test-latest.json"), []byte(`{
+		"name": "test:latest",
+		"config": {
+			"User": "app",
+			"Env": ["PATH=/opt/java/bin:/usr/bin", "JAVA_HOME=/opt/java"],
+			"Entrypoint": ["/entrypoint.sh"],
+			"Cmd": ["jshell"],
+			"WorkingDir": "/app"
+		}
+	}`), 0644))
+
+	cfg, err := makeConfig(Process{Image: "test:latest", Cmd: []string{"java", "Main.java"}}, "/box", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"java", "Main.java"}, cfg.Args)
+	require.Equal(t, []string{"PATH=/opt/java/bin:/usr/bin", "JAVA_HOME=/opt/java"}, cfg.Env)
+	require.Equal(t, "/app", cfg.Cwd)
+	require.Equal(t, uint32(1000), cfg.UID)
+	require.Equal(t, uint32(1001), cfg.GID)
+
+	cfg, err = makeConfig(Process{
+		Image:      "test:latest",
+		Env:        []string{"JAVA_HOME=/usr/lib/jvm"},
+		WorkingDir: "/box",
+		User:       "0:0",
+	}, "/box", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/entrypoint.sh", "jshell"}, cfg.Args)
+	require.Equal(t, []string{"PATH=/opt/java/bin:/usr/bin", "JAVA_HOME=/usr/lib/jvm"}, cfg.Env)
+	require.Equal(t, "/box", cfg.Cwd)
+	require.Equal(t, uint32(0), cfg.UID)
+
+	_, err = makeConfig(Process{Image: "test:latest", User: "nobody"}, "/box", nil)
+	require.Error(t, err)
+}
+
+func TestApplyBuildProfile(t *testing.T) {
+	cfg := sandbox.GetDefaultConfig()
+	applyBuildProfile(cfg, Process{}, "/overlay/build-1/upper")
+	require.Equal(t, "/overlay/build-1/upper", cfg.UpperDir)
+	require.True(t, cfg.HostNetwork)
+	require.True(t, cfg.RootOwnsImage)
+	require.Equal(t, int64(BUILD_TIME_LIMIT_MS), cfg.TimeLimitMs)
+	require.Equal(t, int64(BUILD_MEMORY_LIMIT_MB*1024*1024), cfg.Cgroup.Memory)
+
+	// Limits the step sets itself stay.
+	cfg, err := makeConfig(Process{TimeLimitMs: 5000, MemoryLimitMB: 512}, "/box", nil)
+	require.NoError(t, err)
+	applyBuildProfile(cfg, Process{TimeLimitMs: 5000, MemoryLimitMB: 512}, "/upper")
+	require.Equal(t, int64(5000), cfg.TimeLimitMs)
+	require.Equal(t, int64(512*1024*1024), cfg.Cgroup.Memory)
+	require.Equal(t, int64(BUILD_PROC_LIMIT), cfg.Cgroup.PidsLimit)
+}
@@ -0,0 +1,70 @@
+// Package logging sets up the server's structured logs. Lines about a job
+// carry its ID and the index of the step, and lines about a sandbox its ID
+// as well, so that everything that happened to one run can be found.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Keys of the attributes that correlate log lines.
+const (
+	KEY_JOB_ID     = "job_id"
+	KEY_STEP       = "step"
+	KEY_SANDBOX_ID = "sandbox_id"
+	KEY_ERROR      = "error"
+)
+
+// Formats of log lines.
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+// Setup makes the default logger write lines of at least level, one of
+// debug, info, warn and error, to w in format.
+func Setup(w io.Writer, level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FORMAT_TEXT:
+		handler = slog.NewTextHandler(w, opts)
+	case FORMAT_JSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, want %s or %s", format, FORMAT_TEXT, FORMAT_JSON)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx carrying l, for code called with ctx to
+// log with l's attributes.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger ctx carries, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// Error is the attribute of an error.
+func Error(err error) slog.Attr {
+	return slog.String(KEY_ERROR, err.Error())
+}
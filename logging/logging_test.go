@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	var buf bytes.Buffer
+	require.NoError(t, Setup(&buf, "warn", FORMAT_JSON))
+
+	log := slog.With(KEY_JOB_ID, "j1", KEY_STEP, 2)
+	ctx := NewContext(context.Background(), log)
+	FromContext(ctx).Info("dropped")
+	FromContext(ctx).With(KEY_SANDBOX_ID, "j1-2").Error("sandbox failed", Error(errors.New("boom")))
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "sandbox failed", line["msg"])
+	require.Equal(t, "j1", line[KEY_JOB_ID])
+	require.Equal(t, 2.0, line[KEY_STEP])
+	require.Equal(t, "j1-2", line[KEY_SANDBOX_ID])
+	require.Equal(t, "boom", line[KEY_ERROR])
+
+	require.Error(t, Setup(&buf, "loud", FORMAT_TEXT))
+	require.Error(t, Setup(&buf, "info", "xml"))
+	require.Equal(t, slog.Default(), FromContext(context.Background()))
+}
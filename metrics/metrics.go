@@ -0,0 +1,118 @@
+// Package metrics exposes the server's metrics in the Prometheus format on
+// /metrics. The packages doing the work update the collectors here as they
+// go.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const NAMESPACE = "castletown"
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	// QueueDepth is the number of sandboxes waiting for a concurrency slot.
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "queue_depth",
+		Help:      "Sandboxes waiting for a concurrency slot.",
+	})
+
+	// QueueWait is how long sandboxes waited for their concurrency slots.
+	QueueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "queue_wait_seconds",
+		Help:      "Time sandboxes waited for a concurrency slot.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+
+	// SandboxesRunning is the number of concurrency slots taken, each by a
+	// running sandbox.
+	SandboxesRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "sandboxes_running",
+		Help:      "Sandboxes holding a concurrency slot.",
+	})
+
+	// SetupDuration is how long sandboxes took from being run to starting
+	// their process.
+	SetupDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "sandbox_setup_seconds",
+		Help:      "Time from running a sandbox to starting its process.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// TeardownDuration is how long destroying sandboxes took.
+	TeardownDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "sandbox_teardown_seconds",
+		Help:      "Time taken to destroy a sandbox.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// Verdicts counts the steps that finished, by status and image.
+	Verdicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "verdicts_total",
+		Help:      "Steps finished, by status and image.",
+	}, []string{"status", "image"})
+
+	// StepCPU is the CPU time of finished steps.
+	StepCPU = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "step_cpu_seconds",
+		Help:      "CPU time taken by a step.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	})
+
+	// StepMemory is the peak memory of finished steps.
+	StepMemory = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "step_memory_bytes",
+		Help:      "Peak memory used by a step.",
+		Buckets:   prometheus.ExponentialBuckets(1<<20, 2, 12),
+	})
+
+	// AllocatorRangesInUse is the number of UID/GID ranges given to
+	// sandboxes that have not been destroyed yet.
+	AllocatorRangesInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "allocator_ranges_in_use",
+		Help:      "UID/GID ranges allocated to sandboxes.",
+	})
+
+	// JobPoolSize is the number of jobs in the job pool.
+	JobPoolSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "job_pool_size",
+		Help:      "Jobs kept in the job pool.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		QueueDepth,
+		QueueWait,
+		SandboxesRunning,
+		SetupDuration,
+		TeardownDuration,
+		Verdicts,
+		StepCPU,
+		StepMemory,
+		AllocatorRangesInUse,
+		JobPoolSize,
+	)
+}
+
+// Handler serves the metrics: GET /metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
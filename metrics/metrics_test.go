@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	Verdicts.WithLabelValues("OK", "gcc").Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	for _, name := range []string{
+		"castletown_queue_depth",
+		"castletown_queue_wait_seconds_bucket",
+		"castletown_sandboxes_running",
+		"castletown_sandbox_setup_seconds_bucket",
+		"castletown_sandbox_teardown_seconds_bucket",
+		`castletown_verdicts_total{image="gcc",status="OK"} 1`,
+		"castletown_step_cpu_seconds_bucket",
+		"castletown_step_memory_bytes_bucket",
+		"castletown_allocator_ranges_in_use",
+		"castletown_job_pool_size",
+		"go_goroutines",
+	} {
+		require.Contains(t, body, name)
+	}
+}
@@ -21,6 +21,128 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Verdict is the outcome of an interactive step
+type Verdict int32
+
+const (
+	Verdict_VERDICT_UNSPECIFIED           Verdict = 0
+	Verdict_VERDICT_ACCEPTED              Verdict = 1
+	Verdict_VERDICT_WRONG_ANSWER          Verdict = 2
+	Verdict_VERDICT_PRESENTATION_ERROR    Verdict = 3
+	Verdict_VERDICT_TIME_LIMIT_EXCEEDED   Verdict = 4
+	Verdict_VERDICT_MEMORY_LIMIT_EXCEEDED Verdict = 5
+	Verdict_VERDICT_OUTPUT_LIMIT_EXCEEDED Verdict = 6
+	Verdict_VERDICT_RUNTIME_ERROR         Verdict = 7
+	Verdict_VERDICT_JUDGE_ERROR           Verdict = 8
+)
+
+// Enum value maps for Verdict.
+var (
+	Verdict_name = map[int32]string{
+		0: "VERDICT_UNSPECIFIED",
+		1: "VERDICT_ACCEPTED",
+		2: "VERDICT_WRONG_ANSWER",
+		3: "VERDICT_PRESENTATION_ERROR",
+		4: "VERDICT_TIME_LIMIT_EXCEEDED",
+		5: "VERDICT_MEMORY_LIMIT_EXCEEDED",
+		6: "VERDICT_OUTPUT_LIMIT_EXCEEDED",
+		7: "VERDICT_RUNTIME_ERROR",
+		8: "VERDICT_JUDGE_ERROR",
+	}
+	Verdict_value = map[string]int32{
+		"VERDICT_UNSPECIFIED":           0,
+		"VERDICT_ACCEPTED":              1,
+		"VERDICT_WRONG_ANSWER":          2,
+		"VERDICT_PRESENTATION_ERROR":    3,
+		"VERDICT_TIME_LIMIT_EXCEEDED":   4,
+		"VERDICT_MEMORY_LIMIT_EXCEEDED": 5,
+		"VERDICT_OUTPUT_LIMIT_EXCEEDED": 6,
+		"VERDICT_RUNTIME_ERROR":         7,
+		"VERDICT_JUDGE_ERROR":           8,
+	}
+)
+
+func (x Verdict) Enum() *Verdict {
+	p := new(Verdict)
+	*p = x
+	return p
+}
+
+func (x Verdict) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Verdict) Descriptor() protoreflect.EnumDescriptor {
+	return file_common_proto_enumTypes[0].Descriptor()
+}
+
+func (Verdict) Type() protoreflect.EnumType {
+	return &file_common_proto_enumTypes[0]
+}
+
+func (x Verdict) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Verdict.Descriptor instead.
+func (Verdict) EnumDescriptor() ([]byte, []int) {
+	return file_common_proto_rawDescGZIP(), []int{0}
+}
+
+// FileType tells how a file is materialized. Archives are extracted into
+// the directory named by the file.
+type FileType int32
+
+const (
+	FileType_FILE_TYPE_REGULAR FileType = 0
+	FileType_FILE_TYPE_DIR     FileType = 1
+	FileType_FILE_TYPE_TAR     FileType = 2
+	FileType_FILE_TYPE_ZIP     FileType = 3
+)
+
+// Enum value maps for FileType.
+var (
+	FileType_name = map[int32]string{
+		0: "FILE_TYPE_REGULAR",
+		1: "FILE_TYPE_DIR",
+		2: "FILE_TYPE_TAR",
+		3: "FILE_TYPE_ZIP",
+	}
+	FileType_value = map[string]int32{
+		"FILE_TYPE_REGULAR": 0,
+		"FILE_TYPE_DIR":     1,
+		"FILE_TYPE_TAR":     2,
+		"FILE_TYPE_ZIP":     3,
+	}
+)
+
+func (x FileType) Enum() *FileType {
+	p := new(FileType)
+	*p = x
+	return p
+}
+
+func (x FileType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FileType) Descriptor() protoreflect.EnumDescriptor {
+	return file_common_proto_enumTypes[1].Descriptor()
+}
+
+func (FileType) Type() protoreflect.EnumType {
+	return &file_common_proto_enumTypes[1]
+}
+
+func (x FileType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FileType.Descriptor instead.
+func (FileType) EnumDescriptor() ([]byte, []int) {
+	return file_common_proto_rawDescGZIP(), []int{1}
+}
+
 // Status represents the execution status
 type Status int32
 
@@ -73,11 +195,11 @@ func (x Status) String() string {
 }
 
 func (Status) Descriptor() protoreflect.EnumDescriptor {
-	return file_common_proto_enumTypes[0].Descriptor()
+	return file_common_proto_enumTypes[2].Descriptor()
 }
 
 func (Status) Type() protoreflect.EnumType {
-	return &file_common_proto_enumTypes[0]
+	return &file_common_proto_enumTypes[2]
 }
 
 func (x Status) Number() protoreflect.EnumNumber {
@@ -86,14 +208,16 @@ func (x Status) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Status.Descriptor instead.
 func (Status) EnumDescriptor() ([]byte, []int) {
-	return file_common_proto_rawDescGZIP(), []int{0}
+	return file_common_proto_rawDescGZIP(), []int{2}
 }
 
 // File represents a file to be created in the sandbox
 type File struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Content       []byte                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Type          FileType               `protobuf:"varint,3,opt,name=type,proto3,enum=castletown.FileType" json:"type,omitempty"`
+	Mode          uint32                 `protobuf:"varint,4,opt,name=mode,proto3" json:"mode,omitempty"` // permission bits, 0 for the default
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -135,26 +259,53 @@ func (x *File) GetName() string {
 	return ""
 }
 
-func (x *File) GetContent() string {
+func (x *File) GetContent() []byte {
 	if x != nil {
 		return x.Content
 	}
-	return ""
+	return nil
+}
+
+func (x *File) GetType() FileType {
+	if x != nil {
+		return x.Type
+	}
+	return FileType_FILE_TYPE_REGULAR
+}
+
+func (x *File) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
 }
 
 // Process represents a single execution step
 type Process struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Image         string                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
-	Cmd           []string               `protobuf:"bytes,2,rep,name=cmd,proto3" json:"cmd,omitempty"`
-	Stdin         string                 `protobuf:"bytes,3,opt,name=stdin,proto3" json:"stdin,omitempty"`
-	MemoryLimitMb int64                  `protobuf:"varint,4,opt,name=memory_limit_mb,json=memoryLimitMb,proto3" json:"memory_limit_mb,omitempty"`
-	TimeLimitMs   uint64                 `protobuf:"varint,5,opt,name=time_limit_ms,json=timeLimitMs,proto3" json:"time_limit_ms,omitempty"`
-	ProcLimit     int64                  `protobuf:"varint,6,opt,name=proc_limit,json=procLimit,proto3" json:"proc_limit,omitempty"`
-	Files         []string               `protobuf:"bytes,7,rep,name=files,proto3" json:"files,omitempty"`
-	Persist       []string               `protobuf:"bytes,8,rep,name=persist,proto3" json:"persist,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Image           string                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	Cmd             []string               `protobuf:"bytes,2,rep,name=cmd,proto3" json:"cmd,omitempty"`
+	Stdin           string                 `protobuf:"bytes,3,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	MemoryLimitMb   int64                  `protobuf:"varint,4,opt,name=memory_limit_mb,json=memoryLimitMb,proto3" json:"memory_limit_mb,omitempty"`
+	TimeLimitMs     uint64                 `protobuf:"varint,5,opt,name=time_limit_ms,json=timeLimitMs,proto3" json:"time_limit_ms,omitempty"`
+	ProcLimit       int64                  `protobuf:"varint,6,opt,name=proc_limit,json=procLimit,proto3" json:"proc_limit,omitempty"`
+	Files           []string               `protobuf:"bytes,7,rep,name=files,proto3" json:"files,omitempty"`
+	Persist         []string               `protobuf:"bytes,8,rep,name=persist,proto3" json:"persist,omitempty"`
+	Outputs         []string               `protobuf:"bytes,9,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	StdinFile       string                 `protobuf:"bytes,10,opt,name=stdin_file,json=stdinFile,proto3" json:"stdin_file,omitempty"`
+	StdoutFile      string                 `protobuf:"bytes,11,opt,name=stdout_file,json=stdoutFile,proto3" json:"stdout_file,omitempty"`
+	StderrFile      string                 `protobuf:"bytes,12,opt,name=stderr_file,json=stderrFile,proto3" json:"stderr_file,omitempty"`
+	FileSizeLimitMb int64                  `protobuf:"varint,13,opt,name=file_size_limit_mb,json=fileSizeLimitMb,proto3" json:"file_size_limit_mb,omitempty"`
+	Name            string                 `protobuf:"bytes,14,opt,name=name,proto3" json:"name,omitempty"`
+	StdinFrom       string                 `protobuf:"bytes,15,opt,name=stdin_from,json=stdinFrom,proto3" json:"stdin_from,omitempty"` // "<step>.stdout" or "<step>.stderr"
+	Interactor      *Process               `protobuf:"bytes,16,opt,name=interactor,proto3" json:"interactor,omitempty"`                // runs next to this process, connected by pipes
+	Services        []*Process             `protobuf:"bytes,17,rep,name=services,proto3" json:"services,omitempty"`                    // share a network namespace with this process
+	Readiness       *Readiness             `protobuf:"bytes,18,opt,name=readiness,proto3" json:"readiness,omitempty"`                  // services only
+	Env             []string               `protobuf:"bytes,19,rep,name=env,proto3" json:"env,omitempty"`                              // KEY=value, override the image's config
+	WorkingDir      string                 `protobuf:"bytes,20,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	User            string                 `protobuf:"bytes,21,opt,name=user,proto3" json:"user,omitempty"` // "name", "uid", "name:group" or "uid:gid"
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *Process) Reset() {
@@ -243,26 +394,176 @@ func (x *Process) GetPersist() []string {
 	return nil
 }
 
-// Report contains the execution results
-type Report struct {
+func (x *Process) GetOutputs() []string {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+func (x *Process) GetStdinFile() string {
+	if x != nil {
+		return x.StdinFile
+	}
+	return ""
+}
+
+func (x *Process) GetStdoutFile() string {
+	if x != nil {
+		return x.StdoutFile
+	}
+	return ""
+}
+
+func (x *Process) GetStderrFile() string {
+	if x != nil {
+		return x.StderrFile
+	}
+	return ""
+}
+
+func (x *Process) GetFileSizeLimitMb() int64 {
+	if x != nil {
+		return x.FileSizeLimitMb
+	}
+	return 0
+}
+
+func (x *Process) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Process) GetStdinFrom() string {
+	if x != nil {
+		return x.StdinFrom
+	}
+	return ""
+}
+
+func (x *Process) GetInteractor() *Process {
+	if x != nil {
+		return x.Interactor
+	}
+	return nil
+}
+
+func (x *Process) GetServices() []*Process {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *Process) GetReadiness() *Readiness {
+	if x != nil {
+		return x.Readiness
+	}
+	return nil
+}
+
+func (x *Process) GetEnv() []string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *Process) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *Process) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+// Readiness tells when a service is ready to accept connections.
+type Readiness struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        Status                 `protobuf:"varint,1,opt,name=status,proto3,enum=castletown.Status" json:"status,omitempty"`
-	ExitCode      int32                  `protobuf:"varint,2,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
-	Signal        int32                  `protobuf:"varint,3,opt,name=signal,proto3" json:"signal,omitempty"`
-	Stdout        string                 `protobuf:"bytes,4,opt,name=stdout,proto3" json:"stdout,omitempty"`
-	Stderr        string                 `protobuf:"bytes,5,opt,name=stderr,proto3" json:"stderr,omitempty"`
-	CpuTime       uint64                 `protobuf:"varint,6,opt,name=cpu_time,json=cpuTime,proto3" json:"cpu_time,omitempty"`
-	Memory        uint64                 `protobuf:"varint,7,opt,name=memory,proto3" json:"memory,omitempty"`
-	WallTime      int64                  `protobuf:"varint,8,opt,name=wall_time,json=wallTime,proto3" json:"wall_time,omitempty"`
-	StartAt       int64                  `protobuf:"varint,9,opt,name=start_at,json=startAt,proto3" json:"start_at,omitempty"`     // Unix timestamp in nanoseconds
-	FinishAt      int64                  `protobuf:"varint,10,opt,name=finish_at,json=finishAt,proto3" json:"finish_at,omitempty"` // Unix timestamp in nanoseconds
+	TcpPort       int32                  `protobuf:"varint,1,opt,name=tcp_port,json=tcpPort,proto3" json:"tcp_port,omitempty"`
+	TimeoutMs     int64                  `protobuf:"varint,2,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *Readiness) Reset() {
+	*x = Readiness{}
+	mi := &file_common_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Readiness) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Readiness) ProtoMessage() {}
+
+func (x *Readiness) ProtoReflect() protoreflect.Message {
+	mi := &file_common_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Readiness.ProtoReflect.Descriptor instead.
+func (*Readiness) Descriptor() ([]byte, []int) {
+	return file_common_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Readiness) GetTcpPort() int32 {
+	if x != nil {
+		return x.TcpPort
+	}
+	return 0
+}
+
+func (x *Readiness) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+// Report contains the execution results
+type Report struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Status          Status                 `protobuf:"varint,1,opt,name=status,proto3,enum=castletown.Status" json:"status,omitempty"`
+	ExitCode        int32                  `protobuf:"varint,2,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Signal          int32                  `protobuf:"varint,3,opt,name=signal,proto3" json:"signal,omitempty"`
+	Stdout          []byte                 `protobuf:"bytes,4,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr          []byte                 `protobuf:"bytes,5,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	CpuTime         uint64                 `protobuf:"varint,6,opt,name=cpu_time,json=cpuTime,proto3" json:"cpu_time,omitempty"`
+	Memory          uint64                 `protobuf:"varint,7,opt,name=memory,proto3" json:"memory,omitempty"`
+	WallTime        int64                  `protobuf:"varint,8,opt,name=wall_time,json=wallTime,proto3" json:"wall_time,omitempty"`
+	StartAt         int64                  `protobuf:"varint,9,opt,name=start_at,json=startAt,proto3" json:"start_at,omitempty"`     // Unix timestamp in nanoseconds
+	FinishAt        int64                  `protobuf:"varint,10,opt,name=finish_at,json=finishAt,proto3" json:"finish_at,omitempty"` // Unix timestamp in nanoseconds
+	Artifacts       []*Artifact            `protobuf:"bytes,11,rep,name=artifacts,proto3" json:"artifacts,omitempty"`
+	Interactor      *Report                `protobuf:"bytes,12,opt,name=interactor,proto3" json:"interactor,omitempty"`                                   // set for interactive steps
+	Verdict         Verdict                `protobuf:"varint,13,opt,name=verdict,proto3,enum=castletown.Verdict" json:"verdict,omitempty"`                // set for interactive steps
+	Services        []*Report              `protobuf:"bytes,14,rep,name=services,proto3" json:"services,omitempty"`                                       // set for steps with services
+	StdoutTruncated bool                   `protobuf:"varint,15,opt,name=stdout_truncated,json=stdoutTruncated,proto3" json:"stdout_truncated,omitempty"` // output beyond the server's limit was left out
+	StderrTruncated bool                   `protobuf:"varint,16,opt,name=stderr_truncated,json=stderrTruncated,proto3" json:"stderr_truncated,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
 func (x *Report) Reset() {
 	*x = Report{}
-	mi := &file_common_proto_msgTypes[2]
+	mi := &file_common_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -274,7 +575,7 @@ func (x *Report) String() string {
 func (*Report) ProtoMessage() {}
 
 func (x *Report) ProtoReflect() protoreflect.Message {
-	mi := &file_common_proto_msgTypes[2]
+	mi := &file_common_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -287,7 +588,7 @@ func (x *Report) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Report.ProtoReflect.Descriptor instead.
 func (*Report) Descriptor() ([]byte, []int) {
-	return file_common_proto_rawDescGZIP(), []int{2}
+	return file_common_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Report) GetStatus() Status {
@@ -311,18 +612,18 @@ func (x *Report) GetSignal() int32 {
 	return 0
 }
 
-func (x *Report) GetStdout() string {
+func (x *Report) GetStdout() []byte {
 	if x != nil {
 		return x.Stdout
 	}
-	return ""
+	return nil
 }
 
-func (x *Report) GetStderr() string {
+func (x *Report) GetStderr() []byte {
 	if x != nil {
 		return x.Stderr
 	}
-	return ""
+	return nil
 }
 
 func (x *Report) GetCpuTime() uint64 {
@@ -360,15 +661,129 @@ func (x *Report) GetFinishAt() int64 {
 	return 0
 }
 
+func (x *Report) GetArtifacts() []*Artifact {
+	if x != nil {
+		return x.Artifacts
+	}
+	return nil
+}
+
+func (x *Report) GetInteractor() *Report {
+	if x != nil {
+		return x.Interactor
+	}
+	return nil
+}
+
+func (x *Report) GetVerdict() Verdict {
+	if x != nil {
+		return x.Verdict
+	}
+	return Verdict_VERDICT_UNSPECIFIED
+}
+
+func (x *Report) GetServices() []*Report {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *Report) GetStdoutTruncated() bool {
+	if x != nil {
+		return x.StdoutTruncated
+	}
+	return false
+}
+
+func (x *Report) GetStderrTruncated() bool {
+	if x != nil {
+		return x.StderrTruncated
+	}
+	return false
+}
+
+// Artifact is an output file collected from the sandbox after a step.
+// Content is empty when omitted is set; fetch it with ArtifactService.
+type Artifact struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Content       []byte                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Omitted       bool                   `protobuf:"varint,4,opt,name=omitted,proto3" json:"omitted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Artifact) Reset() {
+	*x = Artifact{}
+	mi := &file_common_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Artifact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Artifact) ProtoMessage() {}
+
+func (x *Artifact) ProtoReflect() protoreflect.Message {
+	mi := &file_common_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Artifact.ProtoReflect.Descriptor instead.
+func (*Artifact) Descriptor() ([]byte, []int) {
+	return file_common_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Artifact) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Artifact) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+func (x *Artifact) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Artifact) GetOmitted() bool {
+	if x != nil {
+		return x.Omitted
+	}
+	return false
+}
+
 var File_common_proto protoreflect.FileDescriptor
 
 const file_common_proto_rawDesc = "" +
 	"\n" +
 	"\fcommon.proto\x12\n" +
-	"castletown\"4\n" +
+	"castletown\"r\n" +
 	"\x04File\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
-	"\acontent\x18\x02 \x01(\tR\acontent\"\xe2\x01\n" +
+	"\acontent\x18\x02 \x01(\fR\acontent\x12(\n" +
+	"\x04type\x18\x03 \x01(\x0e2\x14.castletown.FileTypeR\x04type\x12\x12\n" +
+	"\x04mode\x18\x04 \x01(\rR\x04mode\"\x9f\x05\n" +
 	"\aProcess\x12\x14\n" +
 	"\x05image\x18\x01 \x01(\tR\x05image\x12\x10\n" +
 	"\x03cmd\x18\x02 \x03(\tR\x03cmd\x12\x14\n" +
@@ -378,19 +793,72 @@ const file_common_proto_rawDesc = "" +
 	"\n" +
 	"proc_limit\x18\x06 \x01(\x03R\tprocLimit\x12\x14\n" +
 	"\x05files\x18\a \x03(\tR\x05files\x12\x18\n" +
-	"\apersist\x18\b \x03(\tR\apersist\"\xa1\x02\n" +
+	"\apersist\x18\b \x03(\tR\apersist\x12\x18\n" +
+	"\aoutputs\x18\t \x03(\tR\aoutputs\x12\x1d\n" +
+	"\n" +
+	"stdin_file\x18\n" +
+	" \x01(\tR\tstdinFile\x12\x1f\n" +
+	"\vstdout_file\x18\v \x01(\tR\n" +
+	"stdoutFile\x12\x1f\n" +
+	"\vstderr_file\x18\f \x01(\tR\n" +
+	"stderrFile\x12+\n" +
+	"\x12file_size_limit_mb\x18\r \x01(\x03R\x0ffileSizeLimitMb\x12\x12\n" +
+	"\x04name\x18\x0e \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"stdin_from\x18\x0f \x01(\tR\tstdinFrom\x123\n" +
+	"\n" +
+	"interactor\x18\x10 \x01(\v2\x13.castletown.ProcessR\n" +
+	"interactor\x12/\n" +
+	"\bservices\x18\x11 \x03(\v2\x13.castletown.ProcessR\bservices\x123\n" +
+	"\treadiness\x18\x12 \x01(\v2\x15.castletown.ReadinessR\treadiness\x12\x10\n" +
+	"\x03env\x18\x13 \x03(\tR\x03env\x12\x1f\n" +
+	"\vworking_dir\x18\x14 \x01(\tR\n" +
+	"workingDir\x12\x12\n" +
+	"\x04user\x18\x15 \x01(\tR\x04user\"E\n" +
+	"\tReadiness\x12\x19\n" +
+	"\btcp_port\x18\x01 \x01(\x05R\atcpPort\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x02 \x01(\x03R\ttimeoutMs\"\xbe\x04\n" +
 	"\x06Report\x12*\n" +
 	"\x06status\x18\x01 \x01(\x0e2\x12.castletown.StatusR\x06status\x12\x1b\n" +
 	"\texit_code\x18\x02 \x01(\x05R\bexitCode\x12\x16\n" +
 	"\x06signal\x18\x03 \x01(\x05R\x06signal\x12\x16\n" +
-	"\x06stdout\x18\x04 \x01(\tR\x06stdout\x12\x16\n" +
-	"\x06stderr\x18\x05 \x01(\tR\x06stderr\x12\x19\n" +
+	"\x06stdout\x18\x04 \x01(\fR\x06stdout\x12\x16\n" +
+	"\x06stderr\x18\x05 \x01(\fR\x06stderr\x12\x19\n" +
 	"\bcpu_time\x18\x06 \x01(\x04R\acpuTime\x12\x16\n" +
 	"\x06memory\x18\a \x01(\x04R\x06memory\x12\x1b\n" +
 	"\twall_time\x18\b \x01(\x03R\bwallTime\x12\x19\n" +
 	"\bstart_at\x18\t \x01(\x03R\astartAt\x12\x1b\n" +
 	"\tfinish_at\x18\n" +
-	" \x01(\x03R\bfinishAt*\xec\x01\n" +
+	" \x01(\x03R\bfinishAt\x122\n" +
+	"\tartifacts\x18\v \x03(\v2\x14.castletown.ArtifactR\tartifacts\x122\n" +
+	"\n" +
+	"interactor\x18\f \x01(\v2\x12.castletown.ReportR\n" +
+	"interactor\x12-\n" +
+	"\averdict\x18\r \x01(\x0e2\x13.castletown.VerdictR\averdict\x12.\n" +
+	"\bservices\x18\x0e \x03(\v2\x12.castletown.ReportR\bservices\x12)\n" +
+	"\x10stdout_truncated\x18\x0f \x01(\bR\x0fstdoutTruncated\x12)\n" +
+	"\x10stderr_truncated\x18\x10 \x01(\bR\x0fstderrTruncated\"f\n" +
+	"\bArtifact\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\fR\acontent\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x18\n" +
+	"\aomitted\x18\x04 \x01(\bR\aomitted*\x8d\x02\n" +
+	"\aVerdict\x12\x17\n" +
+	"\x13VERDICT_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10VERDICT_ACCEPTED\x10\x01\x12\x18\n" +
+	"\x14VERDICT_WRONG_ANSWER\x10\x02\x12\x1e\n" +
+	"\x1aVERDICT_PRESENTATION_ERROR\x10\x03\x12\x1f\n" +
+	"\x1bVERDICT_TIME_LIMIT_EXCEEDED\x10\x04\x12!\n" +
+	"\x1dVERDICT_MEMORY_LIMIT_EXCEEDED\x10\x05\x12!\n" +
+	"\x1dVERDICT_OUTPUT_LIMIT_EXCEEDED\x10\x06\x12\x19\n" +
+	"\x15VERDICT_RUNTIME_ERROR\x10\a\x12\x17\n" +
+	"\x13VERDICT_JUDGE_ERROR\x10\b*Z\n" +
+	"\bFileType\x12\x15\n" +
+	"\x11FILE_TYPE_REGULAR\x10\x00\x12\x11\n" +
+	"\rFILE_TYPE_DIR\x10\x01\x12\x11\n" +
+	"\rFILE_TYPE_TAR\x10\x02\x12\x11\n" +
+	"\rFILE_TYPE_ZIP\x10\x03*\xec\x01\n" +
 	"\x06Status\x12\x16\n" +
 	"\x12STATUS_UNSPECIFIED\x10\x00\x12\r\n" +
 	"\tSTATUS_OK\x10\x01\x12\x18\n" +
@@ -414,21 +882,33 @@ func file_common_proto_rawDescGZIP() []byte {
 	return file_common_proto_rawDescData
 }
 
-var file_common_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_common_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_common_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_common_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_common_proto_goTypes = []any{
-	(Status)(0),     // 0: castletown.Status
-	(*File)(nil),    // 1: castletown.File
-	(*Process)(nil), // 2: castletown.Process
-	(*Report)(nil),  // 3: castletown.Report
+	(Verdict)(0),      // 0: castletown.Verdict
+	(FileType)(0),     // 1: castletown.FileType
+	(Status)(0),       // 2: castletown.Status
+	(*File)(nil),      // 3: castletown.File
+	(*Process)(nil),   // 4: castletown.Process
+	(*Readiness)(nil), // 5: castletown.Readiness
+	(*Report)(nil),    // 6: castletown.Report
+	(*Artifact)(nil),  // 7: castletown.Artifact
 }
 var file_common_proto_depIdxs = []int32{
-	0, // 0: castletown.Report.status:type_name -> castletown.Status
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	1, // 0: castletown.File.type:type_name -> castletown.FileType
+	4, // 1: castletown.Process.interactor:type_name -> castletown.Process
+	4, // 2: castletown.Process.services:type_name -> castletown.Process
+	5, // 3: castletown.Process.readiness:type_name -> castletown.Readiness
+	2, // 4: castletown.Report.status:type_name -> castletown.Status
+	7, // 5: castletown.Report.artifacts:type_name -> castletown.Artifact
+	6, // 6: castletown.Report.interactor:type_name -> castletown.Report
+	0, // 7: castletown.Report.verdict:type_name -> castletown.Verdict
+	6, // 8: castletown.Report.services:type_name -> castletown.Report
+	9, // [9:9] is the sub-list for method output_type
+	9, // [9:9] is the sub-list for method input_type
+	9, // [9:9] is the sub-list for extension type_name
+	9, // [9:9] is the sub-list for extension extendee
+	0, // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_common_proto_init() }
@@ -441,8 +921,8 @@ func file_common_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_common_proto_rawDesc), len(file_common_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   3,
+			NumEnums:      3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
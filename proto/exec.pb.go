@@ -21,6 +21,65 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// ExecEventType tells what an ExecEvent reports
+type ExecEventType int32
+
+const (
+	ExecEventType_EXEC_EVENT_UNSPECIFIED   ExecEventType = 0
+	ExecEventType_EXEC_EVENT_JOB_ACCEPTED  ExecEventType = 1
+	ExecEventType_EXEC_EVENT_STEP_STARTED  ExecEventType = 2
+	ExecEventType_EXEC_EVENT_STEP_FINISHED ExecEventType = 3
+	ExecEventType_EXEC_EVENT_JOB_DONE      ExecEventType = 4
+	ExecEventType_EXEC_EVENT_OUTPUT        ExecEventType = 5
+)
+
+// Enum value maps for ExecEventType.
+var (
+	ExecEventType_name = map[int32]string{
+		0: "EXEC_EVENT_UNSPECIFIED",
+		1: "EXEC_EVENT_JOB_ACCEPTED",
+		2: "EXEC_EVENT_STEP_STARTED",
+		3: "EXEC_EVENT_STEP_FINISHED",
+		4: "EXEC_EVENT_JOB_DONE",
+		5: "EXEC_EVENT_OUTPUT",
+	}
+	ExecEventType_value = map[string]int32{
+		"EXEC_EVENT_UNSPECIFIED":   0,
+		"EXEC_EVENT_JOB_ACCEPTED":  1,
+		"EXEC_EVENT_STEP_STARTED":  2,
+		"EXEC_EVENT_STEP_FINISHED": 3,
+		"EXEC_EVENT_JOB_DONE":      4,
+		"EXEC_EVENT_OUTPUT":        5,
+	}
+)
+
+func (x ExecEventType) Enum() *ExecEventType {
+	p := new(ExecEventType)
+	*p = x
+	return p
+}
+
+func (x ExecEventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ExecEventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_exec_proto_enumTypes[0].Descriptor()
+}
+
+func (ExecEventType) Type() protoreflect.EnumType {
+	return &file_exec_proto_enumTypes[0]
+}
+
+func (x ExecEventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ExecEventType.Descriptor instead.
+func (ExecEventType) EnumDescriptor() ([]byte, []int) {
+	return file_exec_proto_rawDescGZIP(), []int{0}
+}
+
 // ExecRequest contains the job execution parameters
 type ExecRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -135,6 +194,168 @@ func (x *ExecResponse) GetReports() []*Report {
 	return nil
 }
 
+// ExecEvent reports the progress of a job
+type ExecEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          ExecEventType          `protobuf:"varint,1,opt,name=type,proto3,enum=castletown.ExecEventType" json:"type,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Step          int32                  `protobuf:"varint,3,opt,name=step,proto3" json:"step,omitempty"`    // the step concerned, or the next one to run for job events
+	Steps         int32                  `protobuf:"varint,4,opt,name=steps,proto3" json:"steps,omitempty"`  // number of steps of the job
+	Report        *Report                `protobuf:"bytes,5,opt,name=report,proto3" json:"report,omitempty"` // set for EXEC_EVENT_STEP_FINISHED
+	Error         string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`   // set for EXEC_EVENT_JOB_DONE if a step could not be run
+	Time          int64                  `protobuf:"varint,7,opt,name=time,proto3" json:"time,omitempty"`    // Unix timestamp in nanoseconds
+	Output        *OutputChunk           `protobuf:"bytes,8,opt,name=output,proto3" json:"output,omitempty"` // set for EXEC_EVENT_OUTPUT
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecEvent) Reset() {
+	*x = ExecEvent{}
+	mi := &file_exec_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecEvent) ProtoMessage() {}
+
+func (x *ExecEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_exec_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecEvent.ProtoReflect.Descriptor instead.
+func (*ExecEvent) Descriptor() ([]byte, []int) {
+	return file_exec_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ExecEvent) GetType() ExecEventType {
+	if x != nil {
+		return x.Type
+	}
+	return ExecEventType_EXEC_EVENT_UNSPECIFIED
+}
+
+func (x *ExecEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ExecEvent) GetStep() int32 {
+	if x != nil {
+		return x.Step
+	}
+	return 0
+}
+
+func (x *ExecEvent) GetSteps() int32 {
+	if x != nil {
+		return x.Steps
+	}
+	return 0
+}
+
+func (x *ExecEvent) GetReport() *Report {
+	if x != nil {
+		return x.Report
+	}
+	return nil
+}
+
+func (x *ExecEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ExecEvent) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *ExecEvent) GetOutput() *OutputChunk {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+// OutputChunk is a piece of what a step's process wrote while it ran
+type OutputChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stream        string                 `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"` // "stdout" or "stderr"
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Dropped       int64                  `protobuf:"varint,3,opt,name=dropped,proto3" json:"dropped,omitempty"` // bytes of the stream left out before data
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutputChunk) Reset() {
+	*x = OutputChunk{}
+	mi := &file_exec_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputChunk) ProtoMessage() {}
+
+func (x *OutputChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_exec_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputChunk.ProtoReflect.Descriptor instead.
+func (*OutputChunk) Descriptor() ([]byte, []int) {
+	return file_exec_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *OutputChunk) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *OutputChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *OutputChunk) GetDropped() int64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
 var File_exec_proto protoreflect.FileDescriptor
 
 const file_exec_proto_rawDesc = "" +
@@ -148,9 +369,30 @@ const file_exec_proto_rawDesc = "" +
 	"\x05procs\x18\x03 \x03(\v2\x13.castletown.ProcessR\x05procs\"L\n" +
 	"\fExecResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12,\n" +
-	"\areports\x18\x02 \x03(\v2\x12.castletown.ReportR\areports2K\n" +
+	"\areports\x18\x02 \x03(\v2\x12.castletown.ReportR\areports\"\xfb\x01\n" +
+	"\tExecEvent\x12-\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x19.castletown.ExecEventTypeR\x04type\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x12\n" +
+	"\x04step\x18\x03 \x01(\x05R\x04step\x12\x14\n" +
+	"\x05steps\x18\x04 \x01(\x05R\x05steps\x12*\n" +
+	"\x06report\x18\x05 \x01(\v2\x12.castletown.ReportR\x06report\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12\x12\n" +
+	"\x04time\x18\a \x01(\x03R\x04time\x12/\n" +
+	"\x06output\x18\b \x01(\v2\x17.castletown.OutputChunkR\x06output\"S\n" +
+	"\vOutputChunk\x12\x16\n" +
+	"\x06stream\x18\x01 \x01(\tR\x06stream\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12\x18\n" +
+	"\adropped\x18\x03 \x01(\x03R\adropped*\xb3\x01\n" +
+	"\rExecEventType\x12\x1a\n" +
+	"\x16EXEC_EVENT_UNSPECIFIED\x10\x00\x12\x1b\n" +
+	"\x17EXEC_EVENT_JOB_ACCEPTED\x10\x01\x12\x1b\n" +
+	"\x17EXEC_EVENT_STEP_STARTED\x10\x02\x12\x1c\n" +
+	"\x18EXEC_EVENT_STEP_FINISHED\x10\x03\x12\x17\n" +
+	"\x13EXEC_EVENT_JOB_DONE\x10\x04\x12\x15\n" +
+	"\x11EXEC_EVENT_OUTPUT\x10\x052\x8e\x01\n" +
 	"\vExecService\x12<\n" +
-	"\aExecute\x12\x17.castletown.ExecRequest\x1a\x18.castletown.ExecResponseB%Z#github.com/joshjms/castletown/protob\x06proto3"
+	"\aExecute\x12\x17.castletown.ExecRequest\x1a\x18.castletown.ExecResponse\x12A\n" +
+	"\rExecuteStream\x12\x17.castletown.ExecRequest\x1a\x15.castletown.ExecEvent0\x01B%Z#github.com/joshjms/castletown/protob\x06proto3"
 
 var (
 	file_exec_proto_rawDescOnce sync.Once
@@ -164,25 +406,34 @@ func file_exec_proto_rawDescGZIP() []byte {
 	return file_exec_proto_rawDescData
 }
 
-var file_exec_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_exec_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_exec_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_exec_proto_goTypes = []any{
-	(*ExecRequest)(nil),  // 0: castletown.ExecRequest
-	(*ExecResponse)(nil), // 1: castletown.ExecResponse
-	(*File)(nil),         // 2: castletown.File
-	(*Process)(nil),      // 3: castletown.Process
-	(*Report)(nil),       // 4: castletown.Report
+	(ExecEventType)(0),   // 0: castletown.ExecEventType
+	(*ExecRequest)(nil),  // 1: castletown.ExecRequest
+	(*ExecResponse)(nil), // 2: castletown.ExecResponse
+	(*ExecEvent)(nil),    // 3: castletown.ExecEvent
+	(*OutputChunk)(nil),  // 4: castletown.OutputChunk
+	(*File)(nil),         // 5: castletown.File
+	(*Process)(nil),      // 6: castletown.Process
+	(*Report)(nil),       // 7: castletown.Report
 }
 var file_exec_proto_depIdxs = []int32{
-	2, // 0: castletown.ExecRequest.files:type_name -> castletown.File
-	3, // 1: castletown.ExecRequest.procs:type_name -> castletown.Process
-	4, // 2: castletown.ExecResponse.reports:type_name -> castletown.Report
-	0, // 3: castletown.ExecService.Execute:input_type -> castletown.ExecRequest
-	1, // 4: castletown.ExecService.Execute:output_type -> castletown.ExecResponse
-	4, // [4:5] is the sub-list for method output_type
-	3, // [3:4] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	5, // 0: castletown.ExecRequest.files:type_name -> castletown.File
+	6, // 1: castletown.ExecRequest.procs:type_name -> castletown.Process
+	7, // 2: castletown.ExecResponse.reports:type_name -> castletown.Report
+	0, // 3: castletown.ExecEvent.type:type_name -> castletown.ExecEventType
+	7, // 4: castletown.ExecEvent.report:type_name -> castletown.Report
+	4, // 5: castletown.ExecEvent.output:type_name -> castletown.OutputChunk
+	1, // 6: castletown.ExecService.Execute:input_type -> castletown.ExecRequest
+	1, // 7: castletown.ExecService.ExecuteStream:input_type -> castletown.ExecRequest
+	2, // 8: castletown.ExecService.Execute:output_type -> castletown.ExecResponse
+	3, // 9: castletown.ExecService.ExecuteStream:output_type -> castletown.ExecEvent
+	8, // [8:10] is the sub-list for method output_type
+	6, // [6:8] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_exec_proto_init() }
@@ -196,13 +447,14 @@ func file_exec_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_exec_proto_rawDesc), len(file_exec_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      1,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_exec_proto_goTypes,
 		DependencyIndexes: file_exec_proto_depIdxs,
+		EnumInfos:         file_exec_proto_enumTypes,
 		MessageInfos:      file_exec_proto_msgTypes,
 	}.Build()
 	File_exec_proto = out.File
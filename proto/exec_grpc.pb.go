@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ExecService_Execute_FullMethodName = "/castletown.ExecService/Execute"
+	ExecService_Execute_FullMethodName       = "/castletown.ExecService/Execute"
+	ExecService_ExecuteStream_FullMethodName = "/castletown.ExecService/ExecuteStream"
 )
 
 // ExecServiceClient is the client API for ExecService service.
@@ -29,6 +30,10 @@ const (
 // ExecService handles job execution requests
 type ExecServiceClient interface {
 	Execute(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ExecResponse, error)
+	// ExecuteStream runs a job like Execute, sending an event as the job is
+	// accepted, as each step starts and finishes and, last, as the job is done.
+	// Output of the steps is sent while they run.
+	ExecuteStream(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecEvent], error)
 }
 
 type execServiceClient struct {
@@ -49,6 +54,25 @@ func (c *execServiceClient) Execute(ctx context.Context, in *ExecRequest, opts .
 	return out, nil
 }
 
+func (c *execServiceClient) ExecuteStream(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ExecService_ServiceDesc.Streams[0], ExecService_ExecuteStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExecRequest, ExecEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ExecService_ExecuteStreamClient = grpc.ServerStreamingClient[ExecEvent]
+
 // ExecServiceServer is the server API for ExecService service.
 // All implementations must embed UnimplementedExecServiceServer
 // for forward compatibility.
@@ -56,6 +80,10 @@ func (c *execServiceClient) Execute(ctx context.Context, in *ExecRequest, opts .
 // ExecService handles job execution requests
 type ExecServiceServer interface {
 	Execute(context.Context, *ExecRequest) (*ExecResponse, error)
+	// ExecuteStream runs a job like Execute, sending an event as the job is
+	// accepted, as each step starts and finishes and, last, as the job is done.
+	// Output of the steps is sent while they run.
+	ExecuteStream(*ExecRequest, grpc.ServerStreamingServer[ExecEvent]) error
 	mustEmbedUnimplementedExecServiceServer()
 }
 
@@ -69,6 +97,9 @@ type UnimplementedExecServiceServer struct{}
 func (UnimplementedExecServiceServer) Execute(context.Context, *ExecRequest) (*ExecResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Execute not implemented")
 }
+func (UnimplementedExecServiceServer) ExecuteStream(*ExecRequest, grpc.ServerStreamingServer[ExecEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method ExecuteStream not implemented")
+}
 func (UnimplementedExecServiceServer) mustEmbedUnimplementedExecServiceServer() {}
 func (UnimplementedExecServiceServer) testEmbeddedByValue()                     {}
 
@@ -108,6 +139,17 @@ func _ExecService_Execute_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ExecService_ExecuteStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExecServiceServer).ExecuteStream(m, &grpc.GenericServerStream[ExecRequest, ExecEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ExecService_ExecuteStreamServer = grpc.ServerStreamingServer[ExecEvent]
+
 // ExecService_ServiceDesc is the grpc.ServiceDesc for ExecService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -120,6 +162,12 @@ var ExecService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ExecService_Execute_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExecuteStream",
+			Handler:       _ExecService_ExecuteStream_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "exec.proto",
 }
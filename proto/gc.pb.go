@@ -0,0 +1,330 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v6.32.0
+// source: gc.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// CollectRequest asks for a collection, only reporting what it would remove
+// when dry_run is set
+type CollectRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DryRun        bool                   `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CollectRequest) Reset() {
+	*x = CollectRequest{}
+	mi := &file_gc_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CollectRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CollectRequest) ProtoMessage() {}
+
+func (x *CollectRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gc_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CollectRequest.ProtoReflect.Descriptor instead.
+func (*CollectRequest) Descriptor() ([]byte, []int) {
+	return file_gc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CollectRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+// CollectResponse lists what the collection removed
+type CollectResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Layers        []string               `protobuf:"bytes,1,rep,name=layers,proto3" json:"layers,omitempty"`     // diff IDs
+	Overlays      []string               `protobuf:"bytes,2,rep,name=overlays,proto3" json:"overlays,omitempty"` // overlay directory names
+	Storage       []string               `protobuf:"bytes,3,rep,name=storage,proto3" json:"storage,omitempty"`   // job IDs
+	Freed         int64                  `protobuf:"varint,4,opt,name=freed,proto3" json:"freed,omitempty"`      // bytes
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CollectResponse) Reset() {
+	*x = CollectResponse{}
+	mi := &file_gc_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CollectResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CollectResponse) ProtoMessage() {}
+
+func (x *CollectResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gc_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CollectResponse.ProtoReflect.Descriptor instead.
+func (*CollectResponse) Descriptor() ([]byte, []int) {
+	return file_gc_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CollectResponse) GetLayers() []string {
+	if x != nil {
+		return x.Layers
+	}
+	return nil
+}
+
+func (x *CollectResponse) GetOverlays() []string {
+	if x != nil {
+		return x.Overlays
+	}
+	return nil
+}
+
+func (x *CollectResponse) GetStorage() []string {
+	if x != nil {
+		return x.Storage
+	}
+	return nil
+}
+
+func (x *CollectResponse) GetFreed() int64 {
+	if x != nil {
+		return x.Freed
+	}
+	return 0
+}
+
+// DiskUsageRequest is an empty request
+type DiskUsageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiskUsageRequest) Reset() {
+	*x = DiskUsageRequest{}
+	mi := &file_gc_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiskUsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiskUsageRequest) ProtoMessage() {}
+
+func (x *DiskUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gc_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiskUsageRequest.ProtoReflect.Descriptor instead.
+func (*DiskUsageRequest) Descriptor() ([]byte, []int) {
+	return file_gc_proto_rawDescGZIP(), []int{2}
+}
+
+// DiskUsageResponse is the disk space taken by each kind of data in bytes
+type DiskUsageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Images        int64                  `protobuf:"varint,1,opt,name=images,proto3" json:"images,omitempty"`
+	Layers        int64                  `protobuf:"varint,2,opt,name=layers,proto3" json:"layers,omitempty"`
+	Overlays      int64                  `protobuf:"varint,3,opt,name=overlays,proto3" json:"overlays,omitempty"`
+	Storage       int64                  `protobuf:"varint,4,opt,name=storage,proto3" json:"storage,omitempty"`
+	Reclaimable   int64                  `protobuf:"varint,5,opt,name=reclaimable,proto3" json:"reclaimable,omitempty"` // what a collection would free now
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiskUsageResponse) Reset() {
+	*x = DiskUsageResponse{}
+	mi := &file_gc_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiskUsageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiskUsageResponse) ProtoMessage() {}
+
+func (x *DiskUsageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gc_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiskUsageResponse.ProtoReflect.Descriptor instead.
+func (*DiskUsageResponse) Descriptor() ([]byte, []int) {
+	return file_gc_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DiskUsageResponse) GetImages() int64 {
+	if x != nil {
+		return x.Images
+	}
+	return 0
+}
+
+func (x *DiskUsageResponse) GetLayers() int64 {
+	if x != nil {
+		return x.Layers
+	}
+	return 0
+}
+
+func (x *DiskUsageResponse) GetOverlays() int64 {
+	if x != nil {
+		return x.Overlays
+	}
+	return 0
+}
+
+func (x *DiskUsageResponse) GetStorage() int64 {
+	if x != nil {
+		return x.Storage
+	}
+	return 0
+}
+
+func (x *DiskUsageResponse) GetReclaimable() int64 {
+	if x != nil {
+		return x.Reclaimable
+	}
+	return 0
+}
+
+var File_gc_proto protoreflect.FileDescriptor
+
+const file_gc_proto_rawDesc = "" +
+	"\n" +
+	"\bgc.proto\x12\n" +
+	"castletown\")\n" +
+	"\x0eCollectRequest\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\"u\n" +
+	"\x0fCollectResponse\x12\x16\n" +
+	"\x06layers\x18\x01 \x03(\tR\x06layers\x12\x1a\n" +
+	"\boverlays\x18\x02 \x03(\tR\boverlays\x12\x18\n" +
+	"\astorage\x18\x03 \x03(\tR\astorage\x12\x14\n" +
+	"\x05freed\x18\x04 \x01(\x03R\x05freed\"\x12\n" +
+	"\x10DiskUsageRequest\"\x9b\x01\n" +
+	"\x11DiskUsageResponse\x12\x16\n" +
+	"\x06images\x18\x01 \x01(\x03R\x06images\x12\x16\n" +
+	"\x06layers\x18\x02 \x01(\x03R\x06layers\x12\x1a\n" +
+	"\boverlays\x18\x03 \x01(\x03R\boverlays\x12\x18\n" +
+	"\astorage\x18\x04 \x01(\x03R\astorage\x12 \n" +
+	"\vreclaimable\x18\x05 \x01(\x03R\vreclaimable2\x99\x01\n" +
+	"\tGCService\x12B\n" +
+	"\aCollect\x12\x1a.castletown.CollectRequest\x1a\x1b.castletown.CollectResponse\x12H\n" +
+	"\tDiskUsage\x12\x1c.castletown.DiskUsageRequest\x1a\x1d.castletown.DiskUsageResponseB%Z#github.com/joshjms/castletown/protob\x06proto3"
+
+var (
+	file_gc_proto_rawDescOnce sync.Once
+	file_gc_proto_rawDescData []byte
+)
+
+func file_gc_proto_rawDescGZIP() []byte {
+	file_gc_proto_rawDescOnce.Do(func() {
+		file_gc_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gc_proto_rawDesc), len(file_gc_proto_rawDesc)))
+	})
+	return file_gc_proto_rawDescData
+}
+
+var file_gc_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_gc_proto_goTypes = []any{
+	(*CollectRequest)(nil),    // 0: castletown.CollectRequest
+	(*CollectResponse)(nil),   // 1: castletown.CollectResponse
+	(*DiskUsageRequest)(nil),  // 2: castletown.DiskUsageRequest
+	(*DiskUsageResponse)(nil), // 3: castletown.DiskUsageResponse
+}
+var file_gc_proto_depIdxs = []int32{
+	0, // 0: castletown.GCService.Collect:input_type -> castletown.CollectRequest
+	2, // 1: castletown.GCService.DiskUsage:input_type -> castletown.DiskUsageRequest
+	1, // 2: castletown.GCService.Collect:output_type -> castletown.CollectResponse
+	3, // 3: castletown.GCService.DiskUsage:output_type -> castletown.DiskUsageResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_gc_proto_init() }
+func file_gc_proto_init() {
+	if File_gc_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gc_proto_rawDesc), len(file_gc_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gc_proto_goTypes,
+		DependencyIndexes: file_gc_proto_depIdxs,
+		MessageInfos:      file_gc_proto_msgTypes,
+	}.Build()
+	File_gc_proto = out.File
+	file_gc_proto_goTypes = nil
+	file_gc_proto_depIdxs = nil
+}
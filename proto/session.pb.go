@@ -0,0 +1,480 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v6.32.0
+// source: session.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SessionRequest is sent by the client
+type SessionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Msg:
+	//
+	//	*SessionRequest_Start
+	//	*SessionRequest_Stdin
+	//	*SessionRequest_CloseStdin
+	//	*SessionRequest_Resize
+	Msg           isSessionRequest_Msg `protobuf_oneof:"msg"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionRequest) Reset() {
+	*x = SessionRequest{}
+	mi := &file_session_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionRequest) ProtoMessage() {}
+
+func (x *SessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_session_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionRequest.ProtoReflect.Descriptor instead.
+func (*SessionRequest) Descriptor() ([]byte, []int) {
+	return file_session_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SessionRequest) GetMsg() isSessionRequest_Msg {
+	if x != nil {
+		return x.Msg
+	}
+	return nil
+}
+
+func (x *SessionRequest) GetStart() *SessionStart {
+	if x != nil {
+		if x, ok := x.Msg.(*SessionRequest_Start); ok {
+			return x.Start
+		}
+	}
+	return nil
+}
+
+func (x *SessionRequest) GetStdin() []byte {
+	if x != nil {
+		if x, ok := x.Msg.(*SessionRequest_Stdin); ok {
+			return x.Stdin
+		}
+	}
+	return nil
+}
+
+func (x *SessionRequest) GetCloseStdin() bool {
+	if x != nil {
+		if x, ok := x.Msg.(*SessionRequest_CloseStdin); ok {
+			return x.CloseStdin
+		}
+	}
+	return false
+}
+
+func (x *SessionRequest) GetResize() *TerminalSize {
+	if x != nil {
+		if x, ok := x.Msg.(*SessionRequest_Resize); ok {
+			return x.Resize
+		}
+	}
+	return nil
+}
+
+type isSessionRequest_Msg interface {
+	isSessionRequest_Msg()
+}
+
+type SessionRequest_Start struct {
+	Start *SessionStart `protobuf:"bytes,1,opt,name=start,proto3,oneof"`
+}
+
+type SessionRequest_Stdin struct {
+	Stdin []byte `protobuf:"bytes,2,opt,name=stdin,proto3,oneof"`
+}
+
+type SessionRequest_CloseStdin struct {
+	CloseStdin bool `protobuf:"varint,3,opt,name=close_stdin,json=closeStdin,proto3,oneof"`
+}
+
+type SessionRequest_Resize struct {
+	Resize *TerminalSize `protobuf:"bytes,4,opt,name=resize,proto3,oneof"` // sessions with a terminal only
+}
+
+func (*SessionRequest_Start) isSessionRequest_Msg() {}
+
+func (*SessionRequest_Stdin) isSessionRequest_Msg() {}
+
+func (*SessionRequest_CloseStdin) isSessionRequest_Msg() {}
+
+func (*SessionRequest_Resize) isSessionRequest_Msg() {}
+
+// SessionStart describes the process of a session. Its time limit is the CPU
+// budget of the whole session.
+type SessionStart struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*File                `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	Process       *Process               `protobuf:"bytes,2,opt,name=process,proto3" json:"process,omitempty"`
+	IdleTimeoutMs int64                  `protobuf:"varint,3,opt,name=idle_timeout_ms,json=idleTimeoutMs,proto3" json:"idle_timeout_ms,omitempty"` // 0 = server default, cannot exceed it
+	Terminal      *TerminalSize          `protobuf:"bytes,4,opt,name=terminal,proto3" json:"terminal,omitempty"`                                   // run on a pseudo-terminal of this size
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionStart) Reset() {
+	*x = SessionStart{}
+	mi := &file_session_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionStart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionStart) ProtoMessage() {}
+
+func (x *SessionStart) ProtoReflect() protoreflect.Message {
+	mi := &file_session_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionStart.ProtoReflect.Descriptor instead.
+func (*SessionStart) Descriptor() ([]byte, []int) {
+	return file_session_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SessionStart) GetFiles() []*File {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *SessionStart) GetProcess() *Process {
+	if x != nil {
+		return x.Process
+	}
+	return nil
+}
+
+func (x *SessionStart) GetIdleTimeoutMs() int64 {
+	if x != nil {
+		return x.IdleTimeoutMs
+	}
+	return 0
+}
+
+func (x *SessionStart) GetTerminal() *TerminalSize {
+	if x != nil {
+		return x.Terminal
+	}
+	return nil
+}
+
+// TerminalSize is the size of a terminal in characters
+type TerminalSize struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cols          uint32                 `protobuf:"varint,1,opt,name=cols,proto3" json:"cols,omitempty"`
+	Rows          uint32                 `protobuf:"varint,2,opt,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TerminalSize) Reset() {
+	*x = TerminalSize{}
+	mi := &file_session_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TerminalSize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TerminalSize) ProtoMessage() {}
+
+func (x *TerminalSize) ProtoReflect() protoreflect.Message {
+	mi := &file_session_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TerminalSize.ProtoReflect.Descriptor instead.
+func (*TerminalSize) Descriptor() ([]byte, []int) {
+	return file_session_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TerminalSize) GetCols() uint32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
+func (x *TerminalSize) GetRows() uint32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+// SessionResponse is sent by the server
+type SessionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Msg:
+	//
+	//	*SessionResponse_Id
+	//	*SessionResponse_Stdout
+	//	*SessionResponse_Stderr
+	//	*SessionResponse_Exit
+	Msg           isSessionResponse_Msg `protobuf_oneof:"msg"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionResponse) Reset() {
+	*x = SessionResponse{}
+	mi := &file_session_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionResponse) ProtoMessage() {}
+
+func (x *SessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_session_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionResponse.ProtoReflect.Descriptor instead.
+func (*SessionResponse) Descriptor() ([]byte, []int) {
+	return file_session_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SessionResponse) GetMsg() isSessionResponse_Msg {
+	if x != nil {
+		return x.Msg
+	}
+	return nil
+}
+
+func (x *SessionResponse) GetId() string {
+	if x != nil {
+		if x, ok := x.Msg.(*SessionResponse_Id); ok {
+			return x.Id
+		}
+	}
+	return ""
+}
+
+func (x *SessionResponse) GetStdout() []byte {
+	if x != nil {
+		if x, ok := x.Msg.(*SessionResponse_Stdout); ok {
+			return x.Stdout
+		}
+	}
+	return nil
+}
+
+func (x *SessionResponse) GetStderr() []byte {
+	if x != nil {
+		if x, ok := x.Msg.(*SessionResponse_Stderr); ok {
+			return x.Stderr
+		}
+	}
+	return nil
+}
+
+func (x *SessionResponse) GetExit() *Report {
+	if x != nil {
+		if x, ok := x.Msg.(*SessionResponse_Exit); ok {
+			return x.Exit
+		}
+	}
+	return nil
+}
+
+type isSessionResponse_Msg interface {
+	isSessionResponse_Msg()
+}
+
+type SessionResponse_Id struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3,oneof"`
+}
+
+type SessionResponse_Stdout struct {
+	Stdout []byte `protobuf:"bytes,2,opt,name=stdout,proto3,oneof"`
+}
+
+type SessionResponse_Stderr struct {
+	Stderr []byte `protobuf:"bytes,3,opt,name=stderr,proto3,oneof"`
+}
+
+type SessionResponse_Exit struct {
+	Exit *Report `protobuf:"bytes,4,opt,name=exit,proto3,oneof"`
+}
+
+func (*SessionResponse_Id) isSessionResponse_Msg() {}
+
+func (*SessionResponse_Stdout) isSessionResponse_Msg() {}
+
+func (*SessionResponse_Stderr) isSessionResponse_Msg() {}
+
+func (*SessionResponse_Exit) isSessionResponse_Msg() {}
+
+var File_session_proto protoreflect.FileDescriptor
+
+const file_session_proto_rawDesc = "" +
+	"\n" +
+	"\rsession.proto\x12\n" +
+	"castletown\x1a\fcommon.proto\"\xb8\x01\n" +
+	"\x0eSessionRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x18.castletown.SessionStartH\x00R\x05start\x12\x16\n" +
+	"\x05stdin\x18\x02 \x01(\fH\x00R\x05stdin\x12!\n" +
+	"\vclose_stdin\x18\x03 \x01(\bH\x00R\n" +
+	"closeStdin\x122\n" +
+	"\x06resize\x18\x04 \x01(\v2\x18.castletown.TerminalSizeH\x00R\x06resizeB\x05\n" +
+	"\x03msg\"\xc3\x01\n" +
+	"\fSessionStart\x12&\n" +
+	"\x05files\x18\x01 \x03(\v2\x10.castletown.FileR\x05files\x12-\n" +
+	"\aprocess\x18\x02 \x01(\v2\x13.castletown.ProcessR\aprocess\x12&\n" +
+	"\x0fidle_timeout_ms\x18\x03 \x01(\x03R\ridleTimeoutMs\x124\n" +
+	"\bterminal\x18\x04 \x01(\v2\x18.castletown.TerminalSizeR\bterminal\"6\n" +
+	"\fTerminalSize\x12\x12\n" +
+	"\x04cols\x18\x01 \x01(\rR\x04cols\x12\x12\n" +
+	"\x04rows\x18\x02 \x01(\rR\x04rows\"\x88\x01\n" +
+	"\x0fSessionResponse\x12\x10\n" +
+	"\x02id\x18\x01 \x01(\tH\x00R\x02id\x12\x18\n" +
+	"\x06stdout\x18\x02 \x01(\fH\x00R\x06stdout\x12\x18\n" +
+	"\x06stderr\x18\x03 \x01(\fH\x00R\x06stderr\x12(\n" +
+	"\x04exit\x18\x04 \x01(\v2\x12.castletown.ReportH\x00R\x04exitB\x05\n" +
+	"\x03msg2X\n" +
+	"\x0eSessionService\x12F\n" +
+	"\aSession\x12\x1a.castletown.SessionRequest\x1a\x1b.castletown.SessionResponse(\x010\x01B%Z#github.com/joshjms/castletown/protob\x06proto3"
+
+var (
+	file_session_proto_rawDescOnce sync.Once
+	file_session_proto_rawDescData []byte
+)
+
+func file_session_proto_rawDescGZIP() []byte {
+	file_session_proto_rawDescOnce.Do(func() {
+		file_session_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_session_proto_rawDesc), len(file_session_proto_rawDesc)))
+	})
+	return file_session_proto_rawDescData
+}
+
+var file_session_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_session_proto_goTypes = []any{
+	(*SessionRequest)(nil),  // 0: castletown.SessionRequest
+	(*SessionStart)(nil),    // 1: castletown.SessionStart
+	(*TerminalSize)(nil),    // 2: castletown.TerminalSize
+	(*SessionResponse)(nil), // 3: castletown.SessionResponse
+	(*File)(nil),            // 4: castletown.File
+	(*Process)(nil),         // 5: castletown.Process
+	(*Report)(nil),          // 6: castletown.Report
+}
+var file_session_proto_depIdxs = []int32{
+	1, // 0: castletown.SessionRequest.start:type_name -> castletown.SessionStart
+	2, // 1: castletown.SessionRequest.resize:type_name -> castletown.TerminalSize
+	4, // 2: castletown.SessionStart.files:type_name -> castletown.File
+	5, // 3: castletown.SessionStart.process:type_name -> castletown.Process
+	2, // 4: castletown.SessionStart.terminal:type_name -> castletown.TerminalSize
+	6, // 5: castletown.SessionResponse.exit:type_name -> castletown.Report
+	0, // 6: castletown.SessionService.Session:input_type -> castletown.SessionRequest
+	3, // 7: castletown.SessionService.Session:output_type -> castletown.SessionResponse
+	7, // [7:8] is the sub-list for method output_type
+	6, // [6:7] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_session_proto_init() }
+func file_session_proto_init() {
+	if File_session_proto != nil {
+		return
+	}
+	file_common_proto_init()
+	file_session_proto_msgTypes[0].OneofWrappers = []any{
+		(*SessionRequest_Start)(nil),
+		(*SessionRequest_Stdin)(nil),
+		(*SessionRequest_CloseStdin)(nil),
+		(*SessionRequest_Resize)(nil),
+	}
+	file_session_proto_msgTypes[3].OneofWrappers = []any{
+		(*SessionResponse_Id)(nil),
+		(*SessionResponse_Stdout)(nil),
+		(*SessionResponse_Stderr)(nil),
+		(*SessionResponse_Exit)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_session_proto_rawDesc), len(file_session_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_session_proto_goTypes,
+		DependencyIndexes: file_session_proto_depIdxs,
+		MessageInfos:      file_session_proto_msgTypes,
+	}.Build()
+	File_session_proto = out.File
+	file_session_proto_goTypes = nil
+	file_session_proto_depIdxs = nil
+}
@@ -0,0 +1,43 @@
+package quota
+
+import (
+	"context"
+
+	"github.com/joshjms/castletown/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// GRPCStatus makes gRPC answer calls failing with e with RESOURCE_EXHAUSTED,
+// carrying the retry hint as RetryInfo.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.ResourceExhausted, e.Error())
+	withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)})
+	if err != nil {
+		return st
+	}
+	return withInfo
+}
+
+// UnaryInterceptor counts unary calls like Middleware does requests.
+func (l *Limiter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := l.Allow(auth.Name(ctx)); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor counts streaming calls like Middleware does requests.
+func (l *Limiter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.Allow(auth.Name(ss.Context())); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,35 @@
+package quota
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/joshjms/castletown/auth"
+)
+
+// Middleware counts each request against the request rate of its
+// principal, answering those over it with fail. It must run after the
+// principal is known.
+func (l *Limiter) Middleware(next http.Handler, fail func(http.ResponseWriter, *http.Request, string, int)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.Allow(auth.Name(r.Context())); err != nil {
+			SetRetryAfter(w, err)
+			fail(w, r, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetRetryAfter sets the Retry-After header of a response to a request
+// that failed with err, and tells whether err is an *Error, which calls for
+// a 429 response.
+func SetRetryAfter(w http.ResponseWriter, err error) bool {
+	var qerr *Error
+	if !errors.As(err, &qerr) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(qerr.RetryAfterSeconds()))
+	return true
+}
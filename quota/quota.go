@@ -0,0 +1,234 @@
+// Package quota limits how much of the server each principal may use: how
+// many requests it makes per second, how many jobs it runs at once and how
+// much CPU time its jobs take per hour. Requests over a limit are turned
+// away before their jobs reach the sandbox manager, with a hint of when to
+// retry. Requests without a principal, made when authentication is off, are
+// not limited.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/joshjms/castletown/auth"
+	"github.com/joshjms/castletown/sandbox"
+)
+
+// Limits that may be exceeded.
+const (
+	LIMIT_REQUEST_RATE    = "request rate"
+	LIMIT_CONCURRENT_JOBS = "concurrent jobs"
+	LIMIT_CPU_TIME        = "CPU time"
+)
+
+// CPU_WINDOW is the period over which the CPU time of jobs is counted.
+const CPU_WINDOW = time.Hour
+
+// BUSY_RETRY_AFTER is the retry hint for a principal running as many jobs as
+// it may, since when one of them ends cannot be known.
+const BUSY_RETRY_AFTER = time.Second
+
+var l *Limiter
+
+// Limits are what each principal may use. Zero means unlimited.
+type Limits struct {
+	// RequestsPerSecond is the sustained rate of requests. Bursts of up to
+	// a second's worth of requests, and at least one, are allowed.
+	RequestsPerSecond float64
+
+	// ConcurrentJobs is how many jobs, builds and sessions may run at once.
+	ConcurrentJobs int
+
+	// CPUSecondsPerHour is the CPU time jobs may take in the last hour. A
+	// job that starts under it may take it over; new jobs then wait until
+	// enough of that time is older than an hour.
+	CPUSecondsPerHour float64
+}
+
+// Error is returned for requests over a limit.
+type Error struct {
+	Limit      string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s limit exceeded, retry after %s", e.Limit, e.RetryAfter.Round(time.Millisecond))
+}
+
+// RetryAfterSeconds is RetryAfter as the whole number of seconds of a
+// Retry-After header, rounded up.
+func (e *Error) RetryAfterSeconds() int {
+	return max(1, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// Limiter keeps track of what each principal uses.
+type Limiter struct {
+	limits Limits
+	now    func() time.Time
+
+	mu         sync.Mutex
+	principals map[string]*usage
+}
+
+type usage struct {
+	tokens     float64
+	refilledAt time.Time
+	running    int
+	cpu        []cpuUse
+}
+
+type cpuUse struct {
+	at   time.Time
+	time time.Duration
+}
+
+// NewLimiter sets up the limiter returned by GetLimiter.
+func NewLimiter(limits Limits) {
+	l = newLimiter(limits)
+}
+
+// GetLimiter returns the limiter set up by NewLimiter, or nil if there is
+// none. A nil limiter limits nothing.
+func GetLimiter() *Limiter {
+	return l
+}
+
+func newLimiter(limits Limits) *Limiter {
+	return &Limiter{
+		limits:     limits,
+		now:        time.Now,
+		principals: make(map[string]*usage),
+	}
+}
+
+func (l *Limiter) burst() float64 {
+	return max(1, math.Ceil(l.limits.RequestsPerSecond))
+}
+
+// usage returns what principal uses. l.mu must be held.
+func (l *Limiter) usage(principal string) *usage {
+	u, ok := l.principals[principal]
+	if !ok {
+		u = &usage{tokens: l.burst(), refilledAt: l.now()}
+		l.principals[principal] = u
+	}
+	return u
+}
+
+// Allow counts a request of principal, returning an *Error if it makes
+// more than it may.
+func (l *Limiter) Allow(principal string) error {
+	if l == nil || principal == "" || l.limits.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.usage(principal)
+	now := l.now()
+	rate := l.limits.RequestsPerSecond
+	u.tokens = min(l.burst(), u.tokens+now.Sub(u.refilledAt).Seconds()*rate)
+	u.refilledAt = now
+
+	if u.tokens < 1 {
+		return &Error{
+			Limit:      LIMIT_REQUEST_RATE,
+			RetryAfter: time.Duration((1 - u.tokens) / rate * float64(time.Second)),
+		}
+	}
+	u.tokens--
+	return nil
+}
+
+// StartJob counts a job of principal as running, returning an *Error if it
+// may not run another one yet. Otherwise, done must be called with the CPU
+// time the job took once it ends.
+func (l *Limiter) StartJob(principal string) (done func(cpuTime time.Duration), err error) {
+	if l == nil || principal == "" {
+		return func(time.Duration) {}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.usage(principal)
+	if l.limits.ConcurrentJobs > 0 && u.running >= l.limits.ConcurrentJobs {
+		return nil, &Error{Limit: LIMIT_CONCURRENT_JOBS, RetryAfter: BUSY_RETRY_AFTER}
+	}
+	if retryAfter, ok := l.cpuAvailable(u); !ok {
+		return nil, &Error{Limit: LIMIT_CPU_TIME, RetryAfter: retryAfter}
+	}
+
+	u.running++
+	var once sync.Once
+	return func(cpuTime time.Duration) {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			u.running--
+			if cpuTime > 0 {
+				u.cpu = append(u.cpu, cpuUse{at: l.now(), time: cpuTime})
+			}
+		})
+	}, nil
+}
+
+// cpuAvailable tells whether u took less CPU time than it may in the last
+// CPU_WINDOW and, if not, how long until it will have. l.mu must be held.
+func (l *Limiter) cpuAvailable(u *usage) (time.Duration, bool) {
+	if l.limits.CPUSecondsPerHour <= 0 {
+		return 0, true
+	}
+
+	now := l.now()
+	for len(u.cpu) > 0 && now.Sub(u.cpu[0].at) >= CPU_WINDOW {
+		u.cpu = u.cpu[1:]
+	}
+
+	quota := time.Duration(l.limits.CPUSecondsPerHour * float64(time.Second))
+	var used time.Duration
+	for _, c := range u.cpu {
+		used += c.time
+	}
+	if used < quota {
+		return 0, true
+	}
+
+	// Wait for the oldest uses to leave the window until enough has.
+	for _, c := range u.cpu {
+		used -= c.time
+		if used < quota {
+			return c.at.Add(CPU_WINDOW).Sub(now), false
+		}
+	}
+	return 0, true
+}
+
+// Allow counts a request of the principal ctx carries.
+func Allow(ctx context.Context) error {
+	return GetLimiter().Allow(auth.Name(ctx))
+}
+
+// StartJob counts a job of the principal ctx carries as running.
+func StartJob(ctx context.Context) (done func(cpuTime time.Duration), err error) {
+	return GetLimiter().StartJob(auth.Name(ctx))
+}
+
+// CPUTime is the CPU time taken by the processes reports are of, including
+// interactors and services.
+func CPUTime(reports []sandbox.Report) time.Duration {
+	var total time.Duration
+	for _, r := range reports {
+		total += time.Duration(r.CPUTime) * time.Microsecond
+		if r.Interactor != nil {
+			total += CPUTime([]sandbox.Report{*r.Interactor})
+		}
+		total += CPUTime(r.Services)
+	}
+	return total
+}
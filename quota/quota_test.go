@@ -0,0 +1,149 @@
+package quota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joshjms/castletown/auth"
+	"github.com/joshjms/castletown/sandbox"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newTestLimiter(limits Limits) (*Limiter, *clock) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	l := newLimiter(limits)
+	l.now = c.now
+	return l, c
+}
+
+func requireLimit(t *testing.T, err error, limit string, retryAfter time.Duration) {
+	qerr, ok := err.(*Error)
+	require.True(t, ok, "%v", err)
+	require.Equal(t, limit, qerr.Limit)
+	require.Equal(t, retryAfter, qerr.RetryAfter)
+}
+
+func TestAllow(t *testing.T) {
+	l, c := newTestLimiter(Limits{RequestsPerSecond: 2})
+
+	require.NoError(t, l.Allow("grader"))
+	require.NoError(t, l.Allow("grader"))
+	requireLimit(t, l.Allow("grader"), LIMIT_REQUEST_RATE, 500*time.Millisecond)
+
+	// Others and unauthenticated requests have their own allowance.
+	require.NoError(t, l.Allow("judge"))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, l.Allow(""))
+	}
+
+	c.t = c.t.Add(500 * time.Millisecond)
+	require.NoError(t, l.Allow("grader"))
+	require.Error(t, l.Allow("grader"))
+
+	// The bucket holds no more than a second's worth.
+	c.t = c.t.Add(time.Hour)
+	require.NoError(t, l.Allow("grader"))
+	require.NoError(t, l.Allow("grader"))
+	require.Error(t, l.Allow("grader"))
+}
+
+func TestConcurrentJobs(t *testing.T) {
+	l, _ := newTestLimiter(Limits{ConcurrentJobs: 2})
+
+	done1, err := l.StartJob("grader")
+	require.NoError(t, err)
+	_, err = l.StartJob("grader")
+	require.NoError(t, err)
+
+	_, err = l.StartJob("grader")
+	requireLimit(t, err, LIMIT_CONCURRENT_JOBS, BUSY_RETRY_AFTER)
+	_, err = l.StartJob("judge")
+	require.NoError(t, err)
+
+	// Ending a job twice frees one slot only.
+	done1(0)
+	done1(0)
+	_, err = l.StartJob("grader")
+	require.NoError(t, err)
+	_, err = l.StartJob("grader")
+	require.Error(t, err)
+}
+
+func TestCPUTime(t *testing.T) {
+	l, c := newTestLimiter(Limits{CPUSecondsPerHour: 10})
+
+	done, err := l.StartJob("grader")
+	require.NoError(t, err)
+	done(6 * time.Second)
+
+	c.t = c.t.Add(20 * time.Minute)
+	done, err = l.StartJob("grader")
+	require.NoError(t, err)
+	done(6 * time.Second)
+
+	// 12 seconds were taken; once the first 6 leave the window, 6 remain.
+	c.t = c.t.Add(10 * time.Minute)
+	_, err = l.StartJob("grader")
+	requireLimit(t, err, LIMIT_CPU_TIME, 30*time.Minute)
+
+	c.t = c.t.Add(30 * time.Minute)
+	_, err = l.StartJob("grader")
+	require.NoError(t, err)
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	require.NoError(t, l.Allow("grader"))
+	done, err := l.StartJob("grader")
+	require.NoError(t, err)
+	done(time.Hour)
+}
+
+func TestReportsCPUTime(t *testing.T) {
+	require.Equal(t, 7*time.Millisecond, CPUTime([]sandbox.Report{
+		{CPUTime: 1000, Interactor: &sandbox.Report{CPUTime: 2000}},
+		{CPUTime: 1000, Services: []sandbox.Report{{CPUTime: 3000}}},
+	}))
+}
+
+func TestErrorStatus(t *testing.T) {
+	err := &Error{Limit: LIMIT_REQUEST_RATE, RetryAfter: 1500 * time.Millisecond}
+	require.Equal(t, 2, err.RetryAfterSeconds())
+
+	st := status.Convert(err)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+	require.Equal(t, 1500*time.Millisecond, st.Details()[0].(*errdetails.RetryInfo).RetryDelay.AsDuration())
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _ := newTestLimiter(Limits{RequestsPerSecond: 1})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		func(w http.ResponseWriter, r *http.Request, msg string, status int) {
+			http.Error(w, msg, status)
+		})
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Name: "grader"})
+	for _, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/images", nil).WithContext(ctx))
+		require.Equal(t, want, w.Code)
+		if want == http.StatusTooManyRequests {
+			require.Equal(t, "1", w.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -63,3 +63,11 @@ func (a *Allocator) Free(i int) int {
 
 	return 0
 }
+
+// InUse returns the number of ranges allocated and not freed yet.
+func (a *Allocator) InUse() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return len(a.used)
+}
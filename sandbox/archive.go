@@ -0,0 +1,214 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+type ArchiveFormat string
+
+const (
+	ARCHIVE_TAR ArchiveFormat = "tar"
+	ARCHIVE_ZIP ArchiveFormat = "zip"
+)
+
+// MaxExtractedSize bounds the total number of bytes a single archive may
+// expand to, protecting the host from decompression bombs.
+var MaxExtractedSize int64 = 512 * 1024 * 1024
+
+var errArchiveTooLarge = errors.New("archive exceeds maximum extracted size")
+
+// extractArchive unpacks content into dir. Every entry is resolved with
+// securejoin so that absolute paths, ".." components and symlinks can never
+// place anything outside dir. Only permission bits are kept from the
+// archive; setuid, setgid and sticky bits are dropped.
+func extractArchive(format ArchiveFormat, content []byte, dir string) error {
+	if err := os.MkdirAll(dir, defaultDirMode); err != nil {
+		return err
+	}
+
+	switch format {
+	case ARCHIVE_TAR:
+		return extractTar(content, dir)
+	case ARCHIVE_ZIP:
+		return extractZip(content, dir)
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+func extractTar(content []byte, dir string) error {
+	var r io.Reader = bytes.NewReader(content)
+
+	// gzip-compressed tarballs are detected by their magic number.
+	if len(content) > 2 && content[0] == 0x1f && content[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	budget := MaxExtractedSize
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := extractDir(dir, hdr.Name, mode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractRegular(dir, hdr.Name, mode, tr, &budget); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := extractSymlink(dir, hdr.Name, hdr.Linkname); err != nil {
+				return err
+			}
+		default:
+			// Hard links, devices and fifos are never needed for job inputs.
+			return fmt.Errorf("unsupported tar entry %q", hdr.Name)
+		}
+	}
+}
+
+func extractZip(content []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	budget := MaxExtractedSize
+
+	for _, f := range zr.File {
+		info := f.FileInfo()
+		mode := info.Mode().Perm()
+
+		switch {
+		case info.IsDir():
+			if err := extractDir(dir, f.Name, mode); err != nil {
+				return err
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			target, err := io.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := extractSymlink(dir, f.Name, string(target)); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = extractRegular(dir, f.Name, mode, rc, &budget)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported zip entry %q", f.Name)
+		}
+	}
+
+	return nil
+}
+
+func extractDir(root, name string, mode os.FileMode) error {
+	path, err := securejoin.SecureJoin(root, name)
+	if err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = defaultDirMode
+	}
+	return makeDir(path, mode|0700)
+}
+
+func extractRegular(root, name string, mode os.FileMode, r io.Reader, budget *int64) error {
+	path, err := securejoin.SecureJoin(root, name)
+	if err != nil {
+		return err
+	}
+	if path == filepath.Clean(root) {
+		return fmt.Errorf("invalid archive entry %q", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), defaultDirMode); err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+
+	// Replace whatever is there, including a symlink planted by an earlier
+	// entry, instead of writing through it.
+	os.Remove(path)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(r, *budget+1))
+	if err != nil {
+		return err
+	}
+	*budget -= n
+	if *budget < 0 {
+		return errArchiveTooLarge
+	}
+
+	return os.Chmod(path, mode)
+}
+
+func extractSymlink(root, name, target string) error {
+	path, err := securejoin.SecureJoin(root, name)
+	if err != nil {
+		return err
+	}
+
+	// Links are resolved inside the container, where root is mounted at /box
+	// or below, so only relative targets that stay within root are allowed.
+	if filepath.IsAbs(target) {
+		return fmt.Errorf("symlink %q has absolute target", name)
+	}
+	rel, err := filepath.Rel(root, filepath.Join(filepath.Dir(path), target))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("symlink %q points outside the archive", name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), defaultDirMode); err != nil {
+		return err
+	}
+	os.Remove(path)
+	return os.Symlink(target, path)
+}
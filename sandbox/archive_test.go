@@ -0,0 +1,125 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type archiveEntry struct {
+	name     string
+	body     string
+	mode     int64
+	dir      bool
+	linkname string
+}
+
+func makeTar(t *testing.T, entries []archiveEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.dir:
+			hdr.Typeflag = tar.TypeDir
+		case e.linkname != "":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.linkname
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte(e.body))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestExtractTar(t *testing.T) {
+	dir := t.TempDir()
+
+	content := makeTar(t, []archiveEntry{
+		{name: "pkg/", dir: true, mode: 0755},
+		{name: "pkg/__init__.py", body: "", mode: 0644},
+		{name: "pkg/run.sh", body: "#!/bin/sh\n", mode: 04755},
+		{name: "pkg/link", linkname: "run.sh"},
+	})
+	require.NoError(t, extractArchive(ARCHIVE_TAR, content, dir))
+
+	info, err := os.Stat(filepath.Join(dir, "pkg", "run.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), info.Mode())
+
+	target, err := os.Readlink(filepath.Join(dir, "pkg", "link"))
+	require.NoError(t, err)
+	require.Equal(t, "run.sh", target)
+}
+
+func TestExtractTarStaysInside(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "box")
+
+	content := makeTar(t, []archiveEntry{
+		{name: "../escape.txt", body: "x", mode: 0644},
+		{name: "/abs.txt", body: "x", mode: 0644},
+	})
+	require.NoError(t, extractArchive(ARCHIVE_TAR, content, dir))
+
+	_, err := os.Stat(filepath.Join(root, "escape.txt"))
+	require.True(t, os.IsNotExist(err), "entry escaped the extraction directory")
+	_, err = os.Stat(filepath.Join(dir, "escape.txt"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "abs.txt"))
+	require.NoError(t, err)
+
+	for _, link := range []string{"../../etc", "/etc"} {
+		content = makeTar(t, []archiveEntry{{name: "evil", linkname: link}})
+		require.Error(t, extractArchive(ARCHIVE_TAR, content, dir), "symlink to %s", link)
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("src/main/java/App.java")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("class App {}"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	require.NoError(t, extractArchive(ARCHIVE_ZIP, buf.Bytes(), dir))
+
+	got, err := os.ReadFile(filepath.Join(dir, "src", "main", "java", "App.java"))
+	require.NoError(t, err)
+	require.Equal(t, "class App {}", string(got))
+}
+
+func TestExtractSizeLimit(t *testing.T) {
+	limit := MaxExtractedSize
+	MaxExtractedSize = 4
+	defer func() { MaxExtractedSize = limit }()
+
+	content := makeTar(t, []archiveEntry{{name: "big", body: "0123456789", mode: 0644}})
+	require.ErrorIs(t, extractArchive(ARCHIVE_TAR, content, t.TempDir()), errArchiveTooLarge)
+}
+
+func TestCopyFileKeepsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	require.NoError(t, os.Symlink("/etc/shadow", src))
+
+	require.NoError(t, copyFile(src, dst, 0))
+
+	target, err := os.Readlink(dst)
+	require.NoError(t, err)
+	require.Equal(t, "/etc/shadow", target)
+}
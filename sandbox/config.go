@@ -1,23 +1,91 @@
 package sandbox
 
+import "os"
+
 type Config struct {
 	RootfsImageDir string
+	// RootfsLayers are read-only layers stacked below RootfsImageDir,
+	// bottom first.
+	RootfsLayers []string
+	// UpperDir keeps the changes to the root filesystem there instead of in
+	// a directory of the sandbox's own, so that they outlive the sandbox. It
+	// must be on the same filesystem as config.OverlayFSDir.
+	UpperDir string
 
 	Args  []string
 	Stdin string
 	Cwd   string
 	Env   []string
 
+	// UID and GID are the user and group the process runs as inside the
+	// sandbox's user namespace.
+	UID uint32
+	GID uint32
+
+	// StdinFile, StdoutFile and StderrFile connect the standard streams to
+	// files in BoxDir instead of in-memory buffers. Paths are relative to
+	// BoxDir. StdinFile takes precedence over Stdin.
+	StdinFile  string
+	StdoutFile string
+	StderrFile string
+
+	// StdinPipe, StdoutPipe and StderrPipe connect the process directly to
+	// the given files, typically pipe ends shared with another sandbox or
+	// with a session. They take precedence over the settings above. The
+	// sandbox takes ownership and closes them as soon as the process has
+	// started.
+	StdinPipe  *os.File
+	StdoutPipe *os.File
+	StderrPipe *os.File
+
+	// Output, when set, is handed what the process writes to stdout and
+	// stderr while it runs. Only streams captured for the report are
+	// streamed, not those connected to files or pipes.
+	Output *OutputStream
+
+	// MaxOutputSize bounds, in bytes, each of the captured stdout and
+	// stderr, both in the report and in Output. The rest is dropped and the
+	// report marked as truncated. Zero means no limit.
+	MaxOutputSize int64
+
+	// Terminal runs the process on a pseudo-terminal instead of the streams
+	// above. The sandbox sends the terminal's master over ConsoleSocket,
+	// which it takes ownership of like the pipes.
+	Terminal      *Terminal
+	ConsoleSocket *os.File
+
 	UserNamespace *UserNamespaceConfig
 
+	// NetNSPath and IPCNSPath join existing namespaces, such as those of a
+	// Pod, instead of creating private ones.
+	NetNSPath string
+	IPCNSPath string
+
+	// HostNetwork runs the process in the host's network namespace, with
+	// the host's DNS configuration. RootOwnsImage maps root in the sandbox
+	// to the unprivileged owner of the image's files, image.Owner, so that
+	// the process can change them. Both are only meant for image builds.
+	HostNetwork   bool
+	RootOwnsImage bool
+
 	TimeLimitMs int64
 	Cgroup      *CgroupConfig
 	Rlimit      *RlimitConfig
 
+	// WallTimeLimitMs bounds the real time of a run. Zero means three times
+	// TimeLimitMs.
+	WallTimeLimitMs int64
+
 	BoxDir string
 	Files  []File
 }
 
+// Terminal is the size of a pseudo-terminal, in characters.
+type Terminal struct {
+	Width  uint16
+	Height uint16
+}
+
 type UserNamespaceConfig struct {
 	HostUID      uint32
 	HostGID      uint32
@@ -49,6 +117,14 @@ type Rlimit struct {
 
 type File struct {
 	Src     string
-	Content string
+	Content []byte
 	Dst     string
+
+	// Mode sets the permission bits of Dst. Zero keeps the mode of Src, or
+	// falls back to 0744 for files and 0755 for directories.
+	Mode os.FileMode
+	// Dir creates Dst as a directory instead of a file.
+	Dir bool
+	// Archive extracts Content (or Src) into the directory Dst.
+	Archive ArchiveFormat
 }
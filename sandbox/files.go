@@ -1,24 +1,54 @@
 package sandbox
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+const (
+	defaultFileMode os.FileMode = 0744
+	defaultDirMode  os.FileMode = 0755
 )
 
 func (s *Sandbox) prepareFiles() error {
 	for _, file := range s.config.Files {
-		if err := os.MkdirAll(filepath.Dir(file.Dst), 0755); err != nil {
+		dst, err := boxPath(s.config.BoxDir, file.Dst)
+		if err != nil {
 			return err
 		}
+		file.Dst = dst
 
-		if file.Src != "" {
-			err := copyFile(file.Src, file.Dst)
-			if err != nil {
+		if err := os.MkdirAll(filepath.Dir(file.Dst), defaultDirMode); err != nil {
+			return err
+		}
+		// A link already at Dst, such as one persisted by an earlier step, is
+		// replaced rather than followed.
+		if info, err := os.Lstat(file.Dst); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(file.Dst); err != nil {
 				return err
 			}
-		} else {
-			err := writeFile(file.Content, file.Dst)
-			if err != nil {
+		}
+
+		switch {
+		case file.Dir:
+			if err := makeDir(file.Dst, file.Mode); err != nil {
+				return err
+			}
+		case file.Archive != "":
+			if err := extractFile(file); err != nil {
+				return fmt.Errorf("error extracting %s: %w", filepath.Base(file.Dst), err)
+			}
+		case file.Src != "":
+			if err := copyFile(file.Src, file.Dst, file.Mode); err != nil {
+				return err
+			}
+		default:
+			if err := writeFile(file.Content, file.Dst, file.Mode); err != nil {
 				return err
 			}
 		}
@@ -27,14 +57,99 @@ func (s *Sandbox) prepareFiles() error {
 	return nil
 }
 
-func copyFile(src, dst string) error {
-	input, err := os.ReadFile(src)
+// boxPath resolves the directories leading to dst, which must be inside
+// boxDir, as if boxDir were the root, the way openBoxFile does. A link
+// persisted by an earlier step can then never make the host write outside of
+// the box.
+func boxPath(boxDir, dst string) (string, error) {
+	rel, err := filepath.Rel(boxDir, dst)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s is not inside the box", dst)
+	}
+
+	dir, err := securejoin.SecureJoin(boxDir, filepath.Dir(rel))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(rel)), nil
+}
+
+func extractFile(file File) error {
+	if file.Src == "" {
+		return extractArchive(file.Archive, file.Content, file.Dst)
+	}
+
+	content, err := os.ReadFile(file.Src)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(dst, input, 0744)
+	return extractArchive(file.Archive, content, file.Dst)
 }
 
-func writeFile(content, dst string) error {
-	return os.WriteFile(dst, []byte(content), 0744)
+// copyFile copies src to dst without following symlinks, so that a link
+// produced inside a box can never be used to read files from the host.
+// A zero mode keeps the permissions of src.
+func copyFile(src, dst string, mode os.FileMode) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		os.Remove(dst)
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if mode == 0 {
+			mode = info.Mode().Perm()
+		}
+		return makeDir(dst, mode)
+	case !info.Mode().IsRegular():
+		return fmt.Errorf("cannot copy %s: not a regular file", filepath.Base(src))
+	}
+
+	if mode == 0 {
+		mode = info.Mode().Perm()
+	}
+
+	input, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	if _, err := io.Copy(output, input); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+func writeFile(content []byte, dst string, mode os.FileMode) error {
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+	if err := os.WriteFile(dst, content, mode); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+func makeDir(dst string, mode os.FileMode) error {
+	if mode == 0 {
+		mode = defaultDirMode
+	}
+	if err := os.MkdirAll(dst, mode); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
 }
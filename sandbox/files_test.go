@@ -0,0 +1,58 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareFilesStaysInside(t *testing.T) {
+	root := t.TempDir()
+	boxDir := filepath.Join(root, "box")
+	outside := filepath.Join(root, "outside")
+	require.NoError(t, os.MkdirAll(boxDir, 0755))
+	require.NoError(t, os.MkdirAll(outside, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "passwd"), []byte("root"), 0644))
+
+	// Links such as earlier steps may persist.
+	require.NoError(t, os.Symlink(outside, filepath.Join(boxDir, "out")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "passwd"), filepath.Join(boxDir, "passwd")))
+
+	s := &Sandbox{config: &Config{
+		BoxDir: boxDir,
+		Files: []File{
+			{Content: []byte("pwned"), Dst: filepath.Join(boxDir, "out", "passwd")},
+			{Dir: true, Dst: filepath.Join(boxDir, "out", "dir")},
+			{Content: []byte("pwned"), Dst: filepath.Join(boxDir, "passwd")},
+		},
+	}}
+	require.NoError(t, s.prepareFiles())
+
+	entries, err := os.ReadDir(outside)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	content, err := os.ReadFile(filepath.Join(outside, "passwd"))
+	require.NoError(t, err)
+	require.Equal(t, "root", string(content))
+
+	// The link is resolved inside the box instead.
+	content, err = os.ReadFile(filepath.Join(boxDir, outside, "passwd"))
+	require.NoError(t, err)
+	require.Equal(t, "pwned", string(content))
+	require.DirExists(t, filepath.Join(boxDir, outside, "dir"))
+
+	info, err := os.Lstat(filepath.Join(boxDir, "passwd"))
+	require.NoError(t, err)
+	require.True(t, info.Mode().IsRegular())
+}
+
+func TestPrepareFilesOutsideBox(t *testing.T) {
+	boxDir := t.TempDir()
+	s := &Sandbox{config: &Config{
+		BoxDir: boxDir,
+		Files:  []File{{Content: []byte("pwned"), Dst: filepath.Join(boxDir, "..", "passwd")}},
+	}}
+	require.Error(t, s.prepareFiles())
+}
@@ -0,0 +1,155 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type Verdict string
+
+const (
+	VERDICT_ACCEPTED              Verdict = "ACCEPTED"
+	VERDICT_WRONG_ANSWER          Verdict = "WRONG_ANSWER"
+	VERDICT_PRESENTATION_ERROR    Verdict = "PRESENTATION_ERROR"
+	VERDICT_TIME_LIMIT_EXCEEDED   Verdict = "TIME_LIMIT_EXCEEDED"
+	VERDICT_MEMORY_LIMIT_EXCEEDED Verdict = "MEMORY_LIMIT_EXCEEDED"
+	VERDICT_OUTPUT_LIMIT_EXCEEDED Verdict = "OUTPUT_LIMIT_EXCEEDED"
+	VERDICT_RUNTIME_ERROR         Verdict = "RUNTIME_ERROR"
+	VERDICT_JUDGE_ERROR           Verdict = "JUDGE_ERROR"
+)
+
+// Interactor exit codes, following the testlib convention.
+const (
+	interactorExitOK   = 0
+	interactorExitWA   = 1
+	interactorExitPE   = 2
+	interactorExitFail = 3
+)
+
+// RunInteractive runs two existing sandboxes at the same time with the
+// standard output of each one connected to the standard input of the other.
+// The returned report belongs to the solution; the interactor's report and
+// the verdict derived from both are attached to it.
+//
+// If either side exits early, the other one sees EOF on its stdin (or EPIPE
+// on its stdout) and is still bounded by its own limits. If a sandbox fails
+// to start, the other one is cancelled.
+func (m *Manager) RunInteractive(ctx context.Context, solutionId, interactorId string) (Report, error) {
+	if m.maxConcurrency < 2 {
+		return Report{}, fmt.Errorf("interactive runs need a concurrency of at least 2")
+	}
+
+	m.acquire(2)
+	defer m.release(2)
+
+	m.mu.Lock()
+	solution, solutionExists := m.sandboxes[solutionId]
+	interactor, interactorExists := m.sandboxes[interactorId]
+	m.mu.Unlock()
+
+	if !solutionExists {
+		return Report{}, fmt.Errorf("sandbox with id %q does not exist", solutionId)
+	}
+	if !interactorExists {
+		return Report{}, fmt.Errorf("sandbox with id %q does not exist", interactorId)
+	}
+
+	toInteractorR, toInteractorW, err := os.Pipe()
+	if err != nil {
+		return Report{}, fmt.Errorf("error creating pipe: %w", err)
+	}
+	toSolutionR, toSolutionW, err := os.Pipe()
+	if err != nil {
+		toInteractorR.Close()
+		toInteractorW.Close()
+		return Report{}, fmt.Errorf("error creating pipe: %w", err)
+	}
+
+	solution.config.StdoutPipe = toInteractorW
+	solution.config.StdinPipe = toSolutionR
+	interactor.config.StdoutPipe = toSolutionW
+	interactor.config.StdinPipe = toInteractorR
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg                          sync.WaitGroup
+		solutionReport, interReport Report
+		solutionErr, interErr       error
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		solutionReport, solutionErr = solution.Run(ctx)
+		if solutionErr != nil {
+			cancel()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		interReport, interErr = interactor.Run(ctx)
+		if interErr != nil {
+			cancel()
+		}
+	}()
+	wg.Wait()
+
+	if solutionErr != nil {
+		return Report{}, fmt.Errorf("error running solution sandbox %q: %w", solutionId, solutionErr)
+	}
+	if interErr != nil {
+		return Report{}, fmt.Errorf("error running interactor sandbox %q: %w", interactorId, interErr)
+	}
+
+	solutionReport.Interactor = &interReport
+	solutionReport.Verdict = interactiveVerdict(solutionReport, interReport)
+
+	return solutionReport, nil
+}
+
+// interactiveVerdict combines both reports. Resource limit violations of the
+// solution take precedence, then the interactor's judgement, then a crash of
+// the solution. An interactor that itself fails or breaks its limits is a
+// judge error.
+func interactiveVerdict(solution, interactor Report) Verdict {
+	switch solution.Status {
+	case STATUS_TIME_LIMIT_EXCEEDED:
+		return VERDICT_TIME_LIMIT_EXCEEDED
+	case STATUS_MEMORY_LIMIT_EXCEEDED:
+		return VERDICT_MEMORY_LIMIT_EXCEEDED
+	case STATUS_OUTPUT_LIMIT_EXCEEDED:
+		return VERDICT_OUTPUT_LIMIT_EXCEEDED
+	}
+
+	switch interactor.Status {
+	case STATUS_OK, STATUS_RUNTIME_ERROR:
+	default:
+		return VERDICT_JUDGE_ERROR
+	}
+
+	switch interactor.ExitCode {
+	case interactorExitOK:
+	case interactorExitWA:
+		return VERDICT_WRONG_ANSWER
+	case interactorExitPE:
+		return VERDICT_PRESENTATION_ERROR
+	case interactorExitFail:
+		return VERDICT_JUDGE_ERROR
+	default:
+		// A negative exit code means the interactor was killed by a signal.
+		if interactor.ExitCode < 0 {
+			return VERDICT_JUDGE_ERROR
+		}
+		return VERDICT_WRONG_ANSWER
+	}
+
+	if solution.Status != STATUS_OK {
+		return VERDICT_RUNTIME_ERROR
+	}
+
+	return VERDICT_ACCEPTED
+}
@@ -0,0 +1,32 @@
+package sandbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInteractiveVerdict(t *testing.T) {
+	cases := []struct {
+		solution   Report
+		interactor Report
+		want       Verdict
+	}{
+		{Report{Status: STATUS_OK}, Report{Status: STATUS_OK}, VERDICT_ACCEPTED},
+		{Report{Status: STATUS_OK}, Report{Status: STATUS_RUNTIME_ERROR, ExitCode: 1}, VERDICT_WRONG_ANSWER},
+		{Report{Status: STATUS_OK}, Report{Status: STATUS_RUNTIME_ERROR, ExitCode: 2}, VERDICT_PRESENTATION_ERROR},
+		{Report{Status: STATUS_OK}, Report{Status: STATUS_RUNTIME_ERROR, ExitCode: 3}, VERDICT_JUDGE_ERROR},
+		{Report{Status: STATUS_OK}, Report{Status: STATUS_RUNTIME_ERROR, ExitCode: -1}, VERDICT_JUDGE_ERROR},
+		{Report{Status: STATUS_OK}, Report{Status: STATUS_TIME_LIMIT_EXCEEDED}, VERDICT_JUDGE_ERROR},
+		{Report{Status: STATUS_TIME_LIMIT_EXCEEDED}, Report{Status: STATUS_RUNTIME_ERROR, ExitCode: 1}, VERDICT_TIME_LIMIT_EXCEEDED},
+		{Report{Status: STATUS_MEMORY_LIMIT_EXCEEDED}, Report{Status: STATUS_OK}, VERDICT_MEMORY_LIMIT_EXCEEDED},
+		// The solution crashed, and the interactor noticed the early EOF.
+		{Report{Status: STATUS_RUNTIME_ERROR, ExitCode: 139}, Report{Status: STATUS_RUNTIME_ERROR, ExitCode: 1}, VERDICT_WRONG_ANSWER},
+		// The solution crashed after the interactor had accepted.
+		{Report{Status: STATUS_RUNTIME_ERROR, ExitCode: 1}, Report{Status: STATUS_OK}, VERDICT_RUNTIME_ERROR},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.want, interactiveVerdict(tc.solution, tc.interactor), "solution %+v, interactor %+v", tc.solution, tc.interactor)
+	}
+}
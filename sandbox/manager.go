@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/joshjms/castletown/metrics"
 	"github.com/joshjms/castletown/sandbox/allocator"
+	"github.com/joshjms/castletown/tracing"
 )
 
 var m *Manager
@@ -13,12 +16,14 @@ var m *Manager
 type Manager struct {
 	sandboxes       map[string]*Sandbox
 	allocatedRanges map[string]int
+	pods            map[string]*Pod
 
 	allocator      *allocator.Allocator
 	maxConcurrency int
 
-	mu  sync.Mutex
-	sem chan struct{}
+	mu      sync.Mutex
+	sem     chan struct{}
+	groupMu sync.Mutex
 }
 
 func NewManager(maxConcurrency int) error {
@@ -27,6 +32,7 @@ func NewManager(maxConcurrency int) error {
 	m = &Manager{
 		sandboxes:       make(map[string]*Sandbox),
 		allocatedRanges: make(map[string]int),
+		pods:            make(map[string]*Pod),
 		allocator:       alloc,
 		maxConcurrency:  maxConcurrency,
 		sem:             make(chan struct{}, maxConcurrency),
@@ -67,13 +73,14 @@ func (m *Manager) NewSandbox(id string, cfg *Config) error {
 
 	m.sandboxes[id] = sandbox
 	m.allocatedRanges[id] = idx
+	metrics.AllocatorRangesInUse.Set(float64(m.allocator.InUse()))
 
 	return nil
 }
 
 func (m *Manager) RunSandbox(ctx context.Context, id string) (Report, error) {
-	m.sem <- struct{}{}
-	defer func() { <-m.sem }()
+	m.acquire(1)
+	defer m.release(1)
 
 	m.mu.Lock()
 	sandbox, exists := m.sandboxes[id]
@@ -91,10 +98,65 @@ func (m *Manager) RunSandbox(ctx context.Context, id string) (Report, error) {
 	return report, nil
 }
 
-func (m *Manager) DestroySandbox(id string) error {
+// acquire takes n concurrency slots for sandboxes that must run at the same
+// time. Groups take their slots under a lock so that two groups can never
+// each hold part of what they need while waiting for the rest; a single
+// slot cannot be held partly, so single sandboxes need not wait for groups.
+func (m *Manager) acquire(n int) {
+	queuedAt := time.Now()
+	metrics.QueueDepth.Add(float64(n))
+
+	if n > 1 {
+		m.groupMu.Lock()
+		defer m.groupMu.Unlock()
+	}
+	for i := 0; i < n; i++ {
+		m.sem <- struct{}{}
+	}
+
+	m.acquired(n, queuedAt)
+}
+
+// acquireContext takes one concurrency slot like acquire, unless ctx is done
+// first.
+func (m *Manager) acquireContext(ctx context.Context) error {
+	queuedAt := time.Now()
+	metrics.QueueDepth.Inc()
+
+	select {
+	case m.sem <- struct{}{}:
+	case <-ctx.Done():
+		metrics.QueueDepth.Dec()
+		return ctx.Err()
+	}
+
+	m.acquired(1, queuedAt)
+	return nil
+}
+
+func (m *Manager) acquired(n int, queuedAt time.Time) {
+	metrics.QueueDepth.Sub(float64(n))
+	metrics.QueueWait.Observe(time.Since(queuedAt).Seconds())
+	metrics.SandboxesRunning.Add(float64(n))
+}
+
+func (m *Manager) release(n int) {
+	for i := 0; i < n; i++ {
+		<-m.sem
+	}
+	metrics.SandboxesRunning.Sub(float64(n))
+}
+
+func (m *Manager) DestroySandbox(ctx context.Context, id string) (err error) {
+	_, span := tracing.Start(ctx, "sandbox.Destroy", tracing.KEY_SANDBOX_ID.String(id))
+	defer func() { tracing.End(span, err) }()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	start := time.Now()
+	defer func() { metrics.TeardownDuration.Observe(time.Since(start).Seconds()) }()
+
 	sandbox, exists := m.sandboxes[id]
 	if !exists {
 		return fmt.Errorf("sandbox with id %q does not exist", id)
@@ -115,5 +177,33 @@ func (m *Manager) DestroySandbox(id string) error {
 
 	delete(m.sandboxes, id)
 	delete(m.allocatedRanges, id)
+	metrics.AllocatorRangesInUse.Set(float64(m.allocator.InUse()))
 	return nil
 }
+
+// RootfsInUse tells whether any sandbox that has not been destroyed yet runs
+// on the image in dir.
+func (m *Manager) RootfsInUse(dir string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sandbox := range m.sandboxes {
+		if sandbox.config.RootfsImageDir == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// SandboxIDs returns the IDs of the sandboxes that have not been destroyed
+// yet.
+func (m *Manager) SandboxIDs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.sandboxes))
+	for id := range m.sandboxes {
+		ids = append(ids, id)
+	}
+	return ids
+}
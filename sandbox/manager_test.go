@@ -0,0 +1,30 @@
+package sandbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joshjms/castletown/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerSlotMetrics(t *testing.T) {
+	require.NoError(t, NewManager(2))
+	m := GetManager()
+
+	m.acquire(2)
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.SandboxesRunning))
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.QueueDepth))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, m.acquireContext(ctx), context.Canceled)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.QueueDepth))
+
+	m.release(2)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.SandboxesRunning))
+
+	require.NoError(t, m.NewSandbox("metrics-0", &Config{}))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.AllocatorRangesInUse))
+}
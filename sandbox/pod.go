@@ -0,0 +1,341 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+	"golang.org/x/sys/unix"
+)
+
+// Pod is a network and IPC namespace shared by several sandboxes. The
+// network namespace only has a loopback interface, so members can reach
+// each other on 127.0.0.1 but nothing else.
+type Pod struct {
+	id  string
+	dir string
+}
+
+// Readiness describes how to tell that a pod service is ready to accept
+// requests.
+type Readiness struct {
+	// TCPPort is polled on 127.0.0.1 inside the pod until a connection
+	// succeeds.
+	TCPPort int
+	// TimeoutMs bounds the wait. Zero uses defaultReadinessTimeout.
+	TimeoutMs int64
+}
+
+// PodService is a sandbox started before the main sandbox of a pod and
+// stopped after it.
+type PodService struct {
+	ID        string
+	Readiness *Readiness
+}
+
+const (
+	defaultReadinessTimeout  = 10 * time.Second
+	readinessPollInterval    = 50 * time.Millisecond
+	unprivilegedPortStartCtl = "/proc/sys/net/ipv4/ip_unprivileged_port_start"
+)
+
+func (p *Pod) NetNSPath() string {
+	return filepath.Join(p.dir, "net")
+}
+
+func (p *Pod) IPCNSPath() string {
+	return filepath.Join(p.dir, "ipc")
+}
+
+// NewPod creates the namespaces of a pod and pins them with bind mounts so
+// that they outlive the sandboxes joining them.
+func (m *Manager) NewPod(id string) (*Pod, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.pods[id]; exists {
+		return nil, fmt.Errorf("pod with id %q already exists", id)
+	}
+
+	pod := &Pod{
+		id:  id,
+		dir: filepath.Join(config.PodsDir, fmt.Sprintf("pod-%s", id)),
+	}
+
+	if err := pod.create(); err != nil {
+		pod.destroy()
+		return nil, fmt.Errorf("error creating pod namespaces: %w", err)
+	}
+
+	m.pods[id] = pod
+	return pod, nil
+}
+
+// DestroyPod releases the namespaces of a pod. Its sandboxes must already
+// have finished.
+func (m *Manager) DestroyPod(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pod, exists := m.pods[id]
+	if !exists {
+		return fmt.Errorf("pod with id %q does not exist", id)
+	}
+
+	if err := pod.destroy(); err != nil {
+		return fmt.Errorf("error destroying pod: %w", err)
+	}
+
+	delete(m.pods, id)
+	return nil
+}
+
+func (p *Pod) create() error {
+	if err := os.MkdirAll(p.dir, 0700); err != nil {
+		return err
+	}
+	for _, path := range []string{p.NetNSPath(), p.IPCNSPath()} {
+		f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		// The thread is left locked on purpose: it ends up in the new
+		// namespaces and is discarded when this goroutine exits instead of
+		// going back to the scheduler.
+		runtime.LockOSThread()
+
+		if err := unix.Unshare(unix.CLONE_NEWNET | unix.CLONE_NEWIPC); err != nil {
+			errCh <- fmt.Errorf("unshare: %w", err)
+			return
+		}
+
+		tid := unix.Gettid()
+		for ns, path := range map[string]string{"net": p.NetNSPath(), "ipc": p.IPCNSPath()} {
+			src := fmt.Sprintf("/proc/self/task/%d/ns/%s", tid, ns)
+			if err := unix.Mount(src, path, "", unix.MS_BIND, ""); err != nil {
+				errCh <- fmt.Errorf("bind mount %s namespace: %w", ns, err)
+				return
+			}
+		}
+
+		if err := setLinkUp("lo"); err != nil {
+			errCh <- fmt.Errorf("bring up loopback: %w", err)
+			return
+		}
+
+		// Members live in their own user namespaces, which do not own this
+		// network namespace, so they could not bind ports below 1024.
+		if err := os.WriteFile(unprivilegedPortStartCtl, []byte("0"), 0644); err != nil {
+			errCh <- fmt.Errorf("allow unprivileged ports: %w", err)
+			return
+		}
+
+		errCh <- nil
+	}()
+
+	return <-errCh
+}
+
+func (p *Pod) destroy() error {
+	for _, path := range []string{p.NetNSPath(), p.IPCNSPath()} {
+		if err := unix.Unmount(path, unix.MNT_DETACH); err != nil && err != unix.EINVAL && err != unix.ENOENT {
+			return fmt.Errorf("unmount %s: %w", filepath.Base(path), err)
+		}
+	}
+	return os.RemoveAll(p.dir)
+}
+
+func setLinkUp(name string) error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq(name)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// RunPod starts the services of a pod, waits until each of them is ready and
+// then runs the main sandbox. Services are stopped once the main sandbox
+// finishes and their reports are attached to its report. If a service never
+// becomes ready, the main sandbox is skipped.
+func (m *Manager) RunPod(ctx context.Context, podId, mainId string, services []PodService) (Report, error) {
+	if m.maxConcurrency < len(services)+1 {
+		return Report{}, fmt.Errorf("pod needs a concurrency of at least %d", len(services)+1)
+	}
+
+	m.acquire(len(services) + 1)
+	defer m.release(len(services) + 1)
+
+	m.mu.Lock()
+	pod, podExists := m.pods[podId]
+	main, mainExists := m.sandboxes[mainId]
+	members := make([]*Sandbox, len(services))
+	for i, svc := range services {
+		members[i] = m.sandboxes[svc.ID]
+	}
+	m.mu.Unlock()
+
+	if !podExists {
+		return Report{}, fmt.Errorf("pod with id %q does not exist", podId)
+	}
+	if !mainExists {
+		return Report{}, fmt.Errorf("sandbox with id %q does not exist", mainId)
+	}
+	for i, member := range members {
+		if member == nil {
+			return Report{}, fmt.Errorf("sandbox with id %q does not exist", services[i].ID)
+		}
+	}
+
+	svcCtx, stopServices := context.WithCancel(ctx)
+	defer stopServices()
+
+	var (
+		wg          sync.WaitGroup
+		svcReports  = make([]Report, len(services))
+		svcErrs     = make([]error, len(services))
+		svcFinished = make([]chan struct{}, len(services))
+	)
+
+	for i, member := range members {
+		svcFinished[i] = make(chan struct{})
+		wg.Add(1)
+		go func(i int, member *Sandbox) {
+			defer wg.Done()
+			defer close(svcFinished[i])
+			svcReports[i], svcErrs[i] = member.Run(svcCtx)
+		}(i, member)
+	}
+
+	ready := true
+	for i, svc := range services {
+		if svc.Readiness == nil {
+			continue
+		}
+		if err := waitReady(svcCtx, pod, svc.Readiness, svcFinished[i]); err != nil {
+			ready = false
+			break
+		}
+	}
+
+	var (
+		report  Report
+		mainErr error
+	)
+	if ready {
+		report, mainErr = main.Run(ctx)
+	} else {
+		report = Report{Status: STATUS_SKIPPED}
+	}
+
+	stopServices()
+	wg.Wait()
+
+	if mainErr != nil {
+		return Report{}, fmt.Errorf("error running sandbox %q: %w", mainId, mainErr)
+	}
+	for i, err := range svcErrs {
+		if err != nil {
+			return Report{}, fmt.Errorf("error running service sandbox %q: %w", services[i].ID, err)
+		}
+	}
+
+	report.Services = svcReports
+	return report, nil
+}
+
+// waitReady polls the readiness check of a service until it passes, the
+// service exits or the timeout expires.
+func waitReady(ctx context.Context, pod *Pod, r *Readiness, finished <-chan struct{}) error {
+	timeout := defaultReadinessTimeout
+	if r.TimeoutMs > 0 {
+		timeout = time.Duration(r.TimeoutMs) * time.Millisecond
+	}
+	deadline := time.After(timeout)
+
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := pod.dialTCP(r.TCPPort); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-finished:
+			return fmt.Errorf("service exited before becoming ready")
+		case <-deadline:
+			return fmt.Errorf("service not ready after %v", timeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// dialTCP connects to port on the pod's loopback interface from a thread
+// that temporarily joins the pod's network namespace.
+func (p *Pod) dialTCP(port int) error {
+	target, err := os.Open(p.NetNSPath())
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		runtime.LockOSThread()
+
+		origin, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errCh <- err
+			return
+		}
+		defer origin.Close()
+
+		if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			errCh <- err
+			return
+		}
+
+		// The socket belongs to the namespace it was created in, so the
+		// thread can switch back before waiting for the connection.
+		conn, dialErr := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), readinessPollInterval)
+		if conn != nil {
+			conn.Close()
+		}
+
+		// Only hand the thread back to the scheduler if it is back home.
+		if err := unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET); err == nil {
+			runtime.UnlockOSThread()
+		}
+		errCh <- dialErr
+	}()
+
+	return <-errCh
+}
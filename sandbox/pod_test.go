@@ -0,0 +1,33 @@
+package sandbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodMounts(t *testing.T) {
+	s := &Sandbox{id: "pod-member", config: &Config{
+		BoxDir:        t.TempDir(),
+		UserNamespace: &UserNamespaceConfig{UIDMapCount: 1, GIDMapCount: 1},
+		NetNSPath:     "/run/pod/net",
+		IPCNSPath:     "/run/pod/ipc",
+	}}
+
+	for _, mount := range s.getMounts() {
+		require.NotEqual(t, "mqueue", mount.Type, "mqueue cannot be mounted in a shared IPC namespace")
+		if mount.Destination == "/sys" {
+			require.Equal(t, "bind", mount.Type)
+			require.Contains(t, mount.Options, "ro")
+		}
+	}
+
+	s.config.NetNSPath, s.config.IPCNSPath = "", ""
+
+	var types []string
+	for _, mount := range s.getMounts() {
+		types = append(types, mount.Type)
+	}
+	require.Contains(t, types, "mqueue")
+	require.Contains(t, types, "sysfs")
+}
@@ -25,14 +25,40 @@ type Report struct {
 	Status   Status
 	ExitCode int
 	Signal   syscall.Signal
-	Stdout   string
-	Stderr   string
+	Stdout   []byte
+	Stderr   []byte
 	CPUTime  uint64
 	Memory   uint64
 	WallTime int64
 
 	StartAt  time.Time
 	FinishAt time.Time
+
+	// StdoutTruncated and StderrTruncated are set when the stream went past
+	// the sandbox's MaxOutputSize and its end is missing from the report.
+	StdoutTruncated bool
+	StderrTruncated bool
+
+	Artifacts []Artifact
+
+	// Interactor and Verdict are only set for interactive runs, where this
+	// report belongs to the solution.
+	Interactor *Report
+	Verdict    Verdict
+
+	// Services holds the reports of the pod services that ran next to this
+	// process, in the order they were given.
+	Services []Report
+}
+
+// Artifact is a file collected from the box after a process finished.
+// Content is left empty and Omitted is set when the file does not fit in
+// the inline size limits; it can still be downloaded separately.
+type Artifact struct {
+	Path    string
+	Content []byte
+	Size    int64
+	Omitted bool
 }
 
 func (r Report) String() string {
@@ -49,13 +75,16 @@ func (r Report) String() string {
 	return fmt.Sprintf("status: %s\nexit code: %d\nsignal: %d\nstdout: %s\nstderr:%s\ncpu:%d usec\nmemory:%d bytes\n", r.Status, r.ExitCode, r.Signal, stdoutTrim, stderrTrim, r.CPUTime, r.Memory)
 }
 
-func (s *Sandbox) makeReport(stdoutBuf, stderrBuf io.Reader, state *os.ProcessState, timeLimitExceeded bool, startAt, finishAt time.Time) (Report, error) {
-	stdout, err := io.ReadAll(stdoutBuf)
+// makeReport builds the report of a finished process. killStatus is set when
+// the process was killed by the sandbox itself, either for exceeding its wall
+// time limit or because the run was cancelled.
+func (s *Sandbox) makeReport(stdio *stdio, state *os.ProcessState, killStatus Status, startAt, finishAt time.Time) (Report, error) {
+	stdout, err := io.ReadAll(stdio.Stdout())
 	if err != nil {
 		return Report{}, fmt.Errorf("error reading stdout: %w", err)
 	}
 
-	stderr, err := io.ReadAll(stderrBuf)
+	stderr, err := io.ReadAll(stdio.Stderr())
 	if err != nil {
 		return Report{}, fmt.Errorf("error reading stderr: %w", err)
 	}
@@ -73,25 +102,34 @@ func (s *Sandbox) makeReport(stdoutBuf, stderrBuf io.Reader, state *os.ProcessSt
 	var status Status
 
 	switch {
-	case timeLimitExceeded || stats.GetCPU().GetUsageUsec() > uint64(s.config.TimeLimitMs)*1000:
+	case killStatus == STATUS_TIME_LIMIT_EXCEEDED || stats.GetCPU().GetUsageUsec() > uint64(s.config.TimeLimitMs)*1000:
 		status = STATUS_TIME_LIMIT_EXCEEDED
 	case stats.GetMemory().GetMaxUsage() > uint64(s.config.Cgroup.Memory):
 		status = STATUS_MEMORY_LIMIT_EXCEEDED
+	case killStatus == STATUS_TERMINATED:
+		status = STATUS_TERMINATED
+	case state.Sys().(syscall.WaitStatus).Signal() == syscall.SIGXFSZ:
+		status = STATUS_OUTPUT_LIMIT_EXCEEDED
 	case state.ExitCode() != 0:
 		status = STATUS_RUNTIME_ERROR
 	default:
 		status = STATUS_OK
 	}
 
+	stdoutTruncated, stderrTruncated := stdio.Truncated()
+
 	return Report{
 		Status:   status,
 		ExitCode: state.ExitCode(),
 		Signal:   state.Sys().(syscall.WaitStatus).Signal(),
-		Stdout:   string(stdout),
-		Stderr:   string(stderr),
+		Stdout:   stdout,
+		Stderr:   stderr,
 		CPUTime:  stats.GetCPU().GetUsageUsec(),
 		Memory:   stats.GetMemory().GetMaxUsage(),
 		StartAt:  startAt,
 		FinishAt: finishAt,
+
+		StdoutTruncated: stdoutTruncated,
+		StderrTruncated: stderrTruncated,
 	}, nil
 }
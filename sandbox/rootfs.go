@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/image"
+	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
 func (s *Sandbox) prepareOverlayfs() error {
-	upperDir := filepath.Join(config.OverlayFSDir, fmt.Sprintf("sandbox-%s", s.id), "upper")
-	workDir := filepath.Join(config.OverlayFSDir, fmt.Sprintf("sandbox-%s", s.id), "work")
+	upperDir := s.getUpperDir()
+	workDir := s.getWorkDir()
 
 	if err := os.MkdirAll(upperDir, 0755); err != nil {
 		return err
@@ -19,17 +22,58 @@ func (s *Sandbox) prepareOverlayfs() error {
 		return err
 	}
 
+	// Root mounts the overlay, so it must be able to write to both.
+	if s.config.RootOwnsImage {
+		uid, gid := s.getRootIDs()
+		for _, dir := range []string{upperDir, workDir} {
+			if err := os.Chown(dir, int(uid), int(gid)); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
+// getLowerDir lists the image's directories for the lowerdir mount option,
+// which wants the topmost first.
 func (s *Sandbox) getLowerDir() string {
-	return s.config.RootfsImageDir
+	dirs := []string{s.config.RootfsImageDir}
+	for i := len(s.config.RootfsLayers) - 1; i >= 0; i-- {
+		dirs = append(dirs, s.config.RootfsLayers[i])
+	}
+	return strings.Join(dirs, ":")
 }
 
 func (s *Sandbox) getUpperDir() string {
+	if s.config.UpperDir != "" {
+		return s.config.UpperDir
+	}
 	return filepath.Join(config.OverlayFSDir, fmt.Sprintf("sandbox-%s", s.id), "upper")
 }
 
 func (s *Sandbox) getWorkDir() string {
 	return filepath.Join(config.OverlayFSDir, fmt.Sprintf("sandbox-%s", s.id), "work")
 }
+
+// getRootIDs returns the host user and group that root in the sandbox maps
+// to: the owner of images, who is not the host's root, or the first IDs of
+// the sandbox's range.
+func (s *Sandbox) getRootIDs() (uint32, uint32) {
+	if s.config.RootOwnsImage {
+		return image.Owner()
+	}
+	return s.config.UserNamespace.HostUID, s.config.UserNamespace.HostGID
+}
+
+// getIDMappings maps the sandbox's IDs onto its allocated range, except for
+// root when it owns the image.
+func getIDMappings(root, host, container, size uint32) []specs.LinuxIDMapping {
+	if root == host {
+		return []specs.LinuxIDMapping{{HostID: host, ContainerID: container, Size: size}}
+	}
+	return []specs.LinuxIDMapping{
+		{HostID: root, ContainerID: container, Size: 1},
+		{HostID: host + 1, ContainerID: container + 1, Size: size - 1},
+	}
+}
@@ -0,0 +1,52 @@
+package sandbox
+
+import (
+	"os"
+	"testing"
+
+	"github.com/joshjms/castletown/image"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLowerDir(t *testing.T) {
+	s := &Sandbox{id: "lower", config: &Config{RootfsImageDir: "/images/gcc-15"}}
+	require.Equal(t, "/images/gcc-15", s.getLowerDir())
+
+	s.config.RootfsLayers = []string{"/layers/base", "/layers/toolchain"}
+	require.Equal(t, "/images/gcc-15:/layers/toolchain:/layers/base", s.getLowerDir())
+}
+
+func TestBuildProfileMounts(t *testing.T) {
+	s := &Sandbox{id: "build", config: &Config{
+		BoxDir:        t.TempDir(),
+		UpperDir:      "/overlay/build-1/upper",
+		HostNetwork:   true,
+		RootOwnsImage: true,
+		UserNamespace: &UserNamespaceConfig{HostUID: 1000000, HostGID: 1000000, UIDMapCount: 65536, GIDMapCount: 65536},
+	}}
+	require.Equal(t, "/overlay/build-1/upper", s.getUpperDir())
+
+	// Root of a build is never the host's.
+	ownerUID, _ := image.Owner()
+	if os.Geteuid() == 0 {
+		require.Equal(t, uint32(image.OWNER_ID), ownerUID)
+	}
+
+	var destinations []string
+	for _, mount := range s.getMounts() {
+		destinations = append(destinations, mount.Destination)
+		require.NotEqual(t, "sysfs", mount.Type, "sysfs cannot be mounted without a network namespace of its own")
+		if mount.Destination == "/box" {
+			require.Equal(t, ownerUID, mount.UIDMappings[0].HostID)
+		}
+	}
+	require.Contains(t, destinations, "/etc/resolv.conf")
+
+	uid, _ := s.getRootIDs()
+	mappings := getIDMappings(uid, 1000000, 0, 65536)
+	require.Len(t, mappings, 2)
+	require.Equal(t, ownerUID, mappings[0].HostID)
+	require.Equal(t, uint32(1), mappings[1].ContainerID)
+	require.Equal(t, uint32(1000001), mappings[1].HostID)
+	require.Equal(t, uint32(65535), mappings[1].Size)
+}
@@ -1,14 +1,17 @@
 package sandbox
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/logging"
+	"github.com/joshjms/castletown/metrics"
+	"github.com/joshjms/castletown/tracing"
 	"github.com/opencontainers/runc/libcontainer"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/specconv"
@@ -26,84 +29,160 @@ func (s *Sandbox) GetId() string {
 	return s.id
 }
 
-// Run runs a command inside the sandbox and returns a Report
+// Run runs a command inside the sandbox and returns a Report. Cancelling
+// ctx kills the process and reports it as terminated. Failures are logged
+// with the sandbox's ID and the attributes of the logger ctx carries, and
+// each phase is traced as a child of the span in ctx.
 func (s *Sandbox) Run(ctx context.Context) (Report, error) {
-	err := s.prepareOverlayfs()
+	log := logging.FromContext(ctx).With(logging.KEY_SANDBOX_ID, s.id)
+	ctx, span := tracing.Start(ctx, "sandbox.Run", tracing.KEY_SANDBOX_ID.String(s.id))
+
+	report, err := s.run(ctx, log)
 	if err != nil {
-		return Report{}, fmt.Errorf("error preparing rootfs: %w", err)
+		log.Error("sandbox failed", logging.Error(err))
+		return Report{}, tracing.End(span, err)
 	}
+	log.Debug("sandbox finished", "status", report.Status, "cpu_time_us", report.CPUTime, "memory_bytes", report.Memory)
+	span.SetAttributes(tracing.KEY_STATUS.String(string(report.Status)))
+	tracing.End(span, nil)
+	return report, nil
+}
 
-	if err := s.prepareFiles(); err != nil {
-		return Report{}, fmt.Errorf("error preparing files: %w", err)
-	}
+func (s *Sandbox) run(ctx context.Context, log *slog.Logger) (Report, error) {
+	defer s.closePipes()
 
-	spec, err := s.createSpec()
-	if err != nil {
-		return Report{}, fmt.Errorf("error creating oci spec: %w", err)
+	setupAt := time.Now()
+
+	_, span := tracing.Start(ctx, "sandbox.prepareOverlayfs")
+	if err := tracing.End(span, s.prepareOverlayfs()); err != nil {
+		return Report{}, fmt.Errorf("error preparing rootfs: %w", err)
 	}
 
-	libcontainerConfig, err := specconv.CreateLibcontainerConfig(&specconv.CreateOpts{
-		UseSystemdCgroup: false,
-		Spec:             spec,
-		// RootlessEUID:     true,
-		// RootlessCgroups:  true,
-	})
-	if err != nil {
-		return Report{}, fmt.Errorf("error creating libcontainer config: %w", err)
+	_, span = tracing.Start(ctx, "sandbox.prepareFiles")
+	if err := tracing.End(span, s.prepareFiles()); err != nil {
+		return Report{}, fmt.Errorf("error preparing files: %w", err)
 	}
 
-	container, err := libcontainer.Create(config.LibcontainerDir, s.id, libcontainerConfig)
+	container, err := s.createContainer(ctx)
 	if err != nil {
-		return Report{}, fmt.Errorf("error creating container: %w", err)
+		return Report{}, err
 	}
-	defer container.Destroy()
+	defer func() {
+		_, span := tracing.Start(ctx, "sandbox.destroyContainer")
+		if err := tracing.End(span, container.Destroy()); err != nil {
+			log.Error("error destroying container", logging.Error(err))
+		}
+	}()
 
 	noNewPrivileges := true
 
-	var stdinBuf, stdoutBuf, stderrBuf bytes.Buffer
+	stdio, err := s.openStdio()
+	if err != nil {
+		return Report{}, fmt.Errorf("error opening stdio: %w", err)
+	}
+	defer stdio.Close()
 
-	if s.config.Stdin != "" {
-		stdinBuf.WriteString(s.config.Stdin)
+	if s.config.Output != nil {
+		s.config.Output.start()
+		defer s.config.Output.close()
 	}
 
 	process := &libcontainer.Process{
 		Args:            s.config.Args,
 		Env:             s.config.Env,
-		UID:             0,
-		GID:             0,
+		UID:             int(s.config.UID),
+		GID:             int(s.config.GID),
 		Cwd:             s.config.Cwd,
 		NoNewPrivileges: &noNewPrivileges,
-		Stdin:           &stdinBuf,
-		Stdout:          &stdoutBuf,
-		Stderr:          &stderrBuf,
+		Stdin:           stdio.stdin,
+		Stdout:          stdio.stdout,
+		Stderr:          stdio.stderr,
 		Rlimits:         getRlimits(s.config.Rlimit),
 		Init:            true,
 	}
 
+	// On a terminal, the container's init creates the pty and wires it to
+	// the process' standard streams itself.
+	if s.config.Terminal != nil {
+		process.Stdin, process.Stdout, process.Stderr = nil, nil, nil
+		process.ConsoleSocket = s.config.ConsoleSocket
+		process.ConsoleWidth = s.config.Terminal.Width
+		process.ConsoleHeight = s.config.Terminal.Height
+	}
+
 	startAt := time.Now()
 
-	if err := container.Run(process); err != nil {
+	_, span = tracing.Start(ctx, "sandbox.startProcess")
+	if err := tracing.End(span, container.Run(process)); err != nil {
 		return Report{}, fmt.Errorf("error running container: %w", err)
 	}
+	metrics.SetupDuration.Observe(time.Since(setupAt).Seconds())
+
+	// The process holds its own copies now; dropping ours lets peers on the
+	// other end of a pipe see EOF once this process exits.
+	stdio.Close()
 
 	processFinished := make(chan interface{}, 1)
-	timeLimitExceeded := false
+	killedBy := make(chan Status, 1)
+
+	wallTimeLimit := time.Duration(s.config.TimeLimitMs) * time.Millisecond * 3
+	if s.config.WallTimeLimitMs > 0 {
+		wallTimeLimit = time.Duration(s.config.WallTimeLimitMs) * time.Millisecond
+	}
 
 	go func() {
 		select {
 		case <-processFinished:
-		case <-time.After(time.Duration(s.config.TimeLimitMs) * time.Millisecond * 3):
-			timeLimitExceeded = true
+		case <-time.After(wallTimeLimit):
+			killedBy <- STATUS_TIME_LIMIT_EXCEEDED
+			container.Signal(unix.SIGKILL)
+		case <-ctx.Done():
+			killedBy <- STATUS_TERMINATED
 			container.Signal(unix.SIGKILL)
 		}
 	}()
 
+	_, span = tracing.Start(ctx, "sandbox.wait")
 	state, _ := process.Wait()
 	processFinished <- struct{}{}
+	span.End()
 
 	finishAt := time.Now()
 
-	return s.makeReport(&stdoutBuf, &stderrBuf, state, timeLimitExceeded, startAt, finishAt)
+	var killStatus Status
+	select {
+	case killStatus = <-killedBy:
+	default:
+	}
+
+	return s.makeReport(stdio, state, killStatus, startAt, finishAt)
+}
+
+// createContainer creates the libcontainer container of the sandbox.
+func (s *Sandbox) createContainer(ctx context.Context) (*libcontainer.Container, error) {
+	_, span := tracing.Start(ctx, "sandbox.createContainer")
+	defer span.End()
+
+	spec, err := s.createSpec()
+	if err != nil {
+		return nil, tracing.End(span, fmt.Errorf("error creating oci spec: %w", err))
+	}
+
+	libcontainerConfig, err := specconv.CreateLibcontainerConfig(&specconv.CreateOpts{
+		UseSystemdCgroup: false,
+		Spec:             spec,
+		// RootlessEUID:     true,
+		// RootlessCgroups:  true,
+	})
+	if err != nil {
+		return nil, tracing.End(span, fmt.Errorf("error creating libcontainer config: %w", err))
+	}
+
+	container, err := libcontainer.Create(config.LibcontainerDir, s.id, libcontainerConfig)
+	if err != nil {
+		return nil, tracing.End(span, fmt.Errorf("error creating container: %w", err))
+	}
+	return container, nil
 }
 
 func getRlimits(cfg *RlimitConfig) []configs.Rlimit {
@@ -0,0 +1,366 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/utils"
+	"golang.org/x/sys/unix"
+)
+
+// SessionLimits bound a session on top of the sandbox's own limits. The
+// sandbox's TimeLimitMs is the CPU budget of the whole session.
+type SessionLimits struct {
+	// IdleTimeout ends the session when no input was sent and no output was
+	// read for this long. Zero disables it.
+	IdleTimeout time.Duration
+	// MaxLifetime bounds the real time of the session. Zero keeps the
+	// sandbox's wall time limit.
+	MaxLifetime time.Duration
+}
+
+const (
+	sessionPollInterval = 100 * time.Millisecond
+	// terminalEOF is Ctrl-D, the default end-of-file character of a tty.
+	terminalEOF = 0x04
+)
+
+// Session is a sandboxed process that stays alive while a client streams
+// its standard input and reads its output.
+type Session struct {
+	id string
+
+	stdin  *os.File
+	stdout *os.File
+	stderr *os.File
+
+	// pty is the terminal's master when the sandbox runs on a terminal. It
+	// serves as both stdin and stdout; stderr is merged into it.
+	pty *os.File
+
+	lastActive atomic.Int64
+	cancel     context.CancelFunc
+	closeOnce  sync.Once
+
+	// cpuUsage is the CPU time in microseconds last seen in the cgroup, for
+	// sessions whose sandbox fails without a report.
+	cpuUsage atomic.Uint64
+
+	done   chan struct{}
+	report Report
+	err    error
+}
+
+// StartSession starts an existing sandbox with its standard streams
+// connected to the returned session. It holds a concurrency slot until the
+// process exits. The session ends when the process exits, ctx is cancelled,
+// Kill is called, the idle timeout expires or the process has used up its
+// CPU budget.
+func (m *Manager) StartSession(ctx context.Context, id string, limits SessionLimits) (*Session, error) {
+	m.mu.Lock()
+	sandbox, exists := m.sandboxes[id]
+	m.mu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("sandbox with id %q does not exist", id)
+	}
+
+	if err := m.acquireContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if sandbox.config.Terminal != nil {
+		return m.startTerminalSession(ctx, sandbox, limits)
+	}
+
+	var pipes [3][2]*os.File
+	for i := range pipes {
+		r, w, err := os.Pipe()
+		if err != nil {
+			for _, p := range pipes[:i] {
+				p[0].Close()
+				p[1].Close()
+			}
+			m.release(1)
+			return nil, fmt.Errorf("error creating pipe: %w", err)
+		}
+		pipes[i] = [2]*os.File{r, w}
+	}
+
+	sandbox.config.StdinPipe = pipes[0][0]
+	sandbox.config.StdoutPipe = pipes[1][1]
+	sandbox.config.StderrPipe = pipes[2][1]
+
+	sess := m.runSession(ctx, sandbox, limits)
+	sess.stdin = pipes[0][1]
+	sess.stdout = pipes[1][0]
+	sess.stderr = pipes[2][0]
+
+	return sess, nil
+}
+
+// startTerminalSession starts a sandbox on a pseudo-terminal and waits until
+// its init has handed over the terminal's master.
+func (m *Manager) startTerminalSession(ctx context.Context, sandbox *Sandbox, limits SessionLimits) (*Session, error) {
+	parent, child, err := utils.NewSockPair("console")
+	if err != nil {
+		m.release(1)
+		return nil, fmt.Errorf("error creating console socket: %w", err)
+	}
+	defer parent.Close()
+
+	sandbox.config.ConsoleSocket = child
+	if !hasEnv(sandbox.config.Env, "TERM") {
+		sandbox.config.Env = append(sandbox.config.Env, "TERM=xterm-256color")
+	}
+
+	sess := m.runSession(ctx, sandbox, limits)
+
+	// The sandbox closes its end of the socket once the process has started,
+	// so this fails instead of blocking if the container never comes up.
+	master, err := utils.RecvFile(parent)
+	if err != nil {
+		sess.Kill()
+		if _, runErr := sess.Wait(); runErr != nil {
+			return nil, runErr
+		}
+		return nil, fmt.Errorf("error receiving terminal: %w", err)
+	}
+
+	pty, err := pollableFile(master)
+	if err != nil {
+		sess.Kill()
+		sess.Wait()
+		return nil, fmt.Errorf("error receiving terminal: %w", err)
+	}
+
+	sess.pty = pty
+	sess.stdin = pty
+	sess.stdout = pty
+
+	return sess, nil
+}
+
+// runSession runs sandbox in the background for a new session, whose
+// streams the caller fills in.
+func (m *Manager) runSession(ctx context.Context, sandbox *Sandbox, limits SessionLimits) *Session {
+	if limits.MaxLifetime > 0 {
+		sandbox.config.WallTimeLimitMs = limits.MaxLifetime.Milliseconds()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	sess := &Session{
+		id:     sandbox.id,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	sess.touch()
+
+	go func() {
+		defer m.release(1)
+		defer close(sess.done)
+		defer cancel()
+
+		sess.report, sess.err = sandbox.Run(ctx)
+		if sess.err != nil {
+			sess.report = Report{CPUTime: sess.cpuUsage.Load()}
+			sess.err = fmt.Errorf("error running sandbox %q: %w", sandbox.id, sess.err)
+		}
+	}()
+
+	go m.watchSession(ctx, sess, sandbox.config.TimeLimitMs, limits.IdleTimeout)
+
+	return sess
+}
+
+// watchSession kills the session once it has been idle for too long or its
+// CPU usage exceeds the budget. The latter is reported as a time limit
+// violation by the sandbox itself.
+func (m *Manager) watchSession(ctx context.Context, sess *Session, cpuBudgetMs int64, idleTimeout time.Duration) {
+	ticker := time.NewTicker(sessionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sess.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if idleTimeout > 0 && time.Since(time.Unix(0, sess.lastActive.Load())) > idleTimeout {
+			sess.Kill()
+			return
+		}
+
+		// The cgroup does not exist until the container has been created.
+		cgManager, err := loadCgroup(sess.id)
+		if err != nil {
+			continue
+		}
+		stats, err := cgManager.Stat()
+		if err != nil {
+			continue
+		}
+		usage := stats.GetCPU().GetUsageUsec()
+		sess.cpuUsage.Store(usage)
+		if usage > uint64(cpuBudgetMs)*1000 {
+			sess.Kill()
+			return
+		}
+	}
+}
+
+func (s *Session) touch() {
+	s.lastActive.Store(time.Now().UnixNano())
+}
+
+// Write sends p to the process' standard input.
+func (s *Session) Write(p []byte) (int, error) {
+	s.touch()
+	return s.stdin.Write(p)
+}
+
+// CloseStdin signals end of input to the process. On a terminal this sends
+// the end-of-file character, since closing the master would hang it up.
+func (s *Session) CloseStdin() error {
+	if s.pty != nil {
+		_, err := s.pty.Write([]byte{terminalEOF})
+		return err
+	}
+	return s.stdin.Close()
+}
+
+// Stdout returns the process' standard output. It reaches EOF once the
+// process and everything it started have exited.
+func (s *Session) Stdout() io.Reader {
+	return &activityReader{r: s.stdout, s: s}
+}
+
+// Stderr returns the process' standard error. On a terminal it is merged
+// into Stdout and this reader is empty.
+func (s *Session) Stderr() io.Reader {
+	if s.stderr == nil {
+		return &bytes.Buffer{}
+	}
+	return &activityReader{r: s.stderr, s: s}
+}
+
+// Resize changes the size of the session's terminal.
+func (s *Session) Resize(t Terminal) error {
+	if s.pty == nil {
+		return fmt.Errorf("session has no terminal")
+	}
+
+	conn, err := s.pty.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		ioctlErr = unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{
+			Row: t.Height,
+			Col: t.Width,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return ioctlErr
+}
+
+// Kill ends the session. The process is reported as terminated.
+func (s *Session) Kill() {
+	s.cancel()
+}
+
+// Done is closed once the process has exited and its report is available.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait waits for the process to exit and returns its report. Output is not
+// part of the report; it has been streamed through Stdout and Stderr. If the
+// sandbox failed, the report only holds the CPU time last seen.
+func (s *Session) Wait() (Report, error) {
+	<-s.done
+	return s.report, s.err
+}
+
+// Close kills the session if it is still running, waits for it to end and
+// releases the host side of its standard streams.
+func (s *Session) Close() {
+	s.Kill()
+	<-s.done
+
+	s.closeOnce.Do(func() {
+		for _, f := range []*os.File{s.stdin, s.stdout, s.stderr} {
+			if f != nil {
+				f.Close()
+			}
+		}
+	})
+}
+
+// pollableFile switches f to non-blocking mode and wraps it again so that
+// reads can be interrupted by Close.
+func pollableFile(f *os.File) (*os.File, error) {
+	defer f.Close()
+
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var fd int
+	var dupErr error
+	err = conn.Control(func(raw uintptr) {
+		fd, dupErr = unix.Dup(int(raw))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dupErr != nil {
+		return nil, dupErr
+	}
+
+	unix.CloseOnExec(fd)
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
+
+func hasEnv(env []string, key string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+type activityReader struct {
+	r io.Reader
+	s *Session
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.s.touch()
+	}
+	return n, err
+}
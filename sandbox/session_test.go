@@ -0,0 +1,35 @@
+package sandbox
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSessionResize(t *testing.T) {
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pty support: %v", err)
+	}
+
+	pty, err := pollableFile(ptmx)
+	require.NoError(t, err)
+	defer pty.Close()
+
+	sess := &Session{pty: pty}
+	require.NoError(t, sess.Resize(Terminal{Width: 120, Height: 40}))
+
+	var ws *unix.Winsize
+	conn, err := pty.SyscallConn()
+	require.NoError(t, err)
+	require.NoError(t, conn.Control(func(fd uintptr) {
+		ws, err = unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, uint16(120), ws.Col)
+	require.Equal(t, uint16(40), ws.Row)
+
+	require.Error(t, (&Session{}).Resize(Terminal{Width: 80, Height: 24}))
+}
@@ -17,6 +17,36 @@ func (s *Sandbox) createSpec() (*specs.Spec, error) {
 	}
 
 	mounts := s.getMounts()
+	rootUID, rootGID := s.getRootIDs()
+	userns := s.config.UserNamespace
+
+	namespaces := []specs.LinuxNamespace{
+		{
+			Type: specs.CgroupNamespace,
+		},
+		{
+			Type: specs.PIDNamespace,
+		},
+		{
+			Type: specs.IPCNamespace,
+			Path: s.config.IPCNSPath,
+		},
+		{
+			Type: specs.UTSNamespace,
+		},
+		{
+			Type: specs.MountNamespace,
+		},
+		{
+			Type: specs.UserNamespace,
+		},
+	}
+	if !s.config.HostNetwork {
+		namespaces = append(namespaces, specs.LinuxNamespace{
+			Type: specs.NetworkNamespace,
+			Path: s.config.NetNSPath,
+		})
+	}
 
 	spec := &specs.Spec{
 		Version: specs.Version,
@@ -32,43 +62,9 @@ func (s *Sandbox) createSpec() (*specs.Spec, error) {
 		Linux: &specs.Linux{
 			CgroupsPath: filepath.Join(slicePath, fmt.Sprintf("castletown-%s.scope", s.id), s.id),
 			Resources:   cgroupResources(s.config.Cgroup),
-			UIDMappings: []specs.LinuxIDMapping{
-				{
-					HostID:      s.config.UserNamespace.HostUID,
-					ContainerID: s.config.UserNamespace.ContainerUID,
-					Size:        s.config.UserNamespace.UIDMapCount,
-				},
-			},
-			GIDMappings: []specs.LinuxIDMapping{
-				{
-					HostID:      s.config.UserNamespace.HostGID,
-					ContainerID: s.config.UserNamespace.ContainerGID,
-					Size:        s.config.UserNamespace.GIDMapCount,
-				},
-			},
-			Namespaces: []specs.LinuxNamespace{
-				{
-					Type: specs.CgroupNamespace,
-				},
-				{
-					Type: specs.PIDNamespace,
-				},
-				{
-					Type: specs.IPCNamespace,
-				},
-				{
-					Type: specs.UTSNamespace,
-				},
-				{
-					Type: specs.MountNamespace,
-				},
-				{
-					Type: specs.UserNamespace,
-				},
-				{
-					Type: specs.NetworkNamespace,
-				},
-			},
+			UIDMappings: getIDMappings(rootUID, userns.HostUID, userns.ContainerUID, userns.UIDMapCount),
+			GIDMappings: getIDMappings(rootGID, userns.HostGID, userns.ContainerGID, userns.GIDMapCount),
+			Namespaces:  namespaces,
 			// https://github.com/moby/moby/blob/master/oci/defaults.go
 			MaskedPaths: []string{
 				"/proc/asound",
@@ -117,6 +113,7 @@ func (s *Sandbox) getMounts() []specs.Mount {
 
 	mounts = append(mounts, rootfsMount)
 
+	rootUID, rootGID := s.getRootIDs()
 	bindMount := specs.Mount{
 		Destination: "/box",
 		Type:        "bind",
@@ -132,14 +129,14 @@ func (s *Sandbox) getMounts() []specs.Mount {
 		UIDMappings: []specs.LinuxIDMapping{
 			{
 				ContainerID: 0,
-				HostID:      s.config.UserNamespace.HostUID,
+				HostID:      rootUID,
 				Size:        1,
 			},
 		},
 		GIDMappings: []specs.LinuxIDMapping{
 			{
 				ContainerID: 0,
-				HostID:      s.config.UserNamespace.HostGID,
+				HostID:      rootGID,
 				Size:        1,
 			},
 		},
@@ -147,7 +144,33 @@ func (s *Sandbox) getMounts() []specs.Mount {
 
 	mounts = append(mounts, bindMount)
 
-	mounts = append(mounts, defaultMounts()...)
+	if s.config.HostNetwork {
+		mounts = append(mounts, specs.Mount{
+			Destination: "/etc/resolv.conf",
+			Type:        "bind",
+			Source:      "/etc/resolv.conf",
+			Options:     []string{"rbind", "nosuid", "noexec", "nodev", "ro"},
+		})
+	}
+
+	for _, mount := range defaultMounts() {
+		switch {
+		case mount.Type == "mqueue" && s.config.IPCNSPath != "":
+			// mqueue can only be mounted by the owner of the IPC namespace,
+			// which a shared one is not.
+			continue
+		case mount.Type == "sysfs" && (s.config.NetNSPath != "" || s.config.HostNetwork):
+			// Likewise for sysfs and the network namespace; fall back to a
+			// read-only bind of the host's /sys, as other runtimes do.
+			mount = specs.Mount{
+				Destination: "/sys",
+				Type:        "bind",
+				Source:      "/sys",
+				Options:     []string{"rbind", "nosuid", "noexec", "nodev", "ro"},
+			}
+		}
+		mounts = append(mounts, mount)
+	}
 
 	return mounts
 }
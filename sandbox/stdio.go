@@ -0,0 +1,185 @@
+package sandbox
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"golang.org/x/sys/unix"
+)
+
+// stdio holds the standard streams handed to the sandboxed process. Streams
+// backed by files inside the box are passed to the process as plain file
+// descriptors, so their data never goes through this process' memory.
+type stdio struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	stdoutBuf *capture
+	stderrBuf *capture
+
+	files []*os.File
+}
+
+func (s *Sandbox) openStdio() (*stdio, error) {
+	st := &stdio{}
+
+	if s.config.ConsoleSocket != nil {
+		st.files = append(st.files, s.config.ConsoleSocket)
+	}
+
+	if s.config.StdinPipe != nil {
+		st.files = append(st.files, s.config.StdinPipe)
+		st.stdin = s.config.StdinPipe
+	} else if s.config.StdinFile != "" {
+		f, err := openBoxFile(s.config.BoxDir, s.config.StdinFile, os.O_RDONLY)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		st.files = append(st.files, f)
+		st.stdin = f
+	} else {
+		st.stdin = strings.NewReader(s.config.Stdin)
+	}
+
+	if s.config.StdoutPipe != nil {
+		st.files = append(st.files, s.config.StdoutPipe)
+		st.stdout = s.config.StdoutPipe
+	} else if s.config.StdoutFile != "" {
+		f, err := openBoxFile(s.config.BoxDir, s.config.StdoutFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		st.files = append(st.files, f)
+		st.stdout = f
+	} else {
+		st.stdoutBuf = s.newCapture(STREAM_STDOUT)
+		st.stdout = st.stdoutBuf
+	}
+
+	if s.config.StderrPipe != nil {
+		st.files = append(st.files, s.config.StderrPipe)
+		st.stderr = s.config.StderrPipe
+	} else if s.config.StderrFile != "" {
+		f, err := openBoxFile(s.config.BoxDir, s.config.StderrFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		st.files = append(st.files, f)
+		st.stderr = f
+	} else {
+		st.stderrBuf = s.newCapture(STREAM_STDERR)
+		st.stderr = st.stderrBuf
+	}
+
+	return st, nil
+}
+
+// Stdout returns the captured standard output, or an empty reader when it
+// was redirected to a file.
+func (st *stdio) Stdout() io.Reader {
+	if st.stdoutBuf == nil {
+		return &bytes.Buffer{}
+	}
+	return &st.stdoutBuf.buf
+}
+
+// Stderr returns the captured standard error, or an empty reader when it
+// was redirected to a file.
+func (st *stdio) Stderr() io.Reader {
+	if st.stderrBuf == nil {
+		return &bytes.Buffer{}
+	}
+	return &st.stderrBuf.buf
+}
+
+// Truncated tells whether output was left out of the captured stdout and
+// stderr.
+func (st *stdio) Truncated() (stdout, stderr bool) {
+	return st.stdoutBuf != nil && st.stdoutBuf.truncated, st.stderrBuf != nil && st.stderrBuf.truncated
+}
+
+// capture keeps a stream for the report and hands it to the output stream,
+// if any. Past MaxOutputSize bytes, writes still succeed so that the
+// process keeps running, but what they carry is dropped from both.
+type capture struct {
+	buf       bytes.Buffer
+	out       io.Writer
+	limit     int64
+	truncated bool
+}
+
+func (s *Sandbox) newCapture(name string) *capture {
+	c := &capture{limit: s.config.MaxOutputSize}
+	c.out = &c.buf
+	if s.config.Output != nil {
+		c.out = io.MultiWriter(&c.buf, s.config.Output.writer(name))
+	}
+	return c
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	n := len(p)
+	if left := c.limit - int64(c.buf.Len()); c.limit > 0 && int64(len(p)) > left {
+		p = p[:max(left, 0)]
+		c.truncated = true
+	}
+	if len(p) > 0 {
+		if _, err := c.out.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close releases this process' copies of the file-backed streams. It is
+// safe to call more than once.
+func (st *stdio) Close() {
+	for _, f := range st.files {
+		f.Close()
+	}
+	st.files = nil
+}
+
+// closePipes closes pipes handed over in the config. It is used on error
+// paths before the stdio has been opened, so that the process on the other
+// end of a pipe sees EOF instead of blocking forever.
+func (s *Sandbox) closePipes() {
+	if s.config.StdinPipe != nil {
+		s.config.StdinPipe.Close()
+	}
+	if s.config.StdoutPipe != nil {
+		s.config.StdoutPipe.Close()
+	}
+	if s.config.StderrPipe != nil {
+		s.config.StderrPipe.Close()
+	}
+	if s.config.ConsoleSocket != nil {
+		s.config.ConsoleSocket.Close()
+	}
+}
+
+// openBoxFile opens name relative to boxDir. Symlinks are resolved as if
+// boxDir were the root, so a link planted by an earlier step cannot make the
+// host open a file outside of the box.
+func openBoxFile(boxDir, name string, flag int) (*os.File, error) {
+	path, err := securejoin.SecureJoin(boxDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag&os.O_CREATE != 0 {
+		if err := os.MkdirAll(filepath.Dir(path), defaultDirMode); err != nil {
+			return nil, err
+		}
+	}
+
+	return os.OpenFile(path, flag|unix.O_NOFOLLOW, 0644)
+}
@@ -0,0 +1,96 @@
+package sandbox
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenStdio(t *testing.T) {
+	boxDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(boxDir, "input.txt"), []byte("6 9\n"), 0644))
+
+	s := &Sandbox{config: &Config{
+		BoxDir:     boxDir,
+		Stdin:      "ignored",
+		StdinFile:  "input.txt",
+		StdoutFile: "out/stdout.txt",
+	}}
+
+	st, err := s.openStdio()
+	require.NoError(t, err)
+	defer st.Close()
+
+	stdin, err := io.ReadAll(st.stdin)
+	require.NoError(t, err)
+	require.Equal(t, "6 9\n", string(stdin))
+
+	_, err = st.stdout.Write([]byte("15\n"))
+	require.NoError(t, err)
+	_, err = st.stderr.Write([]byte("warning\n"))
+	require.NoError(t, err)
+
+	stdout, err := os.ReadFile(filepath.Join(boxDir, "out", "stdout.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "15\n", string(stdout))
+
+	captured, err := io.ReadAll(st.Stdout())
+	require.NoError(t, err)
+	require.Empty(t, captured)
+
+	captured, err = io.ReadAll(st.Stderr())
+	require.NoError(t, err)
+	require.Equal(t, "warning\n", string(captured))
+}
+
+func TestOpenBoxFileStaysInside(t *testing.T) {
+	root := t.TempDir()
+	boxDir := filepath.Join(root, "box")
+	require.NoError(t, os.MkdirAll(boxDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret"), []byte("secret"), 0644))
+	require.NoError(t, os.Symlink("../secret", filepath.Join(boxDir, "link")))
+
+	_, err := openBoxFile(boxDir, "link", os.O_RDONLY)
+	require.Error(t, err)
+}
+
+func TestOpenStdioMaxOutputSize(t *testing.T) {
+	var streamed []byte
+	output := NewOutputStream(0, func(chunk OutputChunk) {
+		if chunk.Stream == STREAM_STDOUT {
+			streamed = append(streamed, chunk.Data...)
+		}
+	})
+	output.start()
+
+	s := &Sandbox{config: &Config{MaxOutputSize: 8, Output: output}}
+
+	st, err := s.openStdio()
+	require.NoError(t, err)
+	defer st.Close()
+
+	for _, p := range []string{"hello ", "world", "!"} {
+		n, err := st.stdout.Write([]byte(p))
+		require.NoError(t, err)
+		require.Equal(t, len(p), n)
+	}
+	_, err = st.stderr.Write([]byte("warning\n"))
+	require.NoError(t, err)
+	output.close()
+
+	captured, err := io.ReadAll(st.Stdout())
+	require.NoError(t, err)
+	require.Equal(t, "hello wo", string(captured))
+	require.Equal(t, "hello wo", string(streamed))
+
+	captured, err = io.ReadAll(st.Stderr())
+	require.NoError(t, err)
+	require.Equal(t, "warning\n", string(captured))
+
+	stdoutTruncated, stderrTruncated := st.Truncated()
+	require.True(t, stdoutTruncated)
+	require.False(t, stderrTruncated)
+}
@@ -0,0 +1,173 @@
+package sandbox
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Output streams buffer at most OUTPUT_STREAM_BUFFER bytes of each stream
+// and hand them over every OUTPUT_STREAM_INTERVAL.
+const (
+	OUTPUT_STREAM_BUFFER   = 64 * 1024
+	OUTPUT_STREAM_INTERVAL = 50 * time.Millisecond
+)
+
+const (
+	STREAM_STDOUT = "stdout"
+	STREAM_STDERR = "stderr"
+)
+
+// OutputChunk is a piece of what a process wrote to one of its streams.
+// Dropped counts the bytes of the stream left out before Data.
+type OutputChunk struct {
+	Stream  string `json:"stream"`
+	Data    []byte `json:"data"`
+	Dropped int64  `json:"dropped,omitempty"`
+}
+
+// OutputStream hands what a process writes to its captured stdout and
+// stderr to a handler while the process runs. It never slows the process
+// down: output beyond what the buffer holds until the next hand-over, at no
+// more than the rate limit, is dropped from the stream. The report still
+// has all of it, up to the sandbox's MaxOutputSize.
+type OutputStream struct {
+	handler func(OutputChunk)
+	// rate is in bytes per second, with bursts of up to a second's worth.
+	// Zero is unlimited.
+	rate   int64
+	tokens float64
+	last   time.Time
+
+	bufs map[string]*outputBuffer
+
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu sync.Mutex
+}
+
+// outputBuffer holds the output of a stream not handed over yet. Once the
+// buffer is full, writes are dropped until it has been emptied, so that
+// dropped bytes are always after the data; gap counts those dropped before
+// it.
+type outputBuffer struct {
+	data    []byte
+	dropped int64
+	gap     int64
+}
+
+// NewOutputStream returns a stream that calls handler with the output of
+// the process, one chunk at a time, at no more than rate bytes per second.
+// A zero rate is unlimited.
+func NewOutputStream(rate int64, handler func(OutputChunk)) *OutputStream {
+	return &OutputStream{
+		handler: handler,
+		rate:    rate,
+		tokens:  float64(rate),
+		bufs: map[string]*outputBuffer{
+			STREAM_STDOUT: {},
+			STREAM_STDERR: {},
+		},
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// writer returns the writer of the stream called name.
+func (o *OutputStream) writer(name string) io.Writer {
+	return &outputWriter{o: o, buf: o.bufs[name]}
+}
+
+type outputWriter struct {
+	o   *OutputStream
+	buf *outputBuffer
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.o.mu.Lock()
+	defer w.o.mu.Unlock()
+
+	n := 0
+	if w.buf.dropped == 0 {
+		n = min(len(p), OUTPUT_STREAM_BUFFER-len(w.buf.data))
+		w.buf.data = append(w.buf.data, p[:n]...)
+	}
+	w.buf.dropped += int64(len(p) - n)
+	return len(p), nil
+}
+
+// start starts handing output over.
+func (o *OutputStream) start() {
+	o.last = time.Now()
+
+	go func() {
+		defer close(o.stopped)
+
+		ticker := time.NewTicker(OUTPUT_STREAM_INTERVAL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-o.done:
+				return
+			case <-ticker.C:
+				o.flush(false)
+			}
+		}
+	}()
+}
+
+// close hands over what is left, regardless of the rate limit, once the
+// process' streams are closed.
+func (o *OutputStream) close() {
+	close(o.done)
+	<-o.stopped
+	o.flush(true)
+}
+
+func (o *OutputStream) flush(all bool) {
+	o.mu.Lock()
+
+	if o.rate > 0 {
+		now := time.Now()
+		o.tokens = min(float64(o.rate), o.tokens+now.Sub(o.last).Seconds()*float64(o.rate))
+		o.last = now
+	}
+
+	var chunks []OutputChunk
+	for _, name := range []string{STREAM_STDOUT, STREAM_STDERR} {
+		buf := o.bufs[name]
+
+		n := len(buf.data)
+		if o.rate > 0 && !all {
+			n = min(n, int(o.tokens))
+			o.tokens -= float64(n)
+		}
+		if n > 0 {
+			chunks = append(chunks, OutputChunk{
+				Stream:  name,
+				Data:    append([]byte(nil), buf.data[:n]...),
+				Dropped: buf.gap,
+			})
+			buf.data = append(buf.data[:0], buf.data[n:]...)
+			buf.gap = 0
+		}
+		if len(buf.data) == 0 {
+			buf.gap += buf.dropped
+			buf.dropped = 0
+		}
+
+		// Whatever was dropped at the very end is only reported last.
+		if all && buf.gap > 0 {
+			chunks = append(chunks, OutputChunk{Stream: name, Dropped: buf.gap})
+			buf.gap = 0
+		}
+	}
+
+	o.mu.Unlock()
+
+	for _, chunk := range chunks {
+		o.handler(chunk)
+	}
+}
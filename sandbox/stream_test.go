@@ -0,0 +1,81 @@
+package sandbox
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputStream(t *testing.T) {
+	var chunks []OutputChunk
+	o := NewOutputStream(0, func(chunk OutputChunk) {
+		chunks = append(chunks, chunk)
+	})
+	o.start()
+
+	o.writer(STREAM_STDOUT).Write([]byte("hello "))
+	o.writer(STREAM_STDERR).Write([]byte("oops"))
+	o.writer(STREAM_STDOUT).Write([]byte("world"))
+	time.Sleep(3 * OUTPUT_STREAM_INTERVAL)
+	o.writer(STREAM_STDOUT).Write([]byte("!"))
+	o.close()
+
+	var stdout, stderr bytes.Buffer
+	for _, chunk := range chunks {
+		require.Zero(t, chunk.Dropped)
+		if chunk.Stream == STREAM_STDOUT {
+			stdout.Write(chunk.Data)
+		} else {
+			stderr.Write(chunk.Data)
+		}
+	}
+	require.Equal(t, "hello world!", stdout.String())
+	require.Equal(t, "oops", stderr.String())
+	require.Greater(t, len(chunks), 2, "output should be handed over while the process runs")
+}
+
+func TestOutputStreamDrops(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		chunks []OutputChunk
+	)
+	o := NewOutputStream(1000, func(chunk OutputChunk) {
+		mu.Lock()
+		chunks = append(chunks, chunk)
+		mu.Unlock()
+	})
+	o.start()
+
+	w := o.writer(STREAM_STDOUT)
+	n, err := w.Write([]byte(strings.Repeat("a", OUTPUT_STREAM_BUFFER)))
+	require.NoError(t, err)
+	require.Equal(t, OUTPUT_STREAM_BUFFER, n)
+
+	// The buffer is full, so this is dropped but still reported written.
+	n, err = w.Write([]byte("bbb"))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	time.Sleep(3 * OUTPUT_STREAM_INTERVAL)
+	mu.Lock()
+	require.NotEmpty(t, chunks)
+	require.LessOrEqual(t, len(chunks[0].Data), 1000, "the rate limit allows a second's worth at once")
+	mu.Unlock()
+
+	o.close()
+
+	total := 0
+	for _, chunk := range chunks[:len(chunks)-1] {
+		require.Zero(t, chunk.Dropped)
+		total += len(chunk.Data)
+	}
+	require.Equal(t, OUTPUT_STREAM_BUFFER, total)
+
+	last := chunks[len(chunks)-1]
+	require.Empty(t, last.Data)
+	require.EqualValues(t, 3, last.Dropped)
+}
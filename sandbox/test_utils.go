@@ -77,7 +77,7 @@ func (tc *Testcase) Run(t *testing.T) []Report {
 	compileId := fmt.Sprintf("%s-%d", id, 0)
 	err := m.NewSandbox(compileId, compileConfig)
 	defer require.NoError(t, err, "failed to create compile sandbox: %v", err)
-	defer m.DestroySandbox(compileId)
+	defer m.DestroySandbox(context.Background(), compileId)
 
 	ctx := context.Background()
 	compileStartTime := time.Now()
@@ -132,7 +132,7 @@ func (tc *Testcase) Run(t *testing.T) []Report {
 			}
 
 			err = m.NewSandbox(execId, execConfig)
-			defer m.DestroySandbox(execId)
+			defer m.DestroySandbox(context.Background(), execId)
 			require.NoError(t, err, "failed to create exec sandbox: %v", err)
 
 			ctx = context.Background()
@@ -151,7 +151,7 @@ func (tc *Testcase) Run(t *testing.T) []Report {
 			}
 
 			if tc.ExpectedOutput != nil {
-				require.Equal(t, *tc.ExpectedOutput, execReport.Stdout, "output != expectedOutput")
+				require.Equal(t, *tc.ExpectedOutput, string(execReport.Stdout), "output != expectedOutput")
 			}
 
 			reports[i-1] = execReport
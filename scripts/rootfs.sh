@@ -2,18 +2,22 @@
 
 set -euo pipefail
 
-if [ -d "/tmp/castletown/images/gcc-15-bookworm" ]; then
+IMAGE="gcc:15-bookworm"
+IMAGES_DIR="/tmp/castletown/images"
+
+if [ -d "$IMAGES_DIR/gcc-15-bookworm" ]; then
     exit 0
 fi
 
-skopeo copy docker://gcc:15-bookworm oci:/tmp/_tmp_gcc:15-bookworm
-
-umoci raw unpack --rootless \
-    --image /tmp/_tmp_gcc:15-bookworm \
-    /tmp/_tmp_gcc_15-bookworm
+go build -o /tmp/_tmp_castletown main.go
+trap 'rm -rf /tmp/_tmp_castletown /tmp/_tmp_gcc_15-bookworm /tmp/_tmp_gcc_15-bookworm.tar' EXIT
 
-mkdir -p /tmp/castletown/images/gcc-15-bookworm
-cp -r /tmp/_tmp_gcc_15-bookworm/* /tmp/castletown/images/gcc-15-bookworm
-rm -rf /tmp/_tmp_gcc_15-bookworm
-
-mkdir -p /tmp/castletown/images/gcc-15-bookworm/box
+# Any tool that writes an OCI image layout or a Docker archive will do.
+if command -v skopeo >/dev/null; then
+    skopeo copy "docker://$IMAGE" "oci:/tmp/_tmp_gcc_15-bookworm"
+    /tmp/_tmp_castletown image import /tmp/_tmp_gcc_15-bookworm --name "$IMAGE" --images-dir "$IMAGES_DIR"
+else
+    docker pull "$IMAGE"
+    docker save "$IMAGE" -o /tmp/_tmp_gcc_15-bookworm.tar
+    /tmp/_tmp_castletown image import /tmp/_tmp_gcc_15-bookworm.tar --name "$IMAGE" --images-dir "$IMAGES_DIR"
+fi
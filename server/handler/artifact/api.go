@@ -0,0 +1,7 @@
+package artifact
+
+type Request struct {
+	ID   string `json:"id"`
+	Step int    `json:"step"`
+	Path string `json:"path"`
+}
@@ -0,0 +1,78 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/joshjms/castletown/auth"
+	"github.com/joshjms/castletown/job"
+)
+
+// Handler streams a single output file of an executed step. The request is
+// given as query parameters: /artifact?id=<job>&step=<n>&path=<file>.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	ServeArtifact(w, r, http.Error)
+}
+
+// ServeArtifact serves an artifact like Handler, reporting errors with fail.
+func ServeArtifact(w http.ResponseWriter, r *http.Request, fail func(http.ResponseWriter, string, int)) {
+	if r.Method != http.MethodGet {
+		fail(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	step, err := strconv.Atoi(r.URL.Query().Get("step"))
+	if err != nil {
+		fail(w, fmt.Sprintf("invalid step: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	req := Request{
+		ID:   r.URL.Query().Get("id"),
+		Step: step,
+		Path: r.URL.Query().Get("path"),
+	}
+
+	path, err := resolve(r.Context(), req)
+	if err != nil {
+		fail(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fail(w, fmt.Sprintf("cannot open artifact: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		fail(w, fmt.Sprintf("cannot stat artifact: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// resolve returns the path of the artifact req names. Jobs of other
+// principals than the one ctx carries are treated as missing.
+func resolve(ctx context.Context, req Request) (string, error) {
+	j, exists := job.GetJobPool().GetJob(req.ID)
+	if !exists || !auth.Owns(ctx, j.Principal) {
+		return "", fmt.Errorf("job %q does not exist", req.ID)
+	}
+
+	path, err := j.GetArtifactPath(req.Step, req.Path)
+	if err != nil {
+		return "", fmt.Errorf("artifact not available: %w", err)
+	}
+
+	return path, nil
+}
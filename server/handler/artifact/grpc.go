@@ -0,0 +1,54 @@
+package artifact
+
+import (
+	"io"
+	"os"
+
+	pb "github.com/joshjms/castletown/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const chunkSize = 256 * 1024
+
+type ArtifactServer struct {
+	pb.UnimplementedArtifactServiceServer
+}
+
+func NewArtifactServer() *ArtifactServer {
+	return &ArtifactServer{}
+}
+
+func (s *ArtifactServer) GetArtifact(req *pb.GetArtifactRequest, stream grpc.ServerStreamingServer[pb.ArtifactChunk]) error {
+	path, err := resolve(stream.Context(), Request{
+		ID:   req.Id,
+		Step: int(req.Step),
+		Path: req.Path,
+	})
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot open artifact: %v", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.ArtifactChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "cannot read artifact: %v", err)
+		}
+	}
+}
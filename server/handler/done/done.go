@@ -1,10 +1,12 @@
 package done
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 
+	"github.com/joshjms/castletown/auth"
 	"github.com/joshjms/castletown/job"
 )
 
@@ -21,10 +23,19 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jp := job.GetJobPool()
-	jp.RemoveJob(req.ID)
+	Release(r.Context(), req.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"ok"}`))
 }
+
+// Release removes the job with the given ID from the pool, unless it belongs
+// to another principal than the one ctx carries.
+func Release(ctx context.Context, id string) {
+	jp := job.GetJobPool()
+	if j, exists := jp.GetJob(id); exists && !auth.Owns(ctx, j.Principal) {
+		return
+	}
+	jp.RemoveJob(id)
+}
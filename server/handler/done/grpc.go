@@ -3,7 +3,6 @@ package done
 import (
 	"context"
 
-	"github.com/joshjms/castletown/job"
 	pb "github.com/joshjms/castletown/proto"
 )
 
@@ -16,8 +15,7 @@ func NewDoneServer() *DoneServer {
 }
 
 func (s *DoneServer) Done(ctx context.Context, req *pb.DoneRequest) (*pb.DoneResponse, error) {
-	jp := job.GetJobPool()
-	jp.RemoveJob(req.Id)
+	Release(ctx, req.Id)
 
 	return &pb.DoneResponse{}, nil
 }
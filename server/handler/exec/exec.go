@@ -3,12 +3,16 @@ package exec
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/joshjms/castletown/auth"
 	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/quota"
 	"github.com/joshjms/castletown/sandbox"
+	"github.com/joshjms/castletown/tracing"
 )
 
 func Handler(w http.ResponseWriter, r *http.Request) {
@@ -28,9 +32,10 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		req.ID = uuid.NewString()
 	}
 
-	reports, err := handleRequest(r.Context(), req)
+	reports, err := Run(r.Context(), req)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("error running processes: %v", err), http.StatusInternalServerError)
+		quota.SetRetryAfter(w, err)
+		http.Error(w, fmt.Sprintf("error running processes: %v", err), HTTPStatus(err))
 		return
 	}
 
@@ -49,24 +54,68 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	w.Write(responseJson)
 }
 
-func handleRequest(ctx context.Context, req Request) ([]sandbox.Report, error) {
-	j := job.Job{
-		ID:    req.ID,
-		Files: req.Files,
-		Procs: req.Procs,
+// HTTPStatus returns the HTTP status of an error returned by Run: jobs the
+// client got wrong are not the server's failures.
+func HTTPStatus(err error) int {
+	switch {
+	case errors.As(err, new(*job.InvalidError)):
+		return http.StatusBadRequest
+	case errors.Is(err, job.ErrNotOwner):
+		return http.StatusForbidden
+	case errors.As(err, new(*quota.Error)):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
 	}
+}
 
-	jp := job.GetJobPool()
-	_job := jp.AddOrAppendJob(&j)
+// Run prepares the job req describes and runs all of its steps. A job over
+// the limits of its principal fails with a *quota.Error, an invalid one with
+// a *job.InvalidError and one appending to the job of another principal with
+// job.ErrNotOwner.
+func Run(ctx context.Context, req Request) (_ []sandbox.Report, err error) {
+	ctx, span := tracing.Start(ctx, "exec.Run", tracing.KEY_JOB_ID.String(req.ID))
+	defer func() { tracing.End(span, err) }()
 
-	if err := _job.Prepare(); err != nil {
-		return nil, fmt.Errorf("error preparing job: %w", err)
+	jobDone, err := quota.StartJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_job, err := prepareJob(ctx, req)
+	if err != nil {
+		jobDone(0)
+		return nil, err
 	}
 
 	reports, err := _job.ExecuteAll(ctx)
+	jobDone(quota.CPUTime(reports))
 	if err != nil {
 		return nil, fmt.Errorf("error executing job: %w", err)
 	}
 
 	return reports, nil
 }
+
+// prepareJob adds the request to the job pool, appending it to the job of
+// the same ID if there is one, and prepares the job to run. The job belongs
+// to the principal ctx carries.
+func prepareJob(ctx context.Context, req Request) (*job.Job, error) {
+	j := job.Job{
+		ID:        req.ID,
+		Files:     req.Files,
+		Procs:     req.Procs,
+		Principal: auth.Name(ctx),
+	}
+
+	jp := job.GetJobPool()
+	_job, err := jp.AddOrAppendJob(&j)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := _job.Prepare(ctx); err != nil {
+		return nil, fmt.Errorf("error preparing job: %w", err)
+	}
+	return _job, nil
+}
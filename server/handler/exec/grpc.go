@@ -2,11 +2,15 @@ package exec
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/joshjms/castletown/job"
 	pb "github.com/joshjms/castletown/proto"
+	"github.com/joshjms/castletown/quota"
 	"github.com/joshjms/castletown/sandbox"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ExecServer struct {
@@ -18,57 +22,175 @@ func NewExecServer() *ExecServer {
 }
 
 func (s *ExecServer) Execute(ctx context.Context, req *pb.ExecRequest) (*pb.ExecResponse, error) {
+	apiReq := convertFromProtoRequest(req)
+
+	reports, err := Run(ctx, apiReq)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	protoReports := make([]*pb.Report, len(reports))
+	for i, r := range reports {
+		protoReports[i] = ConvertToProtoReport(r)
+	}
+
+	return &pb.ExecResponse{
+		Id:      apiReq.ID,
+		Reports: protoReports,
+	}, nil
+}
+
+// ExecuteStream runs a job like Execute, sending its events as they happen.
+// The stream ends after the job done event; a step that could not be run is
+// reported in that event rather than as an error.
+func (s *ExecServer) ExecuteStream(req *pb.ExecRequest, stream pb.ExecService_ExecuteStreamServer) error {
+	ctx := stream.Context()
+	apiReq := convertFromProtoRequest(req)
+
+	// Subscribe before the job runs so that no event is missed.
+	sub := job.Subscribe(apiReq.ID, false)
+	defer sub.Close()
+
+	jobDone, err := quota.StartJob(ctx)
+	if err != nil {
+		return err
+	}
+
+	j, err := prepareJob(ctx, apiReq)
+	if err != nil {
+		jobDone(0)
+		return grpcError(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reports, _ := j.ExecuteAll(ctx)
+		jobDone(quota.CPUTime(reports))
+	}()
+
+	for {
+		ev, err := sub.Next(ctx)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(ConvertToProtoEvent(ev)); err != nil {
+			return err
+		}
+		if ev.Type == job.EVENT_JOB_DONE {
+			break
+		}
+	}
+
+	<-done
+	return nil
+}
+
+func grpcError(err error) error {
+	switch {
+	case errors.As(err, new(*job.InvalidError)):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, job.ErrNotOwner):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, new(*quota.Error)):
+		// Carries its own status.
+		return err
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func convertFromProtoRequest(req *pb.ExecRequest) Request {
 	id := req.Id
 	if id == "" {
 		id = uuid.NewString()
 	}
 
-	files := make([]job.File, len(req.Files))
-	for i, f := range req.Files {
+	procs := make([]job.Process, len(req.Procs))
+	for i, p := range req.Procs {
+		procs[i] = ConvertFromProtoProcess(p)
+	}
+
+	return Request{
+		ID:    id,
+		Files: ConvertFromProtoFiles(req.Files),
+		Procs: procs,
+	}
+}
+
+// ConvertFromProtoFiles converts files received over gRPC to job files.
+func ConvertFromProtoFiles(pbFiles []*pb.File) []job.File {
+	files := make([]job.File, len(pbFiles))
+	for i, f := range pbFiles {
 		files[i] = job.File{
 			Name:    f.Name,
 			Content: f.Content,
+			Type:    convertFromProtoFileType(f.Type),
+			Mode:    f.Mode,
 		}
 	}
+	return files
+}
 
-	procs := make([]job.Process, len(req.Procs))
-	for i, p := range req.Procs {
-		procs[i] = job.Process{
-			Image:         p.Image,
-			Cmd:           p.Cmd,
-			Stdin:         p.Stdin,
-			MemoryLimitMB: p.MemoryLimitMb,
-			TimeLimitMs:   p.TimeLimitMs,
-			ProcLimit:     p.ProcLimit,
-			Files:         p.Files,
-			Persist:       p.Persist,
-		}
+// ConvertFromProtoProcess converts a process received over gRPC, including
+// its interactor and services.
+func ConvertFromProtoProcess(p *pb.Process) job.Process {
+	proc := job.Process{
+		Name:          p.Name,
+		Image:         p.Image,
+		Cmd:           p.Cmd,
+		Stdin:         p.Stdin,
+		MemoryLimitMB: p.MemoryLimitMb,
+		TimeLimitMs:   p.TimeLimitMs,
+		ProcLimit:     p.ProcLimit,
+		Files:         p.Files,
+		Persist:       p.Persist,
+		Outputs:       p.Outputs,
+
+		StdinFile:       p.StdinFile,
+		StdinFrom:       p.StdinFrom,
+		StdoutFile:      p.StdoutFile,
+		StderrFile:      p.StderrFile,
+		FileSizeLimitMB: p.FileSizeLimitMb,
+
+		Env:        p.Env,
+		WorkingDir: p.WorkingDir,
+		User:       p.User,
 	}
 
-	apiReq := Request{
-		ID:    id,
-		Files: files,
-		Procs: procs,
+	if p.Interactor != nil {
+		interactor := ConvertFromProtoProcess(p.Interactor)
+		proc.Interactor = &interactor
 	}
 
-	reports, err := handleRequest(ctx, apiReq)
-	if err != nil {
-		return nil, err
+	for _, svc := range p.Services {
+		proc.Services = append(proc.Services, ConvertFromProtoProcess(svc))
 	}
 
-	protoReports := make([]*pb.Report, len(reports))
-	for i, r := range reports {
-		protoReports[i] = convertToProtoReport(r)
+	if p.Readiness != nil {
+		proc.Readiness = &job.Readiness{
+			TCPPort:   int(p.Readiness.TcpPort),
+			TimeoutMs: p.Readiness.TimeoutMs,
+		}
 	}
 
-	return &pb.ExecResponse{
-		Id:      id,
-		Reports: protoReports,
-	}, nil
+	return proc
 }
 
-func convertToProtoReport(r sandbox.Report) *pb.Report {
-	return &pb.Report{
+// ConvertToProtoReport converts a report, including the reports of an
+// interactor and services, for sending over gRPC.
+func ConvertToProtoReport(r sandbox.Report) *pb.Report {
+	artifacts := make([]*pb.Artifact, len(r.Artifacts))
+	for i, a := range r.Artifacts {
+		artifacts[i] = &pb.Artifact{
+			Path:    a.Path,
+			Content: a.Content,
+			Size:    a.Size,
+			Omitted: a.Omitted,
+		}
+	}
+
+	report := &pb.Report{
 		Status:   convertToProtoStatus(r.Status),
 		ExitCode: int32(r.ExitCode),
 		Signal:   int32(r.Signal),
@@ -79,6 +201,75 @@ func convertToProtoReport(r sandbox.Report) *pb.Report {
 		WallTime: r.WallTime,
 		StartAt:  r.StartAt.UnixNano(),
 		FinishAt: r.FinishAt.UnixNano(),
+
+		StdoutTruncated: r.StdoutTruncated,
+		StderrTruncated: r.StderrTruncated,
+
+		Artifacts: artifacts,
+		Verdict:   convertToProtoVerdict(r.Verdict),
+	}
+
+	if r.Interactor != nil {
+		report.Interactor = ConvertToProtoReport(*r.Interactor)
+	}
+
+	for _, svc := range r.Services {
+		report.Services = append(report.Services, ConvertToProtoReport(svc))
+	}
+
+	return report
+}
+
+// ConvertToProtoEvent converts a job event for sending over gRPC.
+func ConvertToProtoEvent(ev job.Event) *pb.ExecEvent {
+	event := &pb.ExecEvent{
+		Type:  convertToProtoEventType(ev.Type),
+		Id:    ev.JobID,
+		Step:  int32(ev.Step),
+		Steps: int32(ev.Steps),
+		Error: ev.Error,
+		Time:  ev.Time.UnixNano(),
+	}
+	if ev.Report != nil {
+		event.Report = ConvertToProtoReport(*ev.Report)
+	}
+	if ev.Output != nil {
+		event.Output = &pb.OutputChunk{
+			Stream:  ev.Output.Stream,
+			Data:    ev.Output.Data,
+			Dropped: ev.Output.Dropped,
+		}
+	}
+	return event
+}
+
+func convertToProtoEventType(t job.EventType) pb.ExecEventType {
+	switch t {
+	case job.EVENT_JOB_ACCEPTED:
+		return pb.ExecEventType_EXEC_EVENT_JOB_ACCEPTED
+	case job.EVENT_STEP_STARTED:
+		return pb.ExecEventType_EXEC_EVENT_STEP_STARTED
+	case job.EVENT_STEP_FINISHED:
+		return pb.ExecEventType_EXEC_EVENT_STEP_FINISHED
+	case job.EVENT_JOB_DONE:
+		return pb.ExecEventType_EXEC_EVENT_JOB_DONE
+	case job.EVENT_OUTPUT:
+		return pb.ExecEventType_EXEC_EVENT_OUTPUT
+	default:
+		return pb.ExecEventType_EXEC_EVENT_UNSPECIFIED
+	}
+}
+
+func convertFromProtoFileType(t pb.FileType) job.FileType {
+	switch t {
+	case pb.FileType_FILE_TYPE_DIR:
+		return job.FILE_TYPE_DIR
+	case pb.FileType_FILE_TYPE_TAR:
+		return job.FILE_TYPE_TAR
+	case pb.FileType_FILE_TYPE_ZIP:
+		return job.FILE_TYPE_ZIP
+	default:
+		return job.FILE_TYPE_REGULAR
 	}
 }
 
@@ -102,3 +293,26 @@ func convertToProtoStatus(status sandbox.Status) pb.Status {
 		return pb.Status_STATUS_UNKNOWN
 	}
 }
+
+func convertToProtoVerdict(verdict sandbox.Verdict) pb.Verdict {
+	switch verdict {
+	case sandbox.VERDICT_ACCEPTED:
+		return pb.Verdict_VERDICT_ACCEPTED
+	case sandbox.VERDICT_WRONG_ANSWER:
+		return pb.Verdict_VERDICT_WRONG_ANSWER
+	case sandbox.VERDICT_PRESENTATION_ERROR:
+		return pb.Verdict_VERDICT_PRESENTATION_ERROR
+	case sandbox.VERDICT_TIME_LIMIT_EXCEEDED:
+		return pb.Verdict_VERDICT_TIME_LIMIT_EXCEEDED
+	case sandbox.VERDICT_MEMORY_LIMIT_EXCEEDED:
+		return pb.Verdict_VERDICT_MEMORY_LIMIT_EXCEEDED
+	case sandbox.VERDICT_OUTPUT_LIMIT_EXCEEDED:
+		return pb.Verdict_VERDICT_OUTPUT_LIMIT_EXCEEDED
+	case sandbox.VERDICT_RUNTIME_ERROR:
+		return pb.Verdict_VERDICT_RUNTIME_ERROR
+	case sandbox.VERDICT_JUDGE_ERROR:
+		return pb.Verdict_VERDICT_JUDGE_ERROR
+	default:
+		return pb.Verdict_VERDICT_UNSPECIFIED
+	}
+}
@@ -0,0 +1,24 @@
+package gc
+
+type CollectRequest struct {
+	DryRun bool `json:"dryRun"`
+}
+
+// CollectResponse lists what a collection removed, or would have with
+// DryRun.
+type CollectResponse struct {
+	Layers   []string `json:"layers"`
+	Overlays []string `json:"overlays"`
+	Storage  []string `json:"storage"`
+	Freed    int64    `json:"freed"`
+}
+
+// DiskUsageResponse is the disk space taken by each kind of data in bytes.
+// Reclaimable is what a collection would free now.
+type DiskUsageResponse struct {
+	Images      int64 `json:"images"`
+	Layers      int64 `json:"layers"`
+	Overlays    int64 `json:"overlays"`
+	Storage     int64 `json:"storage"`
+	Reclaimable int64 `json:"reclaimable"`
+}
@@ -0,0 +1,160 @@
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/joshjms/castletown/auth"
+	"github.com/joshjms/castletown/config"
+	collector "github.com/joshjms/castletown/gc"
+	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/sandbox"
+)
+
+var errForbidden = errors.New("not allowed")
+
+// Handler runs a collection: POST /gc.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := Authorize(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req CollectRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid json: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	res, err := Collect(req.DryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, CollectResponse{
+		Layers:   nonNil(res.Layers),
+		Overlays: nonNil(res.Overlays),
+		Storage:  nonNil(res.Storage),
+		Freed:    res.Freed,
+	})
+}
+
+// DiskUsageHandler reports the disk space taken by each kind of data: GET
+// /disk-usage.
+func DiskUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := Authorize(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	resp, err := DiskUsage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// Authorize rejects principals config.AdminPrincipals does not list.
+// Collections touch the data of every principal and name the jobs of all of
+// them.
+func Authorize(ctx context.Context) error {
+	if !auth.Listed(ctx, config.AdminPrincipals) {
+		return fmt.Errorf("%w: %q may not collect garbage", errForbidden, auth.Name(ctx))
+	}
+	return nil
+}
+
+// Collect removes what no image, sandbox, build or job uses any more and
+// has not changed within config.GCMinAge.
+func Collect(dryRun bool) (*collector.Result, error) {
+	var sandboxIDs []string
+	if m := sandbox.GetManager(); m != nil {
+		sandboxIDs = m.SandboxIDs()
+	}
+	sandboxInUse := map[string]bool{}
+	for _, id := range sandboxIDs {
+		sandboxInUse[id] = true
+	}
+
+	return collector.Collect(collector.Options{
+		MinAge: config.GCMinAge,
+		DryRun: dryRun,
+		SandboxInUse: func(id string) bool {
+			return sandboxInUse[id]
+		},
+		BuildInUse: job.IsBuilding,
+		JobInUse: func(id string) bool {
+			if jp := job.GetJobPool(); jp != nil {
+				if _, ok := jp.GetJob(id); ok {
+					return true
+				}
+			}
+			if job.IsBuilding(id) {
+				return true
+			}
+			// Steps and sessions run in sandboxes named after their job.
+			for _, sandboxID := range sandboxIDs {
+				if strings.HasPrefix(sandboxID, id+"-") {
+					return true
+				}
+			}
+			return false
+		},
+	})
+}
+
+// DiskUsage measures the disk space taken by each kind of data and what a
+// collection would free now.
+func DiskUsage() (DiskUsageResponse, error) {
+	usage, err := collector.GetUsage()
+	if err != nil {
+		return DiskUsageResponse{}, fmt.Errorf("error measuring disk usage: %w", err)
+	}
+	res, err := Collect(true)
+	if err != nil {
+		return DiskUsageResponse{}, fmt.Errorf("error measuring reclaimable space: %w", err)
+	}
+
+	return DiskUsageResponse{
+		Images:      usage.Images,
+		Layers:      usage.Layers,
+		Overlays:    usage.Overlays,
+		Storage:     usage.Storage,
+		Reclaimable: res.Freed,
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
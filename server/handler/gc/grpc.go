@@ -0,0 +1,54 @@
+package gc
+
+import (
+	"context"
+
+	pb "github.com/joshjms/castletown/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type GCServer struct {
+	pb.UnimplementedGCServiceServer
+}
+
+func NewGCServer() *GCServer {
+	return &GCServer{}
+}
+
+func (s *GCServer) Collect(ctx context.Context, req *pb.CollectRequest) (*pb.CollectResponse, error) {
+	if err := Authorize(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	res, err := Collect(req.DryRun)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.CollectResponse{
+		Layers:   res.Layers,
+		Overlays: res.Overlays,
+		Storage:  res.Storage,
+		Freed:    res.Freed,
+	}, nil
+}
+
+func (s *GCServer) DiskUsage(ctx context.Context, req *pb.DiskUsageRequest) (*pb.DiskUsageResponse, error) {
+	if err := Authorize(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	usage, err := DiskUsage()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.DiskUsageResponse{
+		Images:      usage.Images,
+		Layers:      usage.Layers,
+		Overlays:    usage.Overlays,
+		Storage:     usage.Storage,
+		Reclaimable: usage.Reclaimable,
+	}, nil
+}
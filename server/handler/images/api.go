@@ -0,0 +1,38 @@
+package images
+
+import (
+	"time"
+
+	"github.com/joshjms/castletown/image"
+	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/sandbox"
+)
+
+type Image struct {
+	Name       string       `json:"name"`
+	Digest     string       `json:"digest"`
+	Size       int64        `json:"size"`
+	ImportedAt time.Time    `json:"importedAt"`
+	InUse      bool         `json:"inUse"`
+	Layers     []string     `json:"layers"`
+	Config     image.Config `json:"config"`
+}
+
+type ListResponse struct {
+	Images []Image `json:"images"`
+}
+
+// BuildRequest builds the image named in the path from Base.
+type BuildRequest struct {
+	Base  string        `json:"base"`
+	Files []job.File    `json:"files"`
+	Procs []job.Process `json:"steps"`
+}
+
+// BuildResponse has Image set on success and Error when a step failed.
+type BuildResponse struct {
+	ID      string           `json:"id"`
+	Image   *Image           `json:"image,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Reports []sandbox.Report `json:"reports"`
+}
@@ -0,0 +1,117 @@
+package images
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joshjms/castletown/job"
+	pb "github.com/joshjms/castletown/proto"
+	"github.com/joshjms/castletown/quota"
+	"github.com/joshjms/castletown/server/handler/exec"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ImageServer struct {
+	pb.UnimplementedImageServiceServer
+}
+
+func NewImageServer() *ImageServer {
+	return &ImageServer{}
+}
+
+func (s *ImageServer) ListImages(ctx context.Context, req *pb.ListImagesRequest) (*pb.ListImagesResponse, error) {
+	images, err := List()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &pb.ListImagesResponse{}
+	for _, img := range images {
+		resp.Images = append(resp.Images, convertToProtoImage(img))
+	}
+	return resp, nil
+}
+
+func (s *ImageServer) GetImage(ctx context.Context, req *pb.GetImageRequest) (*pb.GetImageResponse, error) {
+	img, err := Get(req.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.GetImageResponse{Image: convertToProtoImage(img)}, nil
+}
+
+func (s *ImageServer) DeleteImage(ctx context.Context, req *pb.DeleteImageRequest) (*pb.DeleteImageResponse, error) {
+	if err := Remove(ctx, req.Name); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.DeleteImageResponse{}, nil
+}
+
+func (s *ImageServer) BuildImage(ctx context.Context, req *pb.BuildImageRequest) (*pb.BuildImageResponse, error) {
+	procs := make([]job.Process, len(req.Procs))
+	for i, p := range req.Procs {
+		procs[i] = exec.ConvertFromProtoProcess(p)
+	}
+
+	resp, err := Build(ctx, req.Name, BuildRequest{
+		Base:  req.Base,
+		Files: exec.ConvertFromProtoFiles(req.Files),
+		Procs: procs,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	pbResp := &pb.BuildImageResponse{Id: resp.ID, Error: resp.Error}
+	if resp.Image != nil {
+		pbResp.Image = convertToProtoImage(*resp.Image)
+	}
+	for _, r := range resp.Reports {
+		pbResp.Reports = append(pbResp.Reports, exec.ConvertToProtoReport(r))
+	}
+	return pbResp, nil
+}
+
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, errInvalidName):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, errForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, errNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errInUse):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, errExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.As(err, new(*quota.Error)):
+		// Carries its own status.
+		return err
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func convertToProtoImage(img Image) *pb.Image {
+	var importedAt int64
+	if !img.ImportedAt.IsZero() {
+		importedAt = img.ImportedAt.UnixNano()
+	}
+
+	return &pb.Image{
+		Name:       img.Name,
+		Digest:     img.Digest,
+		Size:       img.Size,
+		ImportedAt: importedAt,
+		InUse:      img.InUse,
+		Layers:     img.Layers,
+		Config: &pb.ImageConfig{
+			User:       img.Config.User,
+			Env:        img.Config.Env,
+			Entrypoint: img.Config.Entrypoint,
+			Cmd:        img.Config.Cmd,
+			WorkingDir: img.Config.WorkingDir,
+		},
+	}
+}
@@ -0,0 +1,238 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/joshjms/castletown/auth"
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/image"
+	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/quota"
+	"github.com/joshjms/castletown/sandbox"
+)
+
+var (
+	errInvalidName = errors.New("invalid image name")
+	errNotFound    = errors.New("image not found")
+	errInUse       = errors.New("image is in use")
+	errExists      = errors.New("image already exists")
+	errForbidden   = errors.New("not allowed")
+)
+
+// ListHandler lists the images available to jobs: GET /images.
+func ListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	images, err := List()
+	if err != nil {
+		http.Error(w, err.Error(), HTTPStatus(err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListResponse{Images: images})
+}
+
+// Handler inspects, removes or builds a single image: GET, DELETE or POST
+// /images/{name}. A build whose steps fail answers 422 with their reports.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		img, err := Get(name)
+		if err != nil {
+			http.Error(w, err.Error(), HTTPStatus(err))
+			return
+		}
+		writeJSON(w, http.StatusOK, img)
+
+	case http.MethodDelete:
+		if err := Remove(r.Context(), name); err != nil {
+			http.Error(w, err.Error(), HTTPStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"ok"}`))
+
+	case http.MethodPost:
+		var req BuildRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid json: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		resp, err := Build(r.Context(), name, req)
+		if err != nil {
+			quota.SetRetryAfter(w, err)
+			http.Error(w, err.Error(), HTTPStatus(err))
+			return
+		}
+		status := http.StatusOK
+		if resp.Error != "" {
+			status = http.StatusUnprocessableEntity
+		}
+		writeJSON(w, status, resp)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// HTTPStatus returns the HTTP status of an error returned by the functions
+// of this package.
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidName):
+		return http.StatusBadRequest
+	case errors.Is(err, errForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errInUse), errors.Is(err, errExists):
+		return http.StatusConflict
+	case errors.As(err, new(*quota.Error)):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// List lists the images available to jobs.
+func List() ([]Image, error) {
+	imgs, err := image.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing images: %w", err)
+	}
+
+	images := make([]Image, len(imgs))
+	for i, img := range imgs {
+		images[i] = toAPIImage(img)
+	}
+	return images, nil
+}
+
+// Get inspects the image called name.
+func Get(name string) (Image, error) {
+	if err := image.ValidateName(name); err != nil {
+		return Image{}, fmt.Errorf("%w: %q", errInvalidName, name)
+	}
+
+	img, err := image.Get(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Image{}, fmt.Errorf("%w: %q", errNotFound, name)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	return toAPIImage(img), nil
+}
+
+// Remove deletes an image unless a sandbox is running on it. A job that
+// starts between the check and the removal fails like one naming a missing
+// image. Jobs of every principal share images, so only the principals
+// config.AdminPrincipals lists may remove them.
+func Remove(ctx context.Context, name string) error {
+	if !auth.Listed(ctx, config.AdminPrincipals) {
+		return fmt.Errorf("%w: %q may not remove images", errForbidden, auth.Name(ctx))
+	}
+
+	img, err := Get(name)
+	if err != nil {
+		return err
+	}
+	if img.InUse {
+		return fmt.Errorf("%w: %q", errInUse, name)
+	}
+
+	return image.Delete(name)
+}
+
+// Build runs a build of the image called name from req. Steps that fail are
+// reported in the response, not as an error, so their output is not lost.
+// Only the principals config.BuildPrincipals lists may build; the build gets
+// an ID of its own, so that it cannot share the storage of a job.
+func Build(ctx context.Context, name string, req BuildRequest) (BuildResponse, error) {
+	// Builds run as the owner of every image's files and with the host's
+	// network.
+	if !auth.Listed(ctx, config.BuildPrincipals) {
+		return BuildResponse{}, fmt.Errorf("%w: %q may not build images", errForbidden, auth.Name(ctx))
+	}
+	if err := image.ValidateName(name); err != nil {
+		return BuildResponse{}, fmt.Errorf("%w: %q", errInvalidName, name)
+	}
+	if _, err := image.Get(name); err == nil {
+		return BuildResponse{}, fmt.Errorf("%w: %q", errExists, name)
+	}
+	if _, err := Get(req.Base); err != nil {
+		return BuildResponse{}, err
+	}
+
+	b := job.Build{
+		ID:        "build-" + uuid.NewString(),
+		Name:      name,
+		Base:      req.Base,
+		Files:     req.Files,
+		Procs:     req.Procs,
+		Principal: auth.Name(ctx),
+	}
+
+	jobDone, err := quota.StartJob(ctx)
+	if err != nil {
+		return BuildResponse{}, err
+	}
+	img, reports, err := b.Run(ctx)
+	jobDone(quota.CPUTime(reports))
+	if err != nil && reports == nil {
+		return BuildResponse{}, fmt.Errorf("error building image: %w", err)
+	}
+
+	resp := BuildResponse{ID: b.ID, Reports: reports}
+	if err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+
+	apiImage := toAPIImage(img)
+	resp.Image = &apiImage
+	return resp, nil
+}
+
+func toAPIImage(img *image.Image) Image {
+	return Image{
+		Name:       img.Name,
+		Digest:     img.Digest,
+		Size:       img.Size,
+		ImportedAt: img.ImportedAt,
+		InUse:      inUse(img.Name),
+		Layers:     img.Layers,
+		Config:     img.Config,
+	}
+}
+
+func inUse(name string) bool {
+	m := sandbox.GetManager()
+	return m != nil && m.RootfsInUse(image.Dir(name))
+}
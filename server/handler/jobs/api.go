@@ -0,0 +1,10 @@
+package jobs
+
+// OutputEvent is the data of an output event. Data is base64-encoded on the
+// wire, like file contents.
+type OutputEvent struct {
+	Step    int    `json:"step"`
+	Stream  string `json:"stream"`
+	Data    []byte `json:"data"`
+	Dropped int64  `json:"dropped,omitempty"`
+}
@@ -0,0 +1,206 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshjms/castletown/auth"
+	"github.com/joshjms/castletown/job"
+	"golang.org/x/net/websocket"
+)
+
+// KEEPALIVE_INTERVAL is how often an idle event stream gets a comment, so
+// that proxies do not time it out.
+const KEEPALIVE_INTERVAL = 15 * time.Second
+
+// OutputHandler streams the output of a job's steps as they run, as
+// Server-Sent Events: GET /jobs/{id}/output. Each output event holds an
+// OutputEvent; a done event ends the stream once the job is done. A job
+// that has not started yet is waited for, while one that is already done
+// ends the stream at once.
+func OutputHandler(w http.ResponseWriter, r *http.Request) {
+	ServeOutput(w, r, http.Error)
+}
+
+// ServeOutput serves the output of the job named in the path like
+// OutputHandler, reporting errors with fail.
+func ServeOutput(w http.ResponseWriter, r *http.Request, fail func(http.ResponseWriter, string, int)) {
+	if r.Method != http.MethodGet {
+		fail(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sub := job.Subscribe(r.PathValue("id"), true)
+	defer sub.Close()
+
+	stream, err := newEventStream(w)
+	if err != nil {
+		fail(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serveEvents(r.Context(), true, func(ctx context.Context) (job.Event, error) {
+		return stream.next(ctx, sub)
+	}, func(ev job.Event) error {
+		switch ev.Type {
+		case job.EVENT_OUTPUT:
+			return stream.send("output", OutputEvent{
+				Step:    ev.Step,
+				Stream:  ev.Output.Stream,
+				Data:    ev.Output.Data,
+				Dropped: ev.Output.Dropped,
+			})
+		case job.EVENT_JOB_DONE:
+			return stream.send("done", struct{}{})
+		}
+		return nil
+	})
+}
+
+// EventsHandler streams the events of a job as it runs: GET
+// /jobs/{id}/events. Events are sent as Server-Sent Events named after their
+// type, with the event as data, or as JSON messages to clients that ask for
+// a WebSocket. The events of the job's current or latest run come first,
+// unless replay=false, and the stream ends after the job done event. Output
+// events are only sent with output=true.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	ServeEvents(w, r, func(ev job.Event) any {
+		return ev
+	}, http.Error)
+}
+
+// ServeEvents serves the events of the job named in the path like
+// EventsHandler, sending convert(ev) for each event and reporting errors
+// with fail.
+func ServeEvents(w http.ResponseWriter, r *http.Request, convert func(job.Event) any, fail func(http.ResponseWriter, string, int)) {
+	if r.Method != http.MethodGet {
+		fail(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	output, err := parseBool(query.Get("output"), false)
+	if err != nil {
+		fail(w, fmt.Sprintf("invalid output: %v", err), http.StatusBadRequest)
+		return
+	}
+	replay, err := parseBool(query.Get("replay"), true)
+	if err != nil {
+		fail(w, fmt.Sprintf("invalid replay: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	sub := job.Subscribe(r.PathValue("id"), replay)
+	defer sub.Close()
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		websocket.Server{Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// The client sends nothing; reading only notices it leaving.
+			ctx, cancel := context.WithCancel(ws.Request().Context())
+			defer cancel()
+			go func() {
+				io.Copy(io.Discard, ws)
+				cancel()
+			}()
+
+			serveEvents(ctx, output, sub.Next, func(ev job.Event) error {
+				return websocket.JSON.Send(ws, convert(ev))
+			})
+		}}.ServeHTTP(w, r)
+		return
+	}
+
+	stream, err := newEventStream(w)
+	if err != nil {
+		fail(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serveEvents(r.Context(), output, func(ctx context.Context) (job.Event, error) {
+		return stream.next(ctx, sub)
+	}, func(ev job.Event) error {
+		return stream.send(string(ev.Type), convert(ev))
+	})
+}
+
+// serveEvents sends the events next returns until the job is done or send
+// fails. Events of jobs of other principals than the one ctx carries are
+// left out.
+func serveEvents(ctx context.Context, output bool, next func(context.Context) (job.Event, error), send func(job.Event) error) {
+	for {
+		ev, err := next(ctx)
+		if err != nil {
+			return
+		}
+		if ev.Type == job.EVENT_OUTPUT && !output || !auth.Owns(ctx, ev.Principal) {
+			continue
+		}
+		if err := send(ev); err != nil || ev.Type == job.EVENT_JOB_DONE {
+			return
+		}
+	}
+}
+
+func parseBool(s string, def bool) (bool, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+// eventStream writes Server-Sent Events.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming is not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// next waits for the next event of sub, keeping the stream alive meanwhile.
+func (s *eventStream) next(ctx context.Context, sub *job.Subscription) (job.Event, error) {
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, KEEPALIVE_INTERVAL)
+		ev, err := sub.Next(waitCtx)
+		cancel()
+
+		if err == nil || ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+			return ev, err
+		}
+		if _, err := fmt.Fprint(s.w, ": keepalive\n\n"); err != nil {
+			return job.Event{}, err
+		}
+		s.flusher.Flush()
+	}
+}
+
+func (s *eventStream) send(name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, b); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,42 @@
+package session
+
+import (
+	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/sandbox"
+)
+
+// Start is the first message a client sends on a session. The process' time
+// limit is the CPU budget of the whole session.
+type Start struct {
+	Files         []job.File  `json:"files"`
+	Process       job.Process `json:"process"`
+	IdleTimeoutMs int64       `json:"idleTimeoutMs"`
+
+	// Terminal runs the process on a pseudo-terminal of this size. Stderr
+	// is then merged into stdout.
+	Terminal *TerminalSize `json:"terminal,omitempty"`
+}
+
+type TerminalSize struct {
+	Cols uint16 `json:"cols"`
+	Rows uint16 `json:"rows"`
+}
+
+// Input is sent by the client after Start.
+type Input struct {
+	Stdin      []byte `json:"stdin,omitempty"`
+	CloseStdin bool   `json:"closeStdin,omitempty"`
+
+	// Resize changes the size of the session's terminal.
+	Resize *TerminalSize `json:"resize,omitempty"`
+}
+
+// Event is sent to the client. The first event carries the session ID and
+// the last one either the exit report or an error.
+type Event struct {
+	ID     string          `json:"id,omitempty"`
+	Stdout []byte          `json:"stdout,omitempty"`
+	Stderr []byte          `json:"stderr,omitempty"`
+	Exit   *sandbox.Report `json:"exit,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
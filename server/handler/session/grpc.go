@@ -0,0 +1,72 @@
+package session
+
+import (
+	pb "github.com/joshjms/castletown/proto"
+	"github.com/joshjms/castletown/server/handler/exec"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type SessionServer struct {
+	pb.UnimplementedSessionServiceServer
+}
+
+func NewSessionServer() *SessionServer {
+	return &SessionServer{}
+}
+
+func (s *SessionServer) Session(stream grpc.BidiStreamingServer[pb.SessionRequest, pb.SessionResponse]) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	pbStart := req.GetStart()
+	if pbStart == nil || pbStart.Process == nil {
+		return status.Error(codes.InvalidArgument, "first message must start the session")
+	}
+
+	start := Start{
+		Files:         exec.ConvertFromProtoFiles(pbStart.Files),
+		Process:       exec.ConvertFromProtoProcess(pbStart.Process),
+		IdleTimeoutMs: pbStart.IdleTimeoutMs,
+		Terminal:      convertFromProtoTerminalSize(pbStart.Terminal),
+	}
+
+	recv := func() (Input, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			return Input{}, err
+		}
+		return Input{
+			Stdin:      req.GetStdin(),
+			CloseStdin: req.GetCloseStdin(),
+			Resize:     convertFromProtoTerminalSize(req.GetResize()),
+		}, nil
+	}
+
+	send := func(e Event) error {
+		resp := &pb.SessionResponse{}
+		switch {
+		case e.ID != "":
+			resp.Msg = &pb.SessionResponse_Id{Id: e.ID}
+		case e.Stdout != nil:
+			resp.Msg = &pb.SessionResponse_Stdout{Stdout: e.Stdout}
+		case e.Stderr != nil:
+			resp.Msg = &pb.SessionResponse_Stderr{Stderr: e.Stderr}
+		case e.Exit != nil:
+			resp.Msg = &pb.SessionResponse_Exit{Exit: exec.ConvertToProtoReport(*e.Exit)}
+		}
+		return stream.Send(resp)
+	}
+
+	return serve(stream.Context(), start, recv, send)
+}
+
+func convertFromProtoTerminalSize(t *pb.TerminalSize) *TerminalSize {
+	if t == nil {
+		return nil
+	}
+	return &TerminalSize{Cols: uint16(t.Cols), Rows: uint16(t.Rows)}
+}
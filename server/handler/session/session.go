@@ -0,0 +1,189 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joshjms/castletown/auth"
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/quota"
+	"github.com/joshjms/castletown/sandbox"
+	"golang.org/x/net/websocket"
+)
+
+const chunkSize = 32 * 1024
+
+var errClientGone = errors.New("client disconnected")
+
+// Handler serves sessions over WebSocket. Messages are JSON: the client
+// sends a Start followed by Inputs and receives Events.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	ServeWebSocket(w, r, func(e Event) any {
+		return e
+	})
+}
+
+// ServeWebSocket serves a session like Handler, sending convert(e) for each
+// event.
+func ServeWebSocket(w http.ResponseWriter, r *http.Request, convert func(Event) any) {
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		serveWebSocket(ws, convert)
+	}}.ServeHTTP(w, r)
+}
+
+func serveWebSocket(ws *websocket.Conn, convert func(Event) any) {
+	defer ws.Close()
+
+	var start Start
+	if err := websocket.JSON.Receive(ws, &start); err != nil {
+		websocket.JSON.Send(ws, convert(Event{Error: fmt.Sprintf("invalid start message: %v", err)}))
+		return
+	}
+
+	recv := func() (Input, error) {
+		var in Input
+		err := websocket.JSON.Receive(ws, &in)
+		// Unlike a gRPC half-close, a closed connection means the client is
+		// gone rather than done with its input.
+		if err == io.EOF {
+			err = errClientGone
+		}
+		return in, err
+	}
+	send := func(e Event) error {
+		return websocket.JSON.Send(ws, convert(e))
+	}
+
+	if err := serve(ws.Request().Context(), start, recv, send); err != nil {
+		websocket.JSON.Send(ws, convert(Event{Error: err.Error()}))
+	}
+}
+
+// serve runs one session. recv returns the client's inputs, with io.EOF
+// once the client has no more input; send delivers events to the client and
+// is never called concurrently. The exit report is sent before serve
+// returns nil.
+func serve(ctx context.Context, start Start, recv func() (Input, error), send func(Event) error) error {
+	jobDone, err := quota.StartJob(ctx)
+	if err != nil {
+		return err
+	}
+	var cpuTime time.Duration
+	defer func() { jobDone(cpuTime) }()
+
+	j := &job.Job{
+		ID:        uuid.NewString(),
+		Files:     start.Files,
+		Procs:     []job.Process{start.Process},
+		Principal: auth.Name(ctx),
+	}
+
+	if err := j.Prepare(ctx); err != nil {
+		return fmt.Errorf("error preparing session: %w", err)
+	}
+
+	var terminal *sandbox.Terminal
+	if start.Terminal != nil {
+		terminal = &sandbox.Terminal{Width: start.Terminal.Cols, Height: start.Terminal.Rows}
+	}
+
+	sess, err := j.StartSession(ctx, getLimits(start), terminal)
+	if err != nil {
+		return fmt.Errorf("error starting session: %w", err)
+	}
+	defer func() {
+		sess.Close()
+		report, _ := sess.Wait()
+		cpuTime = quota.CPUTime([]sandbox.Report{report})
+	}()
+
+	var mu sync.Mutex
+	sendEvent := func(e Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return send(e)
+	}
+
+	if err := sendEvent(Event{ID: j.ID}); err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			in, err := recv()
+			if err == io.EOF {
+				sess.CloseStdin()
+				return
+			}
+			if err != nil {
+				sess.Kill()
+				return
+			}
+
+			if len(in.Stdin) > 0 {
+				// A process that closed its stdin just loses the input.
+				sess.Write(in.Stdin)
+			}
+			if in.CloseStdin {
+				sess.CloseStdin()
+			}
+			if in.Resize != nil {
+				// Sessions without a terminal ignore resizes.
+				sess.Resize(sandbox.Terminal{Width: in.Resize.Cols, Height: in.Resize.Rows})
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	pump := func(r io.Reader, event func([]byte) Event) {
+		defer wg.Done()
+
+		buf := make([]byte, chunkSize)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				chunk := append([]byte(nil), buf[:n]...)
+				if sendEvent(event(chunk)) != nil {
+					sess.Kill()
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	wg.Add(2)
+	go pump(sess.Stdout(), func(b []byte) Event { return Event{Stdout: b} })
+	go pump(sess.Stderr(), func(b []byte) Event { return Event{Stderr: b} })
+
+	report, err := sess.Wait()
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+
+	return sendEvent(Event{Exit: &report})
+}
+
+// getLimits lets clients shorten, but not extend, the server's idle
+// timeout.
+func getLimits(start Start) sandbox.SessionLimits {
+	limits := sandbox.SessionLimits{
+		IdleTimeout: config.SessionIdleTimeout,
+		MaxLifetime: config.SessionMaxLifetime,
+	}
+
+	if idle := time.Duration(start.IdleTimeoutMs) * time.Millisecond; idle > 0 && (limits.IdleTimeout == 0 || idle < limits.IdleTimeout) {
+		limits.IdleTimeout = idle
+	}
+
+	return limits
+}
@@ -0,0 +1,201 @@
+package v1
+
+import "time"
+
+// Error codes tell clients what went wrong without parsing messages.
+const (
+	ERROR_INVALID_REQUEST    = "INVALID_REQUEST"
+	ERROR_UNAUTHENTICATED    = "UNAUTHENTICATED"
+	ERROR_FORBIDDEN          = "FORBIDDEN"
+	ERROR_NOT_FOUND          = "NOT_FOUND"
+	ERROR_METHOD_NOT_ALLOWED = "METHOD_NOT_ALLOWED"
+	ERROR_CONFLICT           = "CONFLICT"
+	ERROR_RESOURCE_EXHAUSTED = "RESOURCE_EXHAUSTED"
+	ERROR_JOB_FAILED         = "JOB_FAILED"
+	ERROR_INTERNAL           = "INTERNAL"
+)
+
+// ErrorResponse is the body of every response that is not a success.
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// File is materialized in the box before the steps run. Content is
+// base64-encoded on the wire.
+type File struct {
+	Name    string `json:"name"`
+	Content []byte `json:"content"`
+	Type    string `json:"type,omitempty"`
+	Mode    uint32 `json:"mode,omitempty"`
+}
+
+type Process struct {
+	Name          string   `json:"name"`
+	Image         string   `json:"image"`
+	Cmd           []string `json:"cmd"`
+	Stdin         string   `json:"stdin,omitempty"`
+	MemoryLimitMB int64    `json:"memoryLimitMB"`
+	TimeLimitMs   uint64   `json:"timeLimitMs"`
+	ProcLimit     int64    `json:"procLimit"`
+	Files         []string `json:"files,omitempty"`
+	Persist       []string `json:"persist,omitempty"`
+	Outputs       []string `json:"outputs,omitempty"`
+
+	StdinFile       string `json:"stdinFile,omitempty"`
+	StdinFrom       string `json:"stdinFrom,omitempty"`
+	StdoutFile      string `json:"stdoutFile,omitempty"`
+	StderrFile      string `json:"stderrFile,omitempty"`
+	FileSizeLimitMB int64  `json:"fileSizeLimitMB,omitempty"`
+
+	Env        []string `json:"env,omitempty"`
+	WorkingDir string   `json:"workingDir,omitempty"`
+	User       string   `json:"user,omitempty"`
+
+	Interactor *Process   `json:"interactor,omitempty"`
+	Services   []Process  `json:"services,omitempty"`
+	Readiness  *Readiness `json:"readiness,omitempty"`
+}
+
+type Readiness struct {
+	TCPPort   int   `json:"tcpPort"`
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+type ExecRequest struct {
+	ID    string    `json:"id,omitempty"`
+	Files []File    `json:"files"`
+	Steps []Process `json:"steps"`
+}
+
+type ExecResponse struct {
+	ID      string   `json:"id"`
+	Reports []Report `json:"reports"`
+}
+
+// Report is the outcome of a step. Stdout and Stderr are base64-encoded on
+// the wire.
+type Report struct {
+	Status      string    `json:"status"`
+	ExitCode    int       `json:"exitCode"`
+	Signal      int       `json:"signal"`
+	Stdout      []byte    `json:"stdout"`
+	Stderr      []byte    `json:"stderr"`
+	CPUTimeUs   uint64    `json:"cpuTimeUs"`
+	MemoryBytes uint64    `json:"memoryBytes"`
+	WallTimeMs  int64     `json:"wallTimeMs"`
+	StartAt     time.Time `json:"startAt"`
+	FinishAt    time.Time `json:"finishAt"`
+
+	StdoutTruncated bool `json:"stdoutTruncated,omitempty"`
+	StderrTruncated bool `json:"stderrTruncated,omitempty"`
+
+	Artifacts []Artifact `json:"artifacts"`
+
+	Interactor *Report  `json:"interactor,omitempty"`
+	Verdict    string   `json:"verdict,omitempty"`
+	Services   []Report `json:"services,omitempty"`
+}
+
+// Artifact is a file collected from the box. Content is base64-encoded on
+// the wire, and empty when Omitted.
+type Artifact struct {
+	Path    string `json:"path"`
+	Content []byte `json:"content"`
+	Size    int64  `json:"size"`
+	Omitted bool   `json:"omitted"`
+}
+
+type DoneRequest struct {
+	ID string `json:"id"`
+}
+
+type Image struct {
+	Name       string      `json:"name"`
+	Digest     string      `json:"digest"`
+	Size       int64       `json:"size"`
+	ImportedAt time.Time   `json:"importedAt"`
+	InUse      bool        `json:"inUse"`
+	Layers     []string    `json:"layers"`
+	Config     ImageConfig `json:"config"`
+}
+
+type ImageConfig struct {
+	User       string   `json:"user,omitempty"`
+	Env        []string `json:"env,omitempty"`
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Cmd        []string `json:"cmd,omitempty"`
+	WorkingDir string   `json:"workingDir,omitempty"`
+}
+
+type ImageList struct {
+	Images []Image `json:"images"`
+}
+
+// BuildRequest builds the image named in the path from Base.
+type BuildRequest struct {
+	Base  string    `json:"base"`
+	Files []File    `json:"files"`
+	Steps []Process `json:"steps"`
+}
+
+// BuildResponse has Image set on success and Error when a step failed.
+type BuildResponse struct {
+	ID      string   `json:"id"`
+	Image   *Image   `json:"image,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Reports []Report `json:"reports"`
+}
+
+type CollectRequest struct {
+	DryRun bool `json:"dryRun"`
+}
+
+type CollectResponse struct {
+	Layers   []string `json:"layers"`
+	Overlays []string `json:"overlays"`
+	Storage  []string `json:"storage"`
+	Freed    int64    `json:"freed"`
+}
+
+type DiskUsage struct {
+	Images      int64 `json:"images"`
+	Layers      int64 `json:"layers"`
+	Overlays    int64 `json:"overlays"`
+	Storage     int64 `json:"storage"`
+	Reclaimable int64 `json:"reclaimable"`
+}
+
+// Event is sent on /v1/jobs/{id}/events while a job runs.
+type Event struct {
+	Type   string       `json:"type"`
+	JobID  string       `json:"jobId"`
+	Step   int          `json:"step"`
+	Steps  int          `json:"steps"`
+	Report *Report      `json:"report,omitempty"`
+	Output *OutputChunk `json:"output,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Time   time.Time    `json:"time"`
+}
+
+// OutputChunk is a piece of what a step's process wrote to one of its
+// streams. Data is base64-encoded on the wire.
+type OutputChunk struct {
+	Stream  string `json:"stream"`
+	Data    []byte `json:"data"`
+	Dropped int64  `json:"dropped,omitempty"`
+}
+
+// SessionEvent is sent to the client of a session. The first event carries
+// the session ID and the last one either the exit report or an error.
+type SessionEvent struct {
+	ID     string  `json:"id,omitempty"`
+	Stdout []byte  `json:"stdout,omitempty"`
+	Stderr []byte  `json:"stderr,omitempty"`
+	Exit   *Report `json:"exit,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
@@ -0,0 +1,190 @@
+package v1
+
+import (
+	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/sandbox"
+	"github.com/joshjms/castletown/server/handler/images"
+	"github.com/joshjms/castletown/server/handler/session"
+)
+
+func toJobFiles(files []File) []job.File {
+	jobFiles := make([]job.File, len(files))
+	for i, f := range files {
+		jobFiles[i] = job.File{
+			Name:    f.Name,
+			Content: f.Content,
+			Type:    job.FileType(f.Type),
+			Mode:    f.Mode,
+		}
+	}
+	return jobFiles
+}
+
+func toJobProcesses(procs []Process) []job.Process {
+	if procs == nil {
+		return nil
+	}
+
+	jobProcs := make([]job.Process, len(procs))
+	for i, p := range procs {
+		jobProcs[i] = toJobProcess(p)
+	}
+	return jobProcs
+}
+
+func toJobProcess(p Process) job.Process {
+	proc := job.Process{
+		Name:            p.Name,
+		Image:           p.Image,
+		Cmd:             p.Cmd,
+		Stdin:           p.Stdin,
+		MemoryLimitMB:   p.MemoryLimitMB,
+		TimeLimitMs:     p.TimeLimitMs,
+		ProcLimit:       p.ProcLimit,
+		Files:           p.Files,
+		Persist:         p.Persist,
+		Outputs:         p.Outputs,
+		StdinFile:       p.StdinFile,
+		StdinFrom:       p.StdinFrom,
+		StdoutFile:      p.StdoutFile,
+		StderrFile:      p.StderrFile,
+		FileSizeLimitMB: p.FileSizeLimitMB,
+		Env:             p.Env,
+		WorkingDir:      p.WorkingDir,
+		User:            p.User,
+		Services:        toJobProcesses(p.Services),
+	}
+	if p.Interactor != nil {
+		interactor := toJobProcess(*p.Interactor)
+		proc.Interactor = &interactor
+	}
+	if p.Readiness != nil {
+		proc.Readiness = &job.Readiness{
+			TCPPort:   p.Readiness.TCPPort,
+			TimeoutMs: p.Readiness.TimeoutMs,
+		}
+	}
+	return proc
+}
+
+func toReports(reports []sandbox.Report) []Report {
+	if reports == nil {
+		return []Report{}
+	}
+
+	apiReports := make([]Report, len(reports))
+	for i, r := range reports {
+		apiReports[i] = toReport(r)
+	}
+	return apiReports
+}
+
+// toReport converts r. The wall time is measured from the start and finish
+// times, which the sandbox always records.
+func toReport(r sandbox.Report) Report {
+	report := Report{
+		Status:      string(r.Status),
+		ExitCode:    r.ExitCode,
+		Signal:      int(r.Signal),
+		Stdout:      r.Stdout,
+		Stderr:      r.Stderr,
+		CPUTimeUs:   r.CPUTime,
+		MemoryBytes: r.Memory,
+		WallTimeMs:  r.WallTime,
+		StartAt:     r.StartAt,
+		FinishAt:    r.FinishAt,
+		Artifacts:   make([]Artifact, len(r.Artifacts)),
+		Verdict:     string(r.Verdict),
+
+		StdoutTruncated: r.StdoutTruncated,
+		StderrTruncated: r.StderrTruncated,
+	}
+	if report.WallTimeMs == 0 && !r.StartAt.IsZero() && !r.FinishAt.IsZero() {
+		report.WallTimeMs = r.FinishAt.Sub(r.StartAt).Milliseconds()
+	}
+
+	for i, a := range r.Artifacts {
+		report.Artifacts[i] = Artifact{
+			Path:    a.Path,
+			Content: a.Content,
+			Size:    a.Size,
+			Omitted: a.Omitted,
+		}
+	}
+	if r.Interactor != nil {
+		interactor := toReport(*r.Interactor)
+		report.Interactor = &interactor
+	}
+	if len(r.Services) > 0 {
+		report.Services = toReports(r.Services)
+	}
+	return report
+}
+
+func toImage(img images.Image) Image {
+	return Image{
+		Name:       img.Name,
+		Digest:     img.Digest,
+		Size:       img.Size,
+		ImportedAt: img.ImportedAt,
+		InUse:      img.InUse,
+		Layers:     nonNil(img.Layers),
+		Config: ImageConfig{
+			User:       img.Config.User,
+			Env:        img.Config.Env,
+			Entrypoint: img.Config.Entrypoint,
+			Cmd:        img.Config.Cmd,
+			WorkingDir: img.Config.WorkingDir,
+		},
+	}
+}
+
+func toBuildResponse(resp images.BuildResponse) BuildResponse {
+	build := BuildResponse{
+		ID:      resp.ID,
+		Error:   resp.Error,
+		Reports: toReports(resp.Reports),
+	}
+	if resp.Image != nil {
+		img := toImage(*resp.Image)
+		build.Image = &img
+	}
+	return build
+}
+
+func toEvent(ev job.Event) Event {
+	event := Event{
+		Type:  string(ev.Type),
+		JobID: ev.JobID,
+		Step:  ev.Step,
+		Steps: ev.Steps,
+		Error: ev.Error,
+		Time:  ev.Time,
+	}
+	if ev.Report != nil {
+		report := toReport(*ev.Report)
+		event.Report = &report
+	}
+	if ev.Output != nil {
+		event.Output = &OutputChunk{
+			Stream:  ev.Output.Stream,
+			Data:    ev.Output.Data,
+			Dropped: ev.Output.Dropped,
+		}
+	}
+	return event
+}
+
+func toSessionEvent(e session.Event) SessionEvent {
+	event := SessionEvent{
+		ID:     e.ID,
+		Stdout: e.Stdout,
+		Stderr: e.Stderr,
+		Error:  e.Error,
+	}
+	if e.Exit != nil {
+		report := toReport(*e.Exit)
+		event.Exit = &report
+	}
+	return event
+}
@@ -0,0 +1,25 @@
+package v1
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPI describes every endpoint of the API and the JSON of its requests
+// and responses.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+// OpenAPIHandler serves the OpenAPI document of the API: GET
+// /v1/openapi.json.
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPI)
+}
@@ -0,0 +1,301 @@
+// Package v1 serves the versioned HTTP API under /v1/. Its JSON is
+// camelCased throughout, every failure is answered with an ErrorResponse and
+// the whole API is described by the OpenAPI document at /v1/openapi.json.
+// It shares its logic with the unversioned handlers, which are kept as
+// deprecated aliases.
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/quota"
+	"github.com/joshjms/castletown/server/handler/artifact"
+	"github.com/joshjms/castletown/server/handler/done"
+	"github.com/joshjms/castletown/server/handler/exec"
+	"github.com/joshjms/castletown/server/handler/gc"
+	"github.com/joshjms/castletown/server/handler/images"
+	"github.com/joshjms/castletown/server/handler/jobs"
+	"github.com/joshjms/castletown/server/handler/session"
+)
+
+// ExecHandler runs a job and answers with the reports of its steps: POST
+// /v1/exec.
+func ExecHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ExecRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.ID == "" {
+		req.ID = uuid.NewString()
+	}
+
+	reports, err := exec.Run(r.Context(), exec.Request{
+		ID:    req.ID,
+		Files: toJobFiles(req.Files),
+		Procs: toJobProcesses(req.Steps),
+	})
+	if err != nil {
+		quota.SetRetryAfter(w, err)
+		if status := exec.HTTPStatus(err); status != http.StatusInternalServerError {
+			HTTPError(w, err.Error(), status)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, ERROR_JOB_FAILED, fmt.Sprintf("error running processes: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ExecResponse{
+		ID:      req.ID,
+		Reports: toReports(reports),
+	})
+}
+
+// DoneHandler releases a job and its files: POST /v1/done.
+func DoneHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DoneRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	done.Release(r.Context(), req.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ArtifactHandler streams an output file of a step: GET
+// /v1/artifact?id=<job>&step=<n>&path=<file>.
+func ArtifactHandler(w http.ResponseWriter, r *http.Request) {
+	artifact.ServeArtifact(w, r, HTTPError)
+}
+
+// SessionHandler serves sessions over WebSocket: /v1/session.
+func SessionHandler(w http.ResponseWriter, r *http.Request) {
+	session.ServeWebSocket(w, r, func(e session.Event) any {
+		return toSessionEvent(e)
+	})
+}
+
+// ImagesHandler lists the images available to jobs: GET /v1/images.
+func ImagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	imgs, err := images.List()
+	if err != nil {
+		HTTPError(w, err.Error(), images.HTTPStatus(err))
+		return
+	}
+
+	list := ImageList{Images: make([]Image, len(imgs))}
+	for i, img := range imgs {
+		list.Images[i] = toImage(img)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// ImageHandler inspects, removes or builds a single image: GET, DELETE or
+// POST /v1/images/{name}. A build whose steps fail answers 422 with their
+// reports.
+func ImageHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		img, err := images.Get(name)
+		if err != nil {
+			HTTPError(w, err.Error(), images.HTTPStatus(err))
+			return
+		}
+		writeJSON(w, http.StatusOK, toImage(img))
+
+	case http.MethodDelete:
+		if err := images.Remove(r.Context(), name); err != nil {
+			HTTPError(w, err.Error(), images.HTTPStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodPost:
+		var req BuildRequest
+		if !decode(w, r, &req) {
+			return
+		}
+
+		resp, err := images.Build(r.Context(), name, images.BuildRequest{
+			Base:  req.Base,
+			Files: toJobFiles(req.Files),
+			Procs: toJobProcesses(req.Steps),
+		})
+		if err != nil {
+			quota.SetRetryAfter(w, err)
+			HTTPError(w, err.Error(), images.HTTPStatus(err))
+			return
+		}
+
+		status := http.StatusOK
+		if resp.Error != "" {
+			status = http.StatusUnprocessableEntity
+		}
+		writeJSON(w, status, toBuildResponse(resp))
+
+	default:
+		HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GCHandler runs a garbage collection: POST /v1/gc.
+func GCHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := gc.Authorize(r.Context()); err != nil {
+		HTTPError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req CollectRequest
+	if r.ContentLength != 0 && !decode(w, r, &req) {
+		return
+	}
+
+	res, err := gc.Collect(req.DryRun)
+	if err != nil {
+		HTTPError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CollectResponse{
+		Layers:   nonNil(res.Layers),
+		Overlays: nonNil(res.Overlays),
+		Storage:  nonNil(res.Storage),
+		Freed:    res.Freed,
+	})
+}
+
+// DiskUsageHandler reports the disk space taken by each kind of data: GET
+// /v1/disk-usage.
+func DiskUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := gc.Authorize(r.Context()); err != nil {
+		HTTPError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	usage, err := gc.DiskUsage()
+	if err != nil {
+		HTTPError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, DiskUsage(usage))
+}
+
+// OutputHandler streams the output of a job's steps as Server-Sent Events:
+// GET /v1/jobs/{id}/output.
+func OutputHandler(w http.ResponseWriter, r *http.Request) {
+	jobs.ServeOutput(w, r, HTTPError)
+}
+
+// EventsHandler streams the events of a job as Server-Sent Events or over a
+// WebSocket: GET /v1/jobs/{id}/events.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	jobs.ServeEvents(w, r, func(ev job.Event) any {
+		return toEvent(ev)
+	}, HTTPError)
+}
+
+// NotFoundHandler answers requests for paths under /v1/ that name no
+// endpoint.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	HTTPError(w, fmt.Sprintf("no endpoint at %s", r.URL.Path), http.StatusNotFound)
+}
+
+// decode reads the JSON body of r into v, answering the request itself when
+// it cannot.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("empty body")
+		}
+		HTTPError(w, fmt.Sprintf("invalid json: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		HTTPError(w, fmt.Sprintf("cannot marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// HTTPError answers with an ErrorResponse whose code follows from status.
+// It has the signature of http.Error, so that handlers shared with the
+// unversioned API, and the server's authentication, can report errors
+// through it.
+func HTTPError(w http.ResponseWriter, message string, status int) {
+	writeError(w, status, errorCode(status), message)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	b, _ := json.Marshal(ErrorResponse{Error: Error{Code: code, Message: message}})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ERROR_INVALID_REQUEST
+	case http.StatusUnauthorized:
+		return ERROR_UNAUTHENTICATED
+	case http.StatusForbidden:
+		return ERROR_FORBIDDEN
+	case http.StatusNotFound:
+		return ERROR_NOT_FOUND
+	case http.StatusMethodNotAllowed:
+		return ERROR_METHOD_NOT_ALLOWED
+	case http.StatusConflict:
+		return ERROR_CONFLICT
+	case http.StatusTooManyRequests:
+		return ERROR_RESOURCE_EXHAUSTED
+	default:
+		return ERROR_INTERNAL
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
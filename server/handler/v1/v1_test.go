@@ -0,0 +1,174 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/joshjms/castletown/auth"
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/job"
+	"github.com/joshjms/castletown/server/handler/jobs"
+	"github.com/joshjms/castletown/server/handler/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	var doc struct {
+		Paths      map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(openAPI, &doc))
+
+	types := map[string]any{
+		"ErrorResponse":   ErrorResponse{},
+		"Error":           Error{},
+		"File":            File{},
+		"Process":         Process{},
+		"Readiness":       Readiness{},
+		"ExecRequest":     ExecRequest{},
+		"ExecResponse":    ExecResponse{},
+		"Report":          Report{},
+		"Artifact":        Artifact{},
+		"DoneRequest":     DoneRequest{},
+		"Image":           Image{},
+		"ImageConfig":     ImageConfig{},
+		"ImageList":       ImageList{},
+		"BuildRequest":    BuildRequest{},
+		"BuildResponse":   BuildResponse{},
+		"CollectRequest":  CollectRequest{},
+		"CollectResponse": CollectResponse{},
+		"DiskUsage":       DiskUsage{},
+		"Event":           Event{},
+		"OutputChunk":     OutputChunk{},
+		"OutputEvent":     jobs.OutputEvent{},
+		"SessionStart":    session.Start{},
+		"SessionInput":    session.Input{},
+		"TerminalSize":    session.TerminalSize{},
+		"SessionEvent":    SessionEvent{},
+	}
+	require.Len(t, doc.Components.Schemas, len(types))
+
+	for name, v := range types {
+		schema, ok := doc.Components.Schemas[name]
+		require.True(t, ok, "schema %s is missing", name)
+
+		var fields []string
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			fields = append(fields, tag)
+		}
+		var properties []string
+		for property := range schema.Properties {
+			properties = append(properties, property)
+		}
+		slices.Sort(fields)
+		slices.Sort(properties)
+		require.Equal(t, fields, properties, "schema %s", name)
+	}
+
+	for path := range doc.Paths {
+		require.True(t, strings.HasPrefix(path, "/v1/"), path)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		handler http.HandlerFunc
+		method  string
+		body    string
+		status  int
+		code    string
+	}{
+		{ExecHandler, http.MethodGet, "", http.StatusMethodNotAllowed, ERROR_METHOD_NOT_ALLOWED},
+		{ExecHandler, http.MethodPost, "{", http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{DoneHandler, http.MethodPost, "", http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{ArtifactHandler, http.MethodGet, "", http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{EventsHandler, http.MethodPost, "", http.StatusMethodNotAllowed, ERROR_METHOD_NOT_ALLOWED},
+		{NotFoundHandler, http.MethodGet, "", http.StatusNotFound, ERROR_NOT_FOUND},
+		// Builds are off without --build-principals.
+		{ImageHandler, http.MethodPost, `{"base":"python:3.12","steps":[]}`, http.StatusForbidden, ERROR_FORBIDDEN},
+		// So are removals without --admin-principals.
+		{ImageHandler, http.MethodDelete, "", http.StatusForbidden, ERROR_FORBIDDEN},
+		{GCHandler, http.MethodPost, "", http.StatusForbidden, ERROR_FORBIDDEN},
+		{DiskUsageHandler, http.MethodGet, "", http.StatusForbidden, ERROR_FORBIDDEN},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler(w, httptest.NewRequest(tt.method, "/v1/test", strings.NewReader(tt.body)))
+
+		require.Equal(t, tt.status, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Equal(t, tt.code, resp.Error.Code)
+		require.NotEmpty(t, resp.Error.Message)
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	imagesDir, storageDir := config.ImagesDir, config.StorageDir
+	t.Cleanup(func() { config.ImagesDir, config.StorageDir = imagesDir, storageDir })
+	config.ImagesDir = t.TempDir()
+	config.StorageDir = t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(config.ImagesDir, "test-latest"), 0755))
+
+	job.NewJobPool()
+	_, err := job.GetJobPool().AddOrAppendJob(&job.Job{ID: "alices", Principal: "alice"})
+	require.NoError(t, err)
+
+	step := func(extra string) string {
+		return `{"image":"test:latest","cmd":["true"]` + extra + `}`
+	}
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"no steps", `{"steps":[]}`, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"unknown image", `{"steps":[{"image":"missing:latest","cmd":["true"]}]}`, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"invalid image name", `{"steps":[{"image":"../../..","cmd":["true"]}]}`, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"invalid file", `{"files":[{"name":"../a","content":""}],"steps":[` + step("") + `]}`, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"invalid stdinFrom", `{"steps":[` + step(`,"stdinFrom":"nope.stdout"`) + `]}`, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"other principal", `{"id":"alices","steps":[` + step("") + `]}`, http.StatusForbidden, ERROR_FORBIDDEN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/exec", strings.NewReader(tt.body))
+			r = r.WithContext(auth.NewContext(r.Context(), auth.Principal{Name: "bob"}))
+			w := httptest.NewRecorder()
+			ExecHandler(w, r)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			var resp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tt.code, resp.Error.Code)
+		})
+	}
+
+	// Failing to set up a valid job is the server's fault.
+	config.StorageDir = filepath.Join(config.StorageDir, "file")
+	require.NoError(t, os.WriteFile(config.StorageDir, nil, 0644))
+
+	w := httptest.NewRecorder()
+	ExecHandler(w, httptest.NewRequest(http.MethodPost, "/v1/exec", strings.NewReader(`{"steps":[`+step("")+`]}`)))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, ERROR_JOB_FAILED, resp.Error.Code)
+}
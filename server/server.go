@@ -3,17 +3,32 @@ package server
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
+	"github.com/joshjms/castletown/auth"
 	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/logging"
+	"github.com/joshjms/castletown/metrics"
 	pb "github.com/joshjms/castletown/proto"
+	"github.com/joshjms/castletown/quota"
+	"github.com/joshjms/castletown/server/handler/artifact"
 	"github.com/joshjms/castletown/server/handler/done"
 	"github.com/joshjms/castletown/server/handler/exec"
+	"github.com/joshjms/castletown/server/handler/gc"
+	"github.com/joshjms/castletown/server/handler/images"
+	"github.com/joshjms/castletown/server/handler/jobs"
+	"github.com/joshjms/castletown/server/handler/session"
+	v1 "github.com/joshjms/castletown/server/handler/v1"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type Server struct {
@@ -22,31 +37,109 @@ type Server struct {
 }
 
 func NewServer() (*Server, error) {
-	grpcSrv := grpc.NewServer()
+	tlsConfig, err := newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var grpcOpts []grpc.ServerOption
+	if tlsConfig != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	var handler http.Handler = http.DefaultServeMux
+	if config.AuthKeysFile != "" || config.TLSClientCAFile != "" {
+		authenticator, err := auth.NewAuthenticator(config.AuthKeysFile, config.TLSClientCAFile != "")
+		if err != nil {
+			return nil, err
+		}
+		// Limits apply to principals, so they are checked once the
+		// principal is known.
+		limiter := quota.GetLimiter()
+		handler = authenticator.Middleware(limiter.Middleware(handler, httpError), httpError)
+		grpcOpts = append(grpcOpts,
+			grpc.ChainUnaryInterceptor(authenticator.UnaryInterceptor(), limiter.UnaryInterceptor()),
+			grpc.ChainStreamInterceptor(authenticator.StreamInterceptor(), limiter.StreamInterceptor()),
+		)
+	} else {
+		slog.Warn("authentication is disabled, anyone who can reach the server can run code on it")
+		if config.RateLimit > 0 || config.JobLimit > 0 || config.CPUQuota > 0 {
+			slog.Warn("limits apply to authenticated principals only, so they are not enforced")
+		}
+	}
+
+	// Spans of requests continue the traces of their clients. Tracing is
+	// outermost so that requests turned away are traced too.
+	handler = otelhttp.NewHandler(handler, "castletown",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "HTTP " + r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/metrics"
+		}),
+	)
+	grpcOpts = append(grpcOpts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+
+	grpcSrv := grpc.NewServer(grpcOpts...)
 
 	pb.RegisterExecServiceServer(grpcSrv, exec.NewExecServer())
 	pb.RegisterDoneServiceServer(grpcSrv, done.NewDoneServer())
+	pb.RegisterArtifactServiceServer(grpcSrv, artifact.NewArtifactServer())
+	pb.RegisterSessionServiceServer(grpcSrv, session.NewSessionServer())
+	pb.RegisterImageServiceServer(grpcSrv, images.NewImageServer())
+	pb.RegisterGCServiceServer(grpcSrv, gc.NewGCServer())
 
 	return &Server{
 		httpSrv: &http.Server{
-			Addr:    fmt.Sprintf(":%d", config.Port),
-			Handler: http.DefaultServeMux,
+			Addr:      fmt.Sprintf(":%d", config.Port),
+			Handler:   handler,
+			TLSConfig: tlsConfig,
 		},
 		grpcSrv: grpcSrv,
 	}, nil
 }
 
 func (s *Server) Start() {
-	http.HandleFunc("/exec", exec.Handler)
-	http.HandleFunc("/done", done.Handler)
+	http.HandleFunc("/v1/exec", v1.ExecHandler)
+	http.HandleFunc("/v1/done", v1.DoneHandler)
+	http.HandleFunc("/v1/artifact", v1.ArtifactHandler)
+	http.HandleFunc("/v1/session", v1.SessionHandler)
+	http.HandleFunc("/v1/images", v1.ImagesHandler)
+	http.HandleFunc("/v1/images/{name}", v1.ImageHandler)
+	http.HandleFunc("/v1/gc", v1.GCHandler)
+	http.HandleFunc("/v1/disk-usage", v1.DiskUsageHandler)
+	http.HandleFunc("/v1/jobs/{id}/output", v1.OutputHandler)
+	http.HandleFunc("/v1/jobs/{id}/events", v1.EventsHandler)
+	http.HandleFunc("/v1/openapi.json", v1.OpenAPIHandler)
+	http.HandleFunc("/v1/", v1.NotFoundHandler)
+	http.Handle("/metrics", metrics.Handler())
+
+	// The routes from before /v1 keep their own JSON and plain text errors.
+	http.HandleFunc("/exec", deprecated(exec.Handler))
+	http.HandleFunc("/done", deprecated(done.Handler))
+	http.HandleFunc("/artifact", deprecated(artifact.Handler))
+	http.HandleFunc("/session", deprecated(session.Handler))
+	http.HandleFunc("/images", deprecated(images.ListHandler))
+	http.HandleFunc("/images/{name}", deprecated(images.Handler))
+	http.HandleFunc("/gc", deprecated(gc.Handler))
+	http.HandleFunc("/disk-usage", deprecated(gc.DiskUsageHandler))
+	http.HandleFunc("/jobs/{id}/output", deprecated(jobs.OutputHandler))
+	http.HandleFunc("/jobs/{id}/events", deprecated(jobs.EventsHandler))
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
 
 	go func() {
-		fmt.Printf("Starting HTTP server at port %s\n", s.httpSrv.Addr)
-		if err := s.httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			fmt.Printf("Error starting HTTP server: %v\n", err)
+		var err error
+		if s.httpSrv.TLSConfig != nil {
+			slog.Info("starting HTTPS server", "addr", s.httpSrv.Addr)
+			err = s.httpSrv.ListenAndServeTLS("", "")
+		} else {
+			slog.Info("starting HTTP server", "addr", s.httpSrv.Addr)
+			err = s.httpSrv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("error starting HTTP server", logging.Error(err))
 		}
 	}()
 
@@ -54,27 +147,78 @@ func (s *Server) Start() {
 	go func() {
 		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
 		if err != nil {
-			fmt.Printf("Failed to listen for gRPC: %v\n", err)
+			slog.Error("failed to listen for gRPC", logging.Error(err))
 			return
 		}
-		fmt.Printf("Starting gRPC server at port %d\n", grpcPort)
+		slog.Info("starting gRPC server", "port", grpcPort)
 		if err := s.grpcSrv.Serve(lis); err != nil {
-			fmt.Printf("Error starting gRPC server: %v\n", err)
+			slog.Error("error starting gRPC server", logging.Error(err))
 		}
 	}()
 
+	gcStop := make(chan struct{})
+	if config.GCInterval > 0 {
+		go collectGarbage(config.GCInterval, gcStop)
+	}
+
 	<-stop
 
-	fmt.Println("Shutting down servers...")
+	slog.Info("shutting down servers")
+	close(gcStop)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := s.httpSrv.Shutdown(ctx); err != nil {
-		fmt.Printf("Error shutting down HTTP server: %v\n", err)
+		slog.Error("error shutting down HTTP server", logging.Error(err))
 	}
 
 	s.grpcSrv.GracefulStop()
 
-	fmt.Println("Servers gracefully stopped")
+	slog.Info("servers gracefully stopped")
+}
+
+// httpError answers requests turned away before reaching their handler, for
+// lack of credentials or for exceeding a limit, in the error format of the
+// API they are for.
+func httpError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		v1.HTTPError(w, message, status)
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// deprecated marks the responses of a route from before /v1 as deprecated,
+// linking to the /v1 route that replaces it.
+func deprecated(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("</v1%s>; rel=\"successor-version\"", r.URL.EscapedPath()))
+		h(w, r)
+	}
+}
+
+// collectGarbage removes unused layers, overlays and job storage every
+// interval until stop is closed.
+func collectGarbage(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		res, err := gc.Collect(false)
+		if err != nil {
+			slog.Error("error collecting garbage", logging.Error(err))
+		}
+		if res != nil && res.Freed > 0 {
+			slog.Info("collected garbage", "layers", len(res.Layers), "overlays", len(res.Overlays),
+				"jobs", len(res.Storage), "freed_bytes", res.Freed)
+		}
+	}
 }
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+	"github.com/joshjms/castletown/logging"
+)
+
+// CERT_CHECK_INTERVAL is how often handshakes look for changes to the
+// certificate, its key and the client CA bundle.
+const CERT_CHECK_INTERVAL = 10 * time.Second
+
+// newTLSConfig loads the certificate both ports serve and, with
+// config.TLSClientCAFile, the CAs that sign the certificates clients may
+// authenticate with. It returns nil when TLS is not configured.
+//
+// The files are loaded again when they change, so that renewed certificates
+// are served without a restart. A file that fails to load is reported and
+// the previous one kept.
+func newTLSConfig() (*tls.Config, error) {
+	if config.TLSCertFile == "" && config.TLSKeyFile == "" {
+		if config.TLSClientCAFile != "" {
+			return nil, errors.New("client certificates need a server certificate and key")
+		}
+		return nil, nil
+	}
+
+	c, err := newCertificates(config.TLSCertFile, config.TLSKeyFile, config.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: c.configForClient,
+	}, nil
+}
+
+// certificates holds the files TLS is configured with, as last loaded.
+type certificates struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+func newCertificates(certFile, keyFile, clientCAFile string) (*certificates, error) {
+	c := &certificates{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// configForClient is the configuration of a handshake, made afresh so that it
+// has the latest files. Both HTTP/2, which gRPC needs, and HTTP/1.1, which
+// WebSockets need, are offered.
+func (c *certificates) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	c.mu.Lock()
+	if time.Since(c.checkedAt) >= CERT_CHECK_INTERVAL {
+		if err := c.reload(); err != nil {
+			slog.Error("error reloading TLS certificates", logging.Error(err))
+		}
+	}
+	cert, clientCAs := c.cert, c.clientCAs
+	c.mu.Unlock()
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if clientCAs != nil {
+		// Clients without a certificate may still use an API key.
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// reload loads the files again if any of them changed since they were last
+// loaded. c.mu must be held.
+func (c *certificates) reload() error {
+	c.checkedAt = time.Now()
+
+	modTimes, err := c.stat()
+	if err != nil {
+		return err
+	}
+	if slices.EqualFunc(modTimes, c.modTimes, time.Time.Equal) {
+		return nil
+	}
+
+	if err := c.load(); err != nil {
+		// Retry once the files change again rather than on every check.
+		c.modTimes = modTimes
+		return err
+	}
+	slog.Info("reloaded TLS certificates")
+	return nil
+}
+
+// load reads the files, replacing what c holds only if all of them load.
+func (c *certificates) load() error {
+	modTimes, err := c.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if c.clientCAFile != "" {
+		pem, err := os.ReadFile(c.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("error reading client CA bundle: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates in client CA bundle %s", c.clientCAFile)
+		}
+	}
+
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.modTimes = modTimes
+	c.checkedAt = time.Now()
+	return nil
+}
+
+func (c *certificates) stat() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, file := range []string{c.certFile, c.keyFile, c.clientCAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading TLS file: %w", err)
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joshjms/castletown/config"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate for name and its key.
+func writeCert(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+func servedName(t *testing.T, c *certificates) string {
+	handshake, err := c.configForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	require.Contains(t, handshake.NextProtos, "h2")
+
+	cert, err := x509.ParseCertificate(handshake.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	return cert.Subject.CommonName
+}
+
+func TestTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeCert(t, certFile, keyFile, "old")
+	c, err := newCertificates(certFile, keyFile, "")
+	require.NoError(t, err)
+	require.Equal(t, "old", servedName(t, c))
+
+	// Renewed files are picked up at the next check.
+	writeCert(t, certFile, keyFile, "new")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+	require.Equal(t, "old", servedName(t, c))
+
+	c.checkedAt = time.Time{}
+	require.Equal(t, "new", servedName(t, c))
+
+	// A broken certificate leaves the last good one in place.
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0644))
+	future = future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	c.checkedAt = time.Time{}
+	require.Equal(t, "new", servedName(t, c))
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	config.TLSCertFile = ""
+	config.TLSKeyFile = ""
+	config.TLSClientCAFile = ""
+
+	tlsConfig, err := newTLSConfig()
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	config.TLSClientCAFile = "ca.pem"
+	t.Cleanup(func() { config.TLSClientCAFile = "" })
+	_, err = newTLSConfig()
+	require.Error(t, err)
+}
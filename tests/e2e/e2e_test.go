@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -28,10 +29,13 @@ type HTTPExecRequest struct {
 
 type HTTPFile struct {
 	Name    string `json:"name"`
-	Content string `json:"content"`
+	Content []byte `json:"content"`
+	Type    string `json:"type,omitempty"`
+	Mode    uint32 `json:"mode,omitempty"`
 }
 
 type HTTPProcess struct {
+	Name          string   `json:"name,omitempty"`
 	Image         string   `json:"image"`
 	Cmd           []string `json:"cmd"`
 	Stdin         string   `json:"stdin"`
@@ -40,6 +44,10 @@ type HTTPProcess struct {
 	ProcLimit     int64    `json:"procLimit"`
 	Files         []string `json:"files"`
 	Persist       []string `json:"persist"`
+	Outputs       []string `json:"outputs"`
+	StdinFile     string   `json:"stdinFile,omitempty"`
+	StdinFrom     string   `json:"stdinFrom,omitempty"`
+	StdoutFile    string   `json:"stdoutFile,omitempty"`
 }
 
 type HTTPExecResponse struct {
@@ -51,11 +59,20 @@ type HTTPReport struct {
 	Status   string `json:"Status"`
 	ExitCode int    `json:"ExitCode"`
 	Signal   int    `json:"Signal"`
-	Stdout   string `json:"Stdout"`
-	Stderr   string `json:"Stderr"`
+	Stdout   []byte `json:"Stdout"`
+	Stderr   []byte `json:"Stderr"`
 	CPUTime  uint64 `json:"CPUTime"`
 	Memory   uint64 `json:"Memory"`
 	WallTime int64  `json:"WallTime"`
+
+	Artifacts []HTTPArtifact `json:"Artifacts"`
+}
+
+type HTTPArtifact struct {
+	Path    string `json:"Path"`
+	Content []byte `json:"Content"`
+	Size    int64  `json:"Size"`
+	Omitted bool   `json:"Omitted"`
 }
 
 type HTTPDoneRequest struct {
@@ -69,7 +86,7 @@ func TestHTTPExec(t *testing.T) {
 		Files: []HTTPFile{
 			{
 				Name:    "test.txt",
-				Content: "Hello from HTTP test",
+				Content: []byte("Hello from HTTP test"),
 			},
 		},
 		Steps: []HTTPProcess{
@@ -160,7 +177,7 @@ func TestGRPCExec(t *testing.T) {
 		Files: []*pb.File{
 			{
 				Name:    "test.txt",
-				Content: "Hello from gRPC test",
+				Content: []byte("Hello from gRPC test"),
 			},
 		},
 		Procs: []*pb.Process{
@@ -234,7 +251,7 @@ func TestHTTPAndGRPCIntegration(t *testing.T) {
 		Files: []HTTPFile{
 			{
 				Name:    "input.txt",
-				Content: "Hello, world!",
+				Content: []byte("Hello, world!"),
 			},
 		},
 		Steps: []HTTPProcess{
@@ -276,3 +293,284 @@ func TestHTTPAndGRPCIntegration(t *testing.T) {
 
 	t.Log("Integration test passed: HTTP exec + gRPC done")
 }
+
+func TestHTTPBinaryRoundTrip(t *testing.T) {
+	content := make([]byte, 256)
+	for i := range content {
+		content[i] = byte(i)
+	}
+
+	req := HTTPExecRequest{
+		ID: "test-http-binary",
+		Files: []HTTPFile{
+			{
+				Name:    "blob.bin",
+				Content: content,
+			},
+		},
+		Steps: []HTTPProcess{
+			{
+				Image: defaultImage,
+				Cmd:   []string{"/bin/cat", "blob.bin"},
+				Files: []string{"blob.bin"},
+			},
+		},
+	}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	resp, err := http.Post(httpURL+"/exec", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatalf("Failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, string(body))
+	}
+
+	var execResp HTTPExecResponse
+	if err := json.NewDecoder(resp.Body).Decode(&execResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(execResp.Reports) == 0 {
+		t.Fatal("Expected at least one report")
+	}
+
+	if !bytes.Equal(execResp.Reports[0].Stdout, content) {
+		t.Errorf("Expected stdout to match binary input byte-for-byte, got %v", execResp.Reports[0].Stdout)
+	}
+}
+
+func TestHTTPArchiveInput(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("pkg/data/hello.txt")
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	w.Write([]byte("hello from zip"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+
+	req := HTTPExecRequest{
+		ID: "test-http-archive",
+		Files: []HTTPFile{
+			{
+				Name:    "project",
+				Content: archive.Bytes(),
+				Type:    "zip",
+			},
+			{
+				Name:    "project/run.sh",
+				Content: []byte("#!/bin/sh\ncat pkg/data/hello.txt > ../out/result.txt\n"),
+				Mode:    0755,
+			},
+			{
+				Name: "out",
+				Type: "dir",
+			},
+		},
+		Steps: []HTTPProcess{
+			{
+				Image:   defaultImage,
+				Cmd:     []string{"/bin/sh", "-c", "cd project && ./run.sh"},
+				Files:   []string{"project/**", "out"},
+				Persist: []string{"out/**"},
+			},
+			{
+				Image: defaultImage,
+				Cmd:   []string{"/bin/cat", "out/result.txt"},
+				Files: []string{"out"},
+			},
+		},
+	}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	resp, err := http.Post(httpURL+"/exec", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatalf("Failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, string(body))
+	}
+
+	var execResp HTTPExecResponse
+	if err := json.NewDecoder(resp.Body).Decode(&execResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(execResp.Reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %d", len(execResp.Reports))
+	}
+
+	if got := string(execResp.Reports[1].Stdout); got != "hello from zip" {
+		t.Errorf("Expected stdout 'hello from zip', got '%s'", got)
+	}
+}
+
+func TestHTTPOutputs(t *testing.T) {
+	req := HTTPExecRequest{
+		ID: "test-http-outputs",
+		Steps: []HTTPProcess{
+			{
+				Image:   defaultImage,
+				Cmd:     []string{"/bin/sh", "-c", "mkdir -p reports && printf '<ok/>' > reports/junit.xml"},
+				Outputs: []string{"reports/*.xml"},
+			},
+		},
+	}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	resp, err := http.Post(httpURL+"/exec", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatalf("Failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, string(body))
+	}
+
+	var execResp HTTPExecResponse
+	if err := json.NewDecoder(resp.Body).Decode(&execResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(execResp.Reports) == 0 || len(execResp.Reports[0].Artifacts) != 1 {
+		t.Fatalf("Expected one artifact, got %+v", execResp.Reports)
+	}
+
+	artifact := execResp.Reports[0].Artifacts[0]
+	if artifact.Path != "reports/junit.xml" || string(artifact.Content) != "<ok/>" {
+		t.Errorf("Unexpected artifact %s: %q", artifact.Path, artifact.Content)
+	}
+
+	dl, err := http.Get(httpURL + "/artifact?id=test-http-outputs&step=0&path=reports/junit.xml")
+	if err != nil {
+		t.Fatalf("Failed to download artifact: %v", err)
+	}
+	defer dl.Body.Close()
+
+	body, _ := io.ReadAll(dl.Body)
+	if dl.StatusCode != http.StatusOK || string(body) != "<ok/>" {
+		t.Errorf("Expected downloaded artifact '<ok/>', got %d: %q", dl.StatusCode, body)
+	}
+}
+
+func TestHTTPStdioFiles(t *testing.T) {
+	req := HTTPExecRequest{
+		ID: "test-http-stdio-files",
+		Steps: []HTTPProcess{
+			{
+				Image:      defaultImage,
+				Cmd:        []string{"/usr/bin/seq", "1", "1000"},
+				StdoutFile: "tests/big.in",
+				Persist:    []string{"tests"},
+			},
+			{
+				Image:     defaultImage,
+				Cmd:       []string{"/usr/bin/wc", "-l"},
+				StdinFile: "tests/big.in",
+			},
+		},
+	}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	resp, err := http.Post(httpURL+"/exec", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatalf("Failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, string(body))
+	}
+
+	var execResp HTTPExecResponse
+	if err := json.NewDecoder(resp.Body).Decode(&execResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(execResp.Reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %d", len(execResp.Reports))
+	}
+
+	if len(execResp.Reports[0].Stdout) != 0 {
+		t.Errorf("Expected redirected stdout to be empty, got %d bytes", len(execResp.Reports[0].Stdout))
+	}
+
+	if got := string(execResp.Reports[1].Stdout); got != "1000\n" {
+		t.Errorf("Expected stdout '1000', got '%s'", got)
+	}
+}
+
+func TestHTTPStdinFrom(t *testing.T) {
+	req := HTTPExecRequest{
+		ID: "test-http-stdin-from",
+		Steps: []HTTPProcess{
+			{
+				Name:  "gen",
+				Image: defaultImage,
+				Cmd:   []string{"/bin/echo", "6 9"},
+			},
+			{
+				Image:     defaultImage,
+				Cmd:       []string{"/bin/sh", "-c", "read a b; echo $((a + b))"},
+				StdinFrom: "gen.stdout",
+			},
+		},
+	}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	resp, err := http.Post(httpURL+"/exec", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatalf("Failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, string(body))
+	}
+
+	var execResp HTTPExecResponse
+	if err := json.NewDecoder(resp.Body).Decode(&execResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(execResp.Reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %d", len(execResp.Reports))
+	}
+
+	if got := string(execResp.Reports[1].Stdout); got != "15\n" {
+		t.Errorf("Expected stdout '15', got '%s'", got)
+	}
+}
@@ -0,0 +1,149 @@
+// Package tracing sets up OpenTelemetry tracing of requests through the
+// jobs they run and the phases of their sandboxes. Trace context is taken
+// from the W3C traceparent header or metadata of HTTP and gRPC requests, so
+// the server's spans join the traces of its clients.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters spans can be sent to.
+const (
+	EXPORTER_NONE   = "none"
+	EXPORTER_OTLP   = "otlp"
+	EXPORTER_STDOUT = "stdout"
+	EXPORTER_FILE   = "file"
+)
+
+const SERVICE_NAME = "castletown"
+
+const TRACER_NAME = "github.com/joshjms/castletown"
+
+// Keys of the attributes of spans.
+const (
+	KEY_JOB_ID     = attribute.Key("castletown.job.id")
+	KEY_STEP       = attribute.Key("castletown.step")
+	KEY_SANDBOX_ID = attribute.Key("castletown.sandbox.id")
+	KEY_IMAGE      = attribute.Key("castletown.image")
+	KEY_STATUS     = attribute.Key("castletown.status")
+)
+
+// Options configure where spans go.
+type Options struct {
+	// Exporter is one of the EXPORTER_* constants.
+	Exporter string
+
+	// Endpoint is the host:port of the OTLP collector, over gRPC. Without
+	// it, the OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint string
+	// Insecure sends spans to Endpoint without TLS.
+	Insecure bool
+
+	// File is where EXPORTER_FILE writes spans, one JSON object each.
+	File string
+}
+
+// Setup installs the tracer provider and propagator of the process. The
+// returned function flushes the spans not exported yet and must be called
+// before the process exits. With EXPORTER_NONE, spans are only used to
+// propagate trace context.
+func Setup(ctx context.Context, opts Options) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+	)
+	switch opts.Exporter {
+	case EXPORTER_NONE, "":
+		return func(context.Context) error { return nil }, nil
+
+	case EXPORTER_OTLP:
+		var grpcOpts []otlptracegrpc.Option
+		if opts.Endpoint != "" {
+			grpcOpts = append(grpcOpts, otlptracegrpc.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			grpcOpts = append(grpcOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, grpcOpts...)
+
+	case EXPORTER_STDOUT:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+
+	case EXPORTER_FILE:
+		if opts.File == "" {
+			return nil, errors.New("the file exporter needs a file")
+		}
+		f, ferr := os.OpenFile(opts.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if ferr != nil {
+			return nil, fmt.Errorf("error opening trace file: %w", ferr)
+		}
+		closer = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q, want %s, %s, %s or %s",
+			opts.Exporter, EXPORTER_NONE, EXPORTER_OTLP, EXPORTER_STDOUT, EXPORTER_FILE)
+	}
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("error creating trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(SERVICE_NAME)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// Start starts a span called name as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TRACER_NAME).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed if err is not nil. It returns err, so
+// that phases can end their span as they return.
+func End(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetupFile(t *testing.T) {
+	defaultProvider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(defaultProvider) })
+
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), Options{Exporter: EXPORTER_FILE, File: file})
+	require.NoError(t, err)
+
+	// The request's span continues the trace of its client.
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(),
+		propagation.MapCarrier{"traceparent": traceparent})
+
+	ctx, parent := Start(ctx, "exec.Run", KEY_JOB_ID.String("j1"))
+	_, child := Start(ctx, "sandbox.Run", KEY_SANDBOX_ID.String("j1-0"))
+	require.Error(t, End(child, errors.New("boom")))
+	require.NoError(t, End(parent, nil))
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	var spans []map[string]any
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	for dec.More() {
+		var span map[string]any
+		require.NoError(t, dec.Decode(&span))
+		spans = append(spans, span)
+	}
+	require.Len(t, spans, 2)
+
+	require.Equal(t, "sandbox.Run", spans[0]["Name"])
+	require.Equal(t, "Error", spans[0]["Status"].(map[string]any)["Code"])
+	require.Equal(t, "exec.Run", spans[1]["Name"])
+	for _, span := range spans {
+		sc := span["SpanContext"].(map[string]any)
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc["TraceID"])
+	}
+	require.Equal(t, spans[1]["SpanContext"].(map[string]any)["SpanID"], spans[0]["Parent"].(map[string]any)["SpanID"])
+}
+
+func TestSetupNone(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{Exporter: EXPORTER_NONE})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	_, span := Start(context.Background(), "exec.Run")
+	require.False(t, span.SpanContext().IsSampled())
+}
+
+func TestSetupErrors(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Exporter: "zipkin"})
+	require.Error(t, err)
+
+	_, err = Setup(context.Background(), Options{Exporter: EXPORTER_FILE})
+	require.Error(t, err)
+
+	_, err = Setup(context.Background(), Options{Exporter: EXPORTER_FILE, File: filepath.Join(t.TempDir(), "missing", "spans.json")})
+	require.Error(t, err)
+}